@@ -13,6 +13,8 @@ type Config struct {
 	DBUser     string
 	DBPassword string
 	DBName     string
+
+	KafkaGroupID string
 }
 
 func LoadConfig() *Config {
@@ -25,6 +27,8 @@ func LoadConfig() *Config {
 		DBUser:     getEnv("DB_USER", "postgres"),
 		DBPassword: os.Getenv("DB_PASSWORD"),
 		DBName:     getEnv("DB_NAME", "fanuc_client_db"),
+
+		KafkaGroupID: getEnv("KAFKA_GROUP_ID", "fanuc-client-bot"),
 	}
 }
 
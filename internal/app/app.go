@@ -6,6 +6,7 @@ import (
 
 	"github.com/iwtcode/fanucClient"
 	"github.com/iwtcode/fanucClient/internal/handlers/telegram"
+	"github.com/iwtcode/fanucClient/internal/handlers/worker"
 	"github.com/iwtcode/fanucClient/internal/repository"
 	"github.com/iwtcode/fanucClient/internal/services"
 	"github.com/iwtcode/fanucClient/internal/usecases"
@@ -25,11 +26,17 @@ func New() *fx.App {
 			// Services
 			services.NewKafkaService,
 			services.NewFanucApiService,
+			services.NewTelegramNotifier,
 
 			// Usecases
 			usecases.NewSettingsUsecase,
 			usecases.NewMonitoringUsecase,
 			usecases.NewControlUsecase,
+			usecases.NewNotificationUsecase,
+			usecases.NewAlertUsecase,
+			usecases.NewMachineWatchUsecase,
+			usecases.NewWatchdogUsecase,
+			usecases.NewReportUsecase,
 
 			// Telegram Components
 			telegram.NewMenu,
@@ -37,9 +44,23 @@ func New() *fx.App {
 			telegram.NewCallbackHandler,
 			telegram.NewRouter,
 			telegram.NewBot,
+
+			// Background Workers
+			worker.NewConsumer,
+			worker.NewAlertEvaluator,
+			worker.NewMachineWatcher,
+			worker.NewSilenceWatchdog,
+			worker.NewHeldNotificationsFlusher,
+			worker.NewShiftReporter,
 		),
 		fx.Invoke(
 			startBot,
+			startConsumer,
+			startAlertEvaluator,
+			startMachineWatcher,
+			startSilenceWatchdog,
+			startHeldNotificationsFlusher,
+			startShiftReporter,
 		),
 	)
 }
@@ -59,3 +80,87 @@ func startBot(lifecycle fx.Lifecycle, bot *telegram.Bot) {
 		},
 	})
 }
+
+func startConsumer(lifecycle fx.Lifecycle, consumer *worker.Consumer) {
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("📡 Kafka consumer запускается...")
+			consumer.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			consumer.Stop()
+			return nil
+		},
+	})
+}
+
+func startAlertEvaluator(lifecycle fx.Lifecycle, evaluator *worker.AlertEvaluator) {
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("🚨 Проверка правил оповещений запускается...")
+			evaluator.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			evaluator.Stop()
+			return nil
+		},
+	})
+}
+
+func startMachineWatcher(lifecycle fx.Lifecycle, watcher *worker.MachineWatcher) {
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("📟 Наблюдение за станками запускается...")
+			watcher.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			watcher.Stop()
+			return nil
+		},
+	})
+}
+
+func startSilenceWatchdog(lifecycle fx.Lifecycle, watchdog *worker.SilenceWatchdog) {
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("🔇 Контроль тишины топиков запускается...")
+			watchdog.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			watchdog.Stop()
+			return nil
+		},
+	})
+}
+
+func startHeldNotificationsFlusher(lifecycle fx.Lifecycle, flusher *worker.HeldNotificationsFlusher) {
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("🗂 Доставка отложенных уведомлений запускается...")
+			flusher.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			flusher.Stop()
+			return nil
+		},
+	})
+}
+
+func startShiftReporter(lifecycle fx.Lifecycle, reporter *worker.ShiftReporter) {
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("📊 Отчеты по сменам запускаются...")
+			reporter.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			reporter.Stop()
+			return nil
+		},
+	})
+}
@@ -0,0 +1,119 @@
+package entities
+
+import (
+	"fmt"
+	"time"
+)
+
+// AlertRule - правило оповещения по значению сообщений (target, key).
+// Condition - условие в синтаксисе фильтров ключа: data.spindle.load > 120, data.alarms not empty
+type AlertRule struct {
+	ID       uint  `gorm:"primaryKey"`
+	UserID   int64 `gorm:"index"` // Владелец правила, получает уведомления
+	TargetID uint  `gorm:"index"`
+	KeyID    uint  `gorm:"index"` // 0 = все сообщения топика (по умолчанию)
+
+	Condition   string `gorm:"size:1024"`
+	DurationSec int    `gorm:"default:0"` // Условие должно выполняться непрерывно (0 = сразу)
+	Enabled     bool   `gorm:"default:true"`
+	Severity    string `gorm:"size:20;default:'warning'"` // см. models.Severity*
+
+	// Неподтвержденное оповещение повторяется каждые RenotifySec (0 = без повторов)
+	// и через EscalateSec после срабатывания уходит пользователям EscalateTo (Telegram ID через запятую)
+	RenotifySec int    `gorm:"default:0"`
+	EscalateSec int    `gorm:"default:0"`
+	EscalateTo  string `gorm:"size:1024"`
+
+	CreatedAt time.Time
+}
+
+// String returns the rule as the user typed it: "data.spindle.load > 120 for 30s"
+func (r AlertRule) String() string {
+	switch {
+	case r.DurationSec <= 0:
+		return r.Condition
+	case r.DurationSec%3600 == 0:
+		return fmt.Sprintf("%s for %dh", r.Condition, r.DurationSec/3600)
+	case r.DurationSec%60 == 0:
+		return fmt.Sprintf("%s for %dm", r.Condition, r.DurationSec/60)
+	}
+	return fmt.Sprintf("%s for %ds", r.Condition, r.DurationSec)
+}
+
+// SilenceWatch - контроль "тишины" (target, key): оповещение, если новых сообщений нет дольше MaxSilenceSec
+type SilenceWatch struct {
+	ID       uint  `gorm:"primaryKey"`
+	UserID   int64 `gorm:"index"`
+	TargetID uint  `gorm:"uniqueIndex:idx_silence_watch"`
+	KeyID    uint  `gorm:"uniqueIndex:idx_silence_watch"` // 0 = все сообщения топика
+
+	MaxSilenceSec int
+
+	// Состояние watchdog
+	LastSeen    time.Time  // время последнего замеченного сообщения
+	Silent      bool       // оповещение о тишине отправлено, ждем восстановления
+	SilentSince *time.Time // начало текущей тишины
+
+	CreatedAt time.Time
+}
+
+// Alert status constants
+const (
+	AlertStatusOpen     = "open"     // ждет подтверждения
+	AlertStatusAcked    = "acked"    // взято в работу
+	AlertStatusResolved = "resolved" // решено
+)
+
+// AlertEvent action constants
+const (
+	AlertActionFired      = "fired"
+	AlertActionRenotified = "renotified"
+	AlertActionEscalated  = "escalated"
+	AlertActionAcked      = "acked"
+	AlertActionResolved   = "resolved"
+)
+
+// Alert - сработавшее правило оповещения
+type Alert struct {
+	ID       uint  `gorm:"primaryKey"`
+	RuleID   uint  `gorm:"index"`
+	UserID   int64 `gorm:"index"` // Владелец правила
+	TargetID *uint `gorm:"index"` // nil = топик удален
+	KeyID    uint
+
+	// Топик и ключ в момент срабатывания, чтобы история оставалась читаемой после их удаления
+	TargetName string `gorm:"size:255"`
+	KeyTitle   string `gorm:"size:1024"`
+
+	RuleText  string `gorm:"size:1100"` // правило в момент срабатывания (правило могут изменить или удалить)
+	Severity  string `gorm:"size:20"`
+	MachineID string `gorm:"size:255;index"`
+	Snapshot  string `gorm:"size:1024"` // значения полей условия в момент срабатывания
+	Text      string `gorm:"type:text"` // текст уведомления (HTML) для повторов и эскалации
+
+	Status  string    `gorm:"size:20;default:'open';index"`
+	FiredAt time.Time `gorm:"index"`
+
+	LastNotifiedAt time.Time
+	NotifyCount    int
+	EscalatedAt    *time.Time
+	EscalatedTo    string `gorm:"size:1024"` // кому ушла эскалация (Telegram ID через запятую)
+
+	AckedBy    int64
+	AckedAt    *time.Time
+	ResolvedBy int64
+	ResolvedAt *time.Time
+
+	Events []AlertEvent `gorm:"foreignKey:AlertID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+// AlertEvent - переход оповещения между состояниями: кто и когда
+type AlertEvent struct {
+	ID       uint   `gorm:"primaryKey"`
+	AlertID  uint   `gorm:"index"`
+	Action   string `gorm:"size:20"`
+	UserID   int64  // 0 = бот
+	UserName string `gorm:"size:255"`
+
+	CreatedAt time.Time
+}
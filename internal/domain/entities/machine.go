@@ -0,0 +1,27 @@
+package entities
+
+import "time"
+
+// MachineSubscription - пользователь получает уведомления о смене состояния станка
+type MachineSubscription struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    int64  `gorm:"uniqueIndex:idx_machine_sub"`
+	ServiceID uint   `gorm:"uniqueIndex:idx_machine_sub"`
+	MachineID string `gorm:"size:255;uniqueIndex:idx_machine_sub"` // ID станка на удаленном сервисе
+	CreatedAt time.Time
+}
+
+// MachineStatusLog - смена состояния станка, замеченная фоновым наблюдателем.
+// Первая запись станка содержит пустые Prev* поля.
+type MachineStatusLog struct {
+	ID        uint   `gorm:"primaryKey"`
+	ServiceID uint   `gorm:"index:idx_machine_status"`
+	MachineID string `gorm:"size:255;index:idx_machine_status"`
+
+	Status     string `gorm:"size:50"` // connected / disconnected / ... / removed
+	Mode       string `gorm:"size:50"` // polling / stopped / ...
+	PrevStatus string `gorm:"size:50"`
+	PrevMode   string `gorm:"size:50"`
+
+	CreatedAt time.Time `gorm:"index"`
+}
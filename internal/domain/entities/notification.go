@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"time"
+)
+
+// NotificationSettings - предпочтения пользователя по доставке уведомлений
+type NotificationSettings struct {
+	UserID int64 `gorm:"primaryKey;autoIncrement:false"` // Telegram Chat ID
+
+	// IANA часовой пояс (Europe/Moscow) или смещение (UTC+3), пусто = UTC
+	TimeZone string `gorm:"size:64"`
+	// Тихие часы "22:00" - "07:00" в часовом поясе пользователя, пусто = выключены
+	QuietFrom string `gorm:"size:5"`
+	QuietTo   string `gorm:"size:5"`
+
+	// Уведомления ниже этой важности не доставляются, см. models.Severity*
+	MinSeverity string `gorm:"size:20;default:'info'"`
+	// Сводка вместо мгновенных уведомлений раз в DigestMinutes (0 = сразу)
+	DigestMinutes int `gorm:"default:0"`
+	LastDigestAt  time.Time
+
+	// Отчеты по сменам: время отправки "08:00,20:00" в часовом поясе пользователя (пусто = выключены)
+	ReportTimes  string `gorm:"size:255"`
+	ReportCSV    bool   `gorm:"default:false"` // прикладывать CSV по станкам
+	LastReportAt time.Time
+
+	UpdatedAt time.Time
+}
+
+// MachineMute - станок, уведомления о котором не доставляются пользователю до Until
+type MachineMute struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    int64     `gorm:"uniqueIndex:idx_machine_mute"`
+	MachineID string    `gorm:"size:255;uniqueIndex:idx_machine_mute"`
+	Until     time.Time `gorm:"index"`
+
+	CreatedAt time.Time
+}
+
+// HeldNotification - уведомление, отложенное тихими часами или режимом сводки
+type HeldNotification struct {
+	ID       uint   `gorm:"primaryKey"`
+	UserID   int64  `gorm:"index"`
+	Severity string `gorm:"size:20"`
+	Text     string `gorm:"type:text"`
+	DedupKey string `gorm:"size:1100"`
+	// Кнопки уведомления (JSON []models.NotificationButton), попадают в сводку
+	Buttons string `gorm:"type:text"`
+
+	CreatedAt time.Time
+}
@@ -11,9 +11,36 @@ const (
 	// Kafka Target Wizard
 	StateWaitingName   = "waiting_name"
 	StateWaitingBroker = "waiting_broker"
-	StateWaitingTopic  = "waiting_topic"
+	// Kafka Auth (SASL / TLS)
+	StateWaitingAuthMechanism = "waiting_auth_mechanism"
+	StateWaitingAuthUser      = "waiting_auth_user"
+	StateWaitingAuthPassword  = "waiting_auth_password"
+	StateWaitingTLS           = "waiting_tls"
+	StateWaitingTopic         = "waiting_topic"
 	// Key is now added separately
-	StateWaitingNewKey = "waiting_new_key"
+	StateWaitingNewKey    = "waiting_new_key"
+	StateWaitingKeyHeader = "waiting_key_header"
+	// Seek by timestamp on key view
+	StateWaitingSeekTime = "waiting_seek_time"
+	// Export of a custom time window
+	StateWaitingExportWindow = "waiting_export_window"
+	// Consumer group of a target (topic stats)
+	StateWaitingTargetGroup = "waiting_target_group"
+	// JSON filters of a key
+	StateWaitingKeyProjection = "waiting_key_projection"
+	StateWaitingKeyPredicate  = "waiting_key_predicate"
+	// Alert rule and max silence of a key
+	StateWaitingAlertRule  = "waiting_alert_rule"
+	StateWaitingMaxSilence = "waiting_max_silence"
+	// Re-notification and escalation of an alert rule
+	StateWaitingRuleRenotify   = "waiting_rule_renotify"
+	StateWaitingRuleEscalation = "waiting_rule_escalation"
+
+	// Notification preferences (profile)
+	StateWaitingTimeZone    = "waiting_time_zone"
+	StateWaitingQuietHours  = "waiting_quiet_hours"
+	StateWaitingMachineMute = "waiting_machine_mute"
+	StateWaitingReportTimes = "waiting_report_times"
 
 	// Service Wizard (Registration of API)
 	StateWaitingSvcName = "waiting_svc_name"
@@ -27,7 +54,8 @@ const (
 	StateWaitingConnSeries   = "waiting_conn_series"
 
 	// Polling Wizard
-	StateWaitingPollInterval = "waiting_poll_interval"
+	StateWaitingPollInterval     = "waiting_poll_interval"
+	StateWaitingBulkPollInterval = "waiting_bulk_poll_interval" // опрос всех станков сервиса
 )
 
 type User struct {
@@ -40,9 +68,19 @@ type User struct {
 
 	// Draft fields for Kafka Wizard
 	DraftName   string `gorm:"size:255"`
-	DraftBroker string `gorm:"size:255"`
+	DraftBroker string `gorm:"size:1024"`
 	DraftTopic  string `gorm:"size:255"`
 	// DraftKey removed, keys are added separately
+	DraftKeyMode   string `gorm:"size:20"`  // Режим сопоставления добавляемого ключа
+	DraftKeyHeader string `gorm:"size:255"` // Заголовок, по которому фильтрует добавляемый ключ
+
+	// Draft fields for Kafka Auth
+	DraftSASLMechanism string `gorm:"size:50"`
+	DraftSASLUsername  string `gorm:"size:255"`
+	DraftSASLPassword  string `gorm:"size:255"`
+	DraftTLSEnabled    bool   `gorm:"default:false"`
+	DraftTLSCACert     string `gorm:"type:text"`
+	DraftTLSInsecure   bool   `gorm:"default:false"`
 
 	// Draft fields for Service Wizard
 	DraftSvcName string `gorm:"size:255"`
@@ -53,6 +91,12 @@ type User struct {
 	ContextSvcID     uint   `gorm:"default:0"` // ID сервиса в БД бота
 	ContextMachineID string `gorm:"size:255"`  // ID станка на удаленном сервисе
 	ContextTargetID  uint   `gorm:"default:0"` // ID Kafka Target для добавления ключа
+	ContextKeyID     uint   `gorm:"default:0"` // ID ключа (0 = по умолчанию) для поиска по времени
+	ContextRuleID    uint   `gorm:"default:0"` // ID правила оповещения для настройки повторов/эскалации
+
+	// Filters of the alert history screen (пусто = все)
+	AlertFilterStatus  string `gorm:"size:20"`
+	AlertFilterMachine string `gorm:"size:255"`
 
 	// Draft fields for Connection Wizard
 	DraftConnEndpoint string `gorm:"size:255"` // IP:PORT
@@ -72,11 +116,29 @@ type MonitoringTarget struct {
 	ID     uint   `gorm:"primaryKey"`
 	UserID int64  `gorm:"index"`
 	Name   string `gorm:"size:255"`
-	Broker string `gorm:"size:255"`
+	Broker string `gorm:"size:1024"` // Bootstrap список через запятую: host1:9092,host2:9092
 	Topic  string `gorm:"size:255"`
 
+	// Authentication (SASL), see models.SASL* constants
+	SASLMechanism string `gorm:"size:50"`
+	SASLUsername  string `gorm:"size:255"`
+	SASLPassword  string `gorm:"size:255"`
+
+	// Encryption (TLS)
+	TLSEnabled  bool   `gorm:"default:false"`
+	TLSCACert   string `gorm:"type:text"`     // PEM, пусто = системные CA
+	TLSInsecure bool   `gorm:"default:false"` // InsecureSkipVerify
+
+	// Consumer group, чей lag показывается в статистике (пусто = не показывать)
+	ConsumerGroup string `gorm:"size:255"`
+
 	// Keys related to this target
 	Keys []MonitoringKey `gorm:"foreignKey:TargetID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	// Alert rules of the target and its keys
+	AlertRules     []AlertRule    `gorm:"foreignKey:TargetID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	SilenceWatches []SilenceWatch `gorm:"foreignKey:TargetID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	// История оповещений переживает удаление топика
+	Alerts []Alert `gorm:"foreignKey:TargetID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 
 	CreatedAt time.Time
 }
@@ -85,16 +147,29 @@ type MonitoringTarget struct {
 type MonitoringKey struct {
 	ID        uint   `gorm:"primaryKey"`
 	TargetID  uint   `gorm:"index"`
-	Key       string `gorm:"size:255"`
+	Key       string `gorm:"size:255"`                // Ключ или шаблон, в зависимости от MatchMode
+	MatchMode string `gorm:"size:20;default:'exact'"` // см. models.KeyMatch*
+	Header    string `gorm:"size:255"`                // Сравнивать значение заголовка вместо ключа записи
+
+	// JSON фильтры значения: пути через запятую (data.spindle.speed, data.alarms[*])
+	// и условие (data.mode == "AUTO" && data.spindle.speed > 0)
+	Projection string `gorm:"type:text"`
+	Predicate  string `gorm:"size:1024"`
+
 	CreatedAt time.Time
 }
 
 // FanucService - подключение к REST API fanucService (управление)
 type FanucService struct {
-	ID        uint   `gorm:"primaryKey"`
-	UserID    int64  `gorm:"index"`
-	Name      string `gorm:"size:255"` // Friendly name (e.g. "Цех №1")
-	BaseURL   string `gorm:"size:255"` // http://ip:port
-	APIKey    string `gorm:"size:255"`
+	ID      uint   `gorm:"primaryKey"`
+	UserID  int64  `gorm:"index"`
+	Name    string `gorm:"size:255"` // Friendly name (e.g. "Цех №1")
+	BaseURL string `gorm:"size:255"` // http://ip:port
+	APIKey  string `gorm:"size:255"`
+
+	// Machine status notifications and history
+	Subscriptions []MachineSubscription `gorm:"foreignKey:ServiceID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	StatusLog     []MachineStatusLog    `gorm:"foreignKey:ServiceID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
 	CreatedAt time.Time
 }
@@ -0,0 +1,62 @@
+package models
+
+// TelemetryEvent - сообщение, принятое фильтрами (target, key) фонового consumer-а
+type TelemetryEvent struct {
+	TargetID uint
+	KeyID    uint // 0 = все сообщения топика
+	Message  KafkaMessage
+}
+
+// Severity constants (важность уведомлений)
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Severities - уровни важности по возрастанию
+var Severities = []string{SeverityInfo, SeverityWarning, SeverityCritical}
+
+// SeverityLevel returns the position of the severity in Severities (unknown = info)
+func SeverityLevel(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return 0
+}
+
+// Notification - исходящее уведомление пользователю Telegram
+type Notification struct {
+	ChatID int64
+	Text   string // HTML
+	// Важность (см. Severity*) и станок уведомления - для предпочтений пользователя
+	Severity  string
+	MachineID string
+	// Уведомления с одинаковым DedupKey в один чат не повторяются в течение окна (пусто = без дедупликации)
+	DedupKey string
+	// Inline-кнопки под сообщением, обрабатываются CallbackHandler-ом
+	Buttons []NotificationButton
+	// Файл, отправляемый документом; Text становится подписью (до 1024 символов)
+	Document *ExportFile
+}
+
+// NotificationButton - inline-кнопка уведомления (Data в формате callback "action:id")
+type NotificationButton struct {
+	Text string
+	Data string
+	// Кнопки с одинаковым Row идут в одну строку (по умолчанию все в одной)
+	Row int
+}
+
+// DigestCallbackSuffix дописывается к Data кнопок сводки: обработчик не должен
+// заменять сводку карточкой одного уведомления
+const DigestCallbackSuffix = ":digest"
+
+// AlertFilter - фильтр истории оповещений пользователя (пустые поля = без фильтра)
+type AlertFilter struct {
+	UserID    int64 // владелец правила или получатель эскалации
+	Status    string
+	MachineID string
+}
@@ -1 +1,20 @@
 package models
+
+// Bulk action constants
+const (
+	BulkStartPolling = "start"
+	BulkStopPolling  = "stop"
+	BulkRecheck      = "check"
+)
+
+// BulkResult - результат массового действия для одного станка сервиса
+type BulkResult struct {
+	MachineID string
+	Endpoint  string
+	Done      bool
+	Err       error
+
+	// Состояние станка после проверки (BulkRecheck)
+	Status string
+	Mode   string
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// HumanDuration prints a rounded duration in Russian: "45 с", "12 мин", "3 ч 5 мин", "2 д 4 ч"
+func HumanDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%d с", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%d мин", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d ч %d мин", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%d д %d ч", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
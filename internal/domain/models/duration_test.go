@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0 с"},
+		{45 * time.Second, "45 с"},
+		{90 * time.Second, "1 мин"},
+		{59 * time.Minute, "59 мин"},
+		{3*time.Hour + 5*time.Minute, "3 ч 5 мин"},
+		{24 * time.Hour, "1 д 0 ч"},
+		{52 * time.Hour, "2 д 4 ч"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d.String(), func(t *testing.T) {
+			if got := HumanDuration(tt.d); got != tt.want {
+				t.Errorf("HumanDuration(%v) = %q, want %q", tt.d, got, tt.want)
+			}
+		})
+	}
+}
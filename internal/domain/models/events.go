@@ -1,6 +1,13 @@
 package models
 
-import "encoding/json"
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
 
 // FanucMessage представляет структуру сообщения, получаемого из топика Kafka.
 // Она должна соответствовать тому, что отправляет fanucService.
@@ -9,3 +16,218 @@ type FanucMessage struct {
 	Timestamp int64           `json:"timestamp"` // Unix ms, если отправляется
 	Data      json.RawMessage `json:"data"`      // Сырые данные (Focas data), структуру которых мы можем уточнить позже
 }
+
+// MachineStatus - типизированное состояние станка, декодированное из FanucMessage.
+// Незаполненные поля (nil / пустые строки) в сообщении отсутствовали.
+type MachineStatus struct {
+	MachineID string
+	Time      time.Time // FanucMessage.Timestamp, если передан
+
+	RunMode       string // AUTO, MDI, JOG, EDIT ...
+	RunState      string // START, STOP, HOLD ...
+	ProgramNumber string
+	ProgramName   string
+
+	SpindleSpeed *float64 // об/мин
+	SpindleLoad  *float64 // %
+	FeedRate     *float64 // мм/мин
+	FeedOverride *float64 // %
+	PartCount    *int64
+
+	Alarms    []MachineAlarm
+	HasAlarms bool // список аварий присутствовал в сообщении (возможно, пустой)
+}
+
+type MachineAlarm struct {
+	Code    string
+	Message string
+}
+
+// SASL механизмы аутентификации Kafka
+const (
+	SASLNone        = ""
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// KafkaAuth - параметры аутентификации (SASL) и шифрования (TLS)
+type KafkaAuth struct {
+	Mechanism string // SASLNone, SASLPlain, SASLScramSHA256, SASLScramSHA512
+	Username  string
+	Password  string
+
+	TLS                bool
+	CACert             string // PEM, пусто = системные CA
+	InsecureSkipVerify bool
+}
+
+// KafkaSource - источник сообщений (Bootstrap Brokers + Topic), на который подписывается consumer
+type KafkaSource struct {
+	Brokers []string // bootstrap список, перебирается до первого доступного
+	Topic   string
+	Auth    KafkaAuth
+}
+
+// ID возвращает уникальный идентификатор источника.
+// Одинаковые Brokers/Topic с разными учетными данными считаются разными источниками,
+// порядок bootstrap брокеров не важен.
+func (s KafkaSource) ID() string {
+	brokers := append([]string(nil), s.Brokers...)
+	sort.Strings(brokers)
+	id := strings.Join(brokers, ",") + "/" + s.Topic
+	if s.Auth.Mechanism != SASLNone {
+		id += "#" + s.Auth.Mechanism + ":" + s.Auth.Username
+	}
+	return id
+}
+
+// GroupID возвращает consumer group источника: у каждого источника своя группа,
+// иначе Kafka делила бы партиции топика между читателями разных источников
+func (s KafkaSource) GroupID(prefix string) string {
+	sum := sha1.Sum([]byte(s.ID()))
+	return prefix + "." + s.Topic + "." + hex.EncodeToString(sum[:4])
+}
+
+// ParseBrokers разбирает bootstrap список "host1:9092, host2:9092" без пустых и повторяющихся адресов
+func ParseBrokers(list string) []string {
+	var brokers []string
+	seen := make(map[string]bool)
+	for _, b := range strings.Split(list, ",") {
+		b = strings.TrimSpace(b)
+		if b == "" || seen[b] {
+			continue
+		}
+		seen[b] = true
+		brokers = append(brokers, b)
+	}
+	return brokers
+}
+
+// TopicInfo - топик кластера с количеством партиций
+type TopicInfo struct {
+	Name       string
+	Partitions int
+}
+
+// ClusterInfo - метаданные кластера Kafka для топика
+type ClusterInfo struct {
+	Brokers    []BrokerInfo
+	Controller BrokerInfo
+	Partitions []PartitionInfo
+}
+
+type BrokerInfo struct {
+	ID   int
+	Addr string // host:port
+	Rack string
+}
+
+type PartitionInfo struct {
+	ID       int
+	Leader   BrokerInfo // ID == -1 и пустой Addr, если лидер недоступен
+	Replicas int
+	ISR      int
+}
+
+// PartitionOffsets - границы партиции: First - первый доступный offset, Last - следующий за последним
+type PartitionOffsets struct {
+	Partition int
+	First     int64
+	Last      int64
+}
+
+// PartitionStats - состояние партиции для экрана статистики
+type PartitionStats struct {
+	Partition   int
+	First       int64
+	Last        int64
+	RecentCount int64     // записей после момента Since
+	NewestTime  time.Time // время последней записи (пусто, если партиция пуста)
+}
+
+// TopicStats - сводная статистика топика
+type TopicStats struct {
+	Partitions []PartitionStats
+	Window     time.Duration // окно расчета скорости
+	Total      int64         // записей в хранении
+	PerMinute  float64
+	NewestTime time.Time
+
+	// Consumer group (если настроена у target)
+	Group      string
+	Lag        map[int]int64 // по партициям; нет записи = группа не коммитила offset
+	TotalLag   int64
+	GroupError string
+}
+
+// KafkaMessage - прочитанная из топика запись
+type KafkaMessage struct {
+	Key       string
+	Value     string
+	Headers   []Header
+	Partition int
+	Offset    int64
+	Time      time.Time
+}
+
+// Header - заголовок записи Kafka (machine_id, schema_version, trace ID)
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Header возвращает значение первого заголовка с указанным именем
+func (m KafkaMessage) Header(name string) (string, bool) {
+	for _, h := range m.Headers {
+		if h.Key == name {
+			return h.Value, true
+		}
+	}
+	return "", false
+}
+
+// Форматы экспорта сообщений
+const (
+	ExportJSONL = "jsonl"
+	ExportCSV   = "csv"
+)
+
+// ExportRange - диапазон экспорта: последние Last сообщений или окно времени [From, To)
+type ExportRange struct {
+	Last int
+	From time.Time
+	To   time.Time
+}
+
+// ExportFile - готовый файл экспорта для отправки документом
+type ExportFile struct {
+	Name       string
+	MIME       string
+	Data       []byte
+	Count      int
+	Compressed bool // gzip
+	Truncated  bool // достигнут лимит количества сообщений
+}
+
+// MessagePosition - положение записи для навигации по соседним сообщениям
+type MessagePosition struct {
+	Partition int
+	Offset    int64
+	Time      time.Time
+}
+
+func (m KafkaMessage) Position() MessagePosition {
+	return MessagePosition{Partition: m.Partition, Offset: m.Offset, Time: m.Time}
+}
+
+// Before задает порядок записей разных партиций: по времени, затем по партиции и offset
+func (p MessagePosition) Before(other MessagePosition) bool {
+	if !p.Time.Equal(other.Time) {
+		return p.Time.Before(other.Time)
+	}
+	if p.Partition != other.Partition {
+		return p.Partition < other.Partition
+	}
+	return p.Offset < other.Offset
+}
@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestKafkaSourceID(t *testing.T) {
+	base := KafkaSource{Brokers: []string{"a:9092", "b:9092"}, Topic: "telemetry"}
+	tests := []struct {
+		name string
+		src  KafkaSource
+		same bool
+	}{
+		{"same source", KafkaSource{Brokers: []string{"a:9092", "b:9092"}, Topic: "telemetry"}, true},
+		{"broker order", KafkaSource{Brokers: []string{"b:9092", "a:9092"}, Topic: "telemetry"}, true},
+		{"other topic", KafkaSource{Brokers: []string{"a:9092", "b:9092"}, Topic: "alarms"}, false},
+		{"other broker", KafkaSource{Brokers: []string{"a:9092"}, Topic: "telemetry"}, false},
+		{"sasl user", KafkaSource{Brokers: []string{"a:9092", "b:9092"}, Topic: "telemetry", Auth: KafkaAuth{Mechanism: SASLPlain, Username: "bot"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.src.ID() == base.ID(); got != tt.same {
+				t.Errorf("ID() %q vs %q: same = %v, want %v", tt.src.ID(), base.ID(), got, tt.same)
+			}
+			if got := tt.src.GroupID("fanuc") == base.GroupID("fanuc"); got != tt.same {
+				t.Errorf("GroupID() %q vs %q: same = %v, want %v", tt.src.GroupID("fanuc"), base.GroupID("fanuc"), got, tt.same)
+			}
+		})
+	}
+
+	// ID must not reorder the bootstrap list used for dialing
+	src := KafkaSource{Brokers: []string{"b:9092", "a:9092"}, Topic: "t"}
+	src.ID()
+	if src.Brokers[0] != "b:9092" {
+		t.Error("ID() changed the broker order of the source")
+	}
+}
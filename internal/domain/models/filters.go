@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Режимы сопоставления ключа записи с MonitoringKey
+const (
+	KeyMatchExact  = "exact"  // точное совпадение
+	KeyMatchPrefix = "prefix" // line3/ → line3/cnc-07/status
+	KeyMatchGlob   = "glob"   // line3/*/status: * - любая подстрока (включая /), ? - один символ
+	KeyMatchRegex  = "regex"  // регулярное выражение Go (RE2), не привязано к началу строки
+)
+
+// KeyMatchModes - режимы в порядке отображения в меню
+var KeyMatchModes = []string{KeyMatchExact, KeyMatchPrefix, KeyMatchGlob, KeyMatchRegex}
+
+// KeyFilter - фильтр по ключу (или заголовку) записи Kafka. Пустой Pattern пропускает все записи.
+type KeyFilter struct {
+	Mode    string // KeyMatch*, пусто = KeyMatchExact
+	Pattern string
+	Header  string // имя заголовка, значение которого сравнивается вместо ключа записи
+}
+
+// IsExact сообщает, что ключ задан точно и его партицию можно вычислить хешем
+func (f KeyFilter) IsExact() bool {
+	return f.Mode == KeyMatchExact || f.Mode == ""
+}
+
+// ByRecordKey сообщает, что фильтр задан по ключу записи (а не по заголовку)
+func (f KeyFilter) ByRecordKey() bool {
+	return f.Header == ""
+}
+
+// Compile проверяет шаблон и возвращает функцию отбора записей
+func (f KeyFilter) Compile() (func(m KafkaMessage) bool, error) {
+	match, err := f.compilePattern()
+	if err != nil {
+		return nil, err
+	}
+	if f.Pattern == "" {
+		return func(KafkaMessage) bool { return true }, nil
+	}
+	if f.Header == "" {
+		return func(m KafkaMessage) bool { return match(m.Key) }, nil
+	}
+
+	header := f.Header
+	return func(m KafkaMessage) bool {
+		value, ok := m.Header(header)
+		return ok && match(value)
+	}, nil
+}
+
+func (f KeyFilter) compilePattern() (func(value string) bool, error) {
+	if f.Pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	switch f.Mode {
+	case KeyMatchExact, "":
+		pattern := f.Pattern
+		return func(key string) bool { return key == pattern }, nil
+
+	case KeyMatchPrefix:
+		prefix := f.Pattern
+		return func(key string) bool { return strings.HasPrefix(key, prefix) }, nil
+
+	case KeyMatchGlob:
+		re, err := regexp.Compile(globToRegexp(f.Pattern))
+		if err != nil {
+			return nil, fmt.Errorf("некорректный glob шаблон: %w", err)
+		}
+		return re.MatchString, nil
+
+	case KeyMatchRegex:
+		re, err := regexp.Compile(f.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("некорректное регулярное выражение: %w", err)
+		}
+		return re.MatchString, nil
+
+	default:
+		return nil, fmt.Errorf("неизвестный режим сопоставления: %s", f.Mode)
+	}
+}
+
+// Validate проверяет, что шаблон можно использовать
+func (f KeyFilter) Validate() error {
+	_, err := f.Compile()
+	return err
+}
+
+// String описывает фильтр для отображения пользователю
+func (f KeyFilter) String() string {
+	pattern := f.Pattern
+	if f.Header != "" {
+		pattern = f.Header + "=" + pattern
+	}
+	if f.IsExact() {
+		return pattern
+	}
+	return fmt.Sprintf("%s [%s]", pattern, f.Mode)
+}
+
+// globToRegexp converts * and ? wildcards to an anchored regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package models
+
+import "testing"
+
+func TestKeyFilterMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter KeyFilter
+		key    string
+		want   bool
+	}{
+		{"empty pattern matches all", KeyFilter{}, "anything", true},
+		{"exact", KeyFilter{Pattern: "cnc-07"}, "cnc-07", true},
+		{"exact mismatch", KeyFilter{Mode: KeyMatchExact, Pattern: "cnc-07"}, "cnc-070", false},
+		{"prefix", KeyFilter{Mode: KeyMatchPrefix, Pattern: "line3/"}, "line3/cnc-07/status", true},
+		{"prefix mismatch", KeyFilter{Mode: KeyMatchPrefix, Pattern: "line3/"}, "line4/cnc-07", false},
+		{"glob star spans slashes", KeyFilter{Mode: KeyMatchGlob, Pattern: "line3/*/status"}, "line3/a/b/status", true},
+		{"glob question mark", KeyFilter{Mode: KeyMatchGlob, Pattern: "cnc-0?"}, "cnc-07", true},
+		{"glob question mark is one char", KeyFilter{Mode: KeyMatchGlob, Pattern: "cnc-0?"}, "cnc-071", false},
+		{"glob is anchored", KeyFilter{Mode: KeyMatchGlob, Pattern: "cnc-*"}, "x/cnc-07", false},
+		{"glob quotes regexp meta", KeyFilter{Mode: KeyMatchGlob, Pattern: "a.b+c"}, "a.b+c", true},
+		{"glob dot is literal", KeyFilter{Mode: KeyMatchGlob, Pattern: "a.b"}, "axb", false},
+		{"regex is not anchored", KeyFilter{Mode: KeyMatchRegex, Pattern: `cnc-\d+`}, "line3/cnc-07", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := tt.filter.Compile()
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if got := match(KafkaMessage{Key: tt.key}); got != tt.want {
+				t.Errorf("match(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyFilterHeader(t *testing.T) {
+	match, err := KeyFilter{Mode: KeyMatchPrefix, Pattern: "M-", Header: "machine_id"}.Compile()
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	withHeader := KafkaMessage{Key: "other", Headers: []Header{{Key: "machine_id", Value: "M-01"}}}
+	if !match(withHeader) {
+		t.Error("header value should be matched instead of the record key")
+	}
+	if match(KafkaMessage{Key: "M-01"}) {
+		t.Error("record without the header should not match")
+	}
+}
+
+func TestKeyFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  KeyFilter
+		wantErr bool
+	}{
+		{"valid regex", KeyFilter{Mode: KeyMatchRegex, Pattern: `^a+$`}, false},
+		{"broken regex", KeyFilter{Mode: KeyMatchRegex, Pattern: `(`}, true},
+		{"unknown mode", KeyFilter{Mode: "fuzzy", Pattern: "a"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
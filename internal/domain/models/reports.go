@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// ShiftReport - сводка за смену по сервисам и топикам пользователя
+type ShiftReport struct {
+	UserID int64
+	From   time.Time
+	To     time.Time
+
+	Machines     []MachineReport
+	Alerts       AlertStats
+	Targets      int
+	SilentTopics []SilentTopic
+}
+
+// MachineReport - состояние станка за период по журналу смены статусов
+type MachineReport struct {
+	ServiceID uint
+	Service   string
+	MachineID string
+	Status    string // последнее известное состояние
+
+	Observed    time.Duration // время, за которое состояние станка известно
+	Connected   time.Duration
+	Polling     time.Duration
+	Transitions int
+	Alerts      int
+}
+
+// Availability returns the share of the observed time the machine was connected (-1 = no data)
+func (m MachineReport) Availability() float64 {
+	if m.Observed <= 0 {
+		return -1
+	}
+	return float64(m.Connected) / float64(m.Observed)
+}
+
+// PollingUptime returns the share of the observed time the machine was polled (-1 = no data)
+func (m MachineReport) PollingUptime() float64 {
+	if m.Observed <= 0 {
+		return -1
+	}
+	return float64(m.Polling) / float64(m.Observed)
+}
+
+// AlertStats - оповещения, сработавшие за период, по текущему состоянию
+type AlertStats struct {
+	Fired    int
+	Acked    int // приняты в работу (в том числе уже решенные)
+	Resolved int
+	Open     int
+}
+
+// SilentTopic - (target, key), в который сейчас не поступают сообщения
+type SilentTopic struct {
+	Target string
+	Key    string
+	Since  time.Time
+}
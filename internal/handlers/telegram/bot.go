@@ -37,6 +37,7 @@ func NewBot(cfg *fanucClient.Config, router *Router) *Bot {
 		{Text: "start", Description: "Главное меню"},
 		{Text: "kafka", Description: "Управление Kafka Targets"},
 		{Text: "services", Description: "Управление API Services"},
+		{Text: "alerts", Description: "История оповещений"},
 		{Text: "profile", Description: "Профиль пользователя"},
 	})
 	if err != nil {
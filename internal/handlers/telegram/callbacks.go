@@ -1,6 +1,7 @@
 package telegram
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -11,19 +12,50 @@ import (
 	"time"
 
 	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
 	"github.com/iwtcode/fanucClient/internal/interfaces"
 	"github.com/iwtcode/fanucService"
 	tele "gopkg.in/telebot.v3"
 )
 
+const (
+	// Минимальный интервал между редактированиями сообщения в Live Mode
+	liveEditInterval = 2 * time.Second
+	// Сколько последних сообщений можно пролистать в истории
+	historySize = 50
+	// Сколько живет прочитанное окно истории для листания
+	historyCacheTTL = 10 * time.Minute
+)
+
+// historyKey identifies the message the history is paged in
+type historyKey struct {
+	chatID    int64
+	messageID int
+}
+
+// historyWindow is the history read when it was opened; pages are served from it
+type historyWindow struct {
+	targetID  uint
+	keyID     uint
+	messages  []models.KafkaMessage
+	fetchedAt time.Time
+}
+
 type CallbackHandler struct {
 	menu         *Menu
 	settingsUC   interfaces.SettingsUsecase
 	monitoringUC interfaces.MonitoringUsecase
 	controlUC    interfaces.ControlUsecase
+	alertUC      interfaces.AlertUsecase
+	watchUC      interfaces.MachineWatchUsecase
+	watchdogUC   interfaces.WatchdogUsecase
+	notifyUC     interfaces.NotificationUsecase
+	reportUC     interfaces.ReportUsecase
 	cmdHandler   *CommandHandler
 
 	liveSessions sync.Map
+	// historyKey -> *historyWindow
+	histories sync.Map
 }
 
 func NewCallbackHandler(
@@ -31,6 +63,11 @@ func NewCallbackHandler(
 	sUC interfaces.SettingsUsecase,
 	mUC interfaces.MonitoringUsecase,
 	cUC interfaces.ControlUsecase,
+	aUC interfaces.AlertUsecase,
+	wUC interfaces.MachineWatchUsecase,
+	wdUC interfaces.WatchdogUsecase,
+	nUC interfaces.NotificationUsecase,
+	rUC interfaces.ReportUsecase,
 	cmd *CommandHandler,
 ) *CallbackHandler {
 	return &CallbackHandler{
@@ -38,6 +75,11 @@ func NewCallbackHandler(
 		settingsUC:   sUC,
 		monitoringUC: mUC,
 		controlUC:    cUC,
+		alertUC:      aUC,
+		watchUC:      wUC,
+		watchdogUC:   wdUC,
+		notifyUC:     nUC,
+		reportUC:     rUC,
 		cmdHandler:   cmd,
 	}
 }
@@ -53,6 +95,40 @@ func (h *CallbackHandler) OnCallback(c tele.Context) error {
 		return h.cmdHandler.OnStart(c)
 	case "who_btn":
 		return h.cmdHandler.OnWho(c)
+
+	// Notification Preferences
+	case "prefs":
+		h.settingsUC.SetState(c.Sender().ID, entities.StateIdle)
+		return h.cmdHandler.showPreferences(c)
+	case "prefs_tz":
+		return h.onPreferenceInput(c, entities.StateWaitingTimeZone,
+			"🌍 <b>Часовой пояс</b>\n\nВведите название (<code>Europe/Moscow</code>, <code>Asia/Yekaterinburg</code>) "+
+				"или смещение от UTC (<code>UTC+3</code>, <code>+05:30</code>).")
+	case "prefs_quiet":
+		return h.onPreferenceInput(c, entities.StateWaitingQuietHours,
+			"🌙 <b>Тихие часы</b>\n\nВведите интервал в вашем часовом поясе, например <code>22:00-07:00</code>.\n"+
+				"Отправьте <code>-</code>, чтобы выключить тихие часы.")
+	case "prefs_mute":
+		return h.onPreferenceInput(c, entities.StateWaitingMachineMute,
+			"🔇 <b>Заглушить станок</b>\n\nВведите ID станка и время: <code>mute M-01 2h</code>.\n"+
+				"Оповещения и уведомления о статусе этого станка не будут приходить до истечения времени.")
+	case "prefs_sev":
+		return h.onCycleMinSeverity(c)
+	case "prefs_dig":
+		return h.onCycleDigest(c)
+
+	// Shift Reports
+	case "reports":
+		h.settingsUC.SetState(c.Sender().ID, entities.StateIdle)
+		return h.cmdHandler.showReports(c)
+	case "reports_time":
+		return h.onPreferenceInput(c, entities.StateWaitingReportTimes,
+			"🕒 <b>Время отчетов</b>\n\nВведите время окончания смен в вашем часовом поясе через запятую, "+
+				"например <code>08:00, 20:00</code>.\nОтправьте <code>-</code>, чтобы выключить отчеты.")
+	case "reports_csv":
+		return h.onToggleReportCSV(c)
+	case "reports_now":
+		return h.onSendReportNow(c)
 	case "cancel_wizard":
 		return h.onCancelWizard(c)
 
@@ -67,6 +143,10 @@ func (h *CallbackHandler) OnCallback(c tele.Context) error {
 		return h.onListServices(c)
 	case "add_service":
 		return h.onAddServiceStart(c)
+
+	// Alerts History
+	case "alerts_mf":
+		return h.onAlertMachineFilter(c)
 	}
 
 	// 2. Dynamic Actions
@@ -92,6 +172,22 @@ func (h *CallbackHandler) handleDynamicCallback(c tele.Context, data string) err
 
 	case "add_key_start":
 		return h.onAddKeyStart(c, uID)
+	case "stats":
+		return h.onTopicStats(c, uID)
+	case "grp_start":
+		return h.onConsumerGroupStart(c, uID)
+	case "key_mode":
+		return h.onKeyMode(c, parts[1])
+	case "key_src":
+		return h.onKeySource(c, parts[1])
+
+	// Kafka Auth Wizard (Format: action:value)
+	case "auth_mech":
+		return h.onAuthMechanism(c, parts[1])
+	case "tls":
+		return h.onTLSOption(c, parts[1])
+	case "pick_topic":
+		return h.onPickTopic(c, idVal)
 
 	case "view_key":
 		if len(parts) < 3 {
@@ -114,6 +210,122 @@ func (h *CallbackHandler) handleDynamicCallback(c tele.Context, data string) err
 		keyID, _ := strconv.Atoi(parts[2])
 		return h.onCheckMessage(c, uID, uint(keyID))
 
+	case "hist", "histp":
+		if len(parts) < 4 {
+			return nil
+		}
+		keyID, _ := strconv.Atoi(parts[2])
+		idx, _ := strconv.Atoi(parts[3])
+		// "hist" opens the history with a fresh read, "histp" turns pages of the read window
+		return h.onHistory(c, uID, uint(keyID), idx, action == "hist")
+
+	case "proj_start", "pred_start":
+		if len(parts) < 3 {
+			return nil
+		}
+		keyID, _ := strconv.Atoi(parts[2])
+		return h.onKeyFilterStart(c, uID, uint(keyID), action == "pred_start")
+
+	// Export (Format: exp:targetID:keyID[:range[:format]])
+	case "exp", "exp_r", "exp_f", "exp_win":
+		if len(parts) < 3 {
+			return nil
+		}
+		keyID, _ := strconv.Atoi(parts[2])
+		switch {
+		case action == "exp":
+			return h.onExportStart(c, uID, uint(keyID))
+		case action == "exp_win":
+			return h.onExportWindowStart(c, uID, uint(keyID))
+		case action == "exp_r" && len(parts) >= 4:
+			return h.onExportRange(c, uID, uint(keyID), parts[3])
+		case action == "exp_f" && len(parts) >= 5:
+			return h.onExportRun(c, uID, uint(keyID), parts[3], parts[4])
+		}
+
+	// Alert Rules (Format: rules:targetID:keyID / rule:ruleID)
+	case "rules", "rule_add":
+		if len(parts) < 3 {
+			return nil
+		}
+		keyID, _ := strconv.Atoi(parts[2])
+		if action == "rule_add" {
+			return h.onAddRuleStart(c, uID, uint(keyID))
+		}
+		return h.onListRules(c, uID, uint(keyID))
+	case "rule":
+		return h.onViewRule(c, uID)
+	case "silence":
+		if len(parts) < 3 {
+			return nil
+		}
+		keyID, _ := strconv.Atoi(parts[2])
+		return h.onMaxSilenceStart(c, uID, uint(keyID))
+	case "rule_tgl":
+		return h.onToggleRule(c, uID)
+	case "rule_del":
+		return h.onDeleteRule(c, uID)
+	case "rule_sev":
+		return h.onCycleRuleSeverity(c, uID)
+	case "rule_rn":
+		return h.onRuleRenotifyStart(c, uID)
+	case "rule_esc":
+		return h.onRuleEscalationStart(c, uID)
+
+	// Alerts (Format: alert_ack:alertID[:digest], buttons of notifications)
+	case "alert_ack", "alert_res":
+		digest := strings.HasSuffix(data, models.DigestCallbackSuffix)
+		return h.onAlertAction(c, uID, action == "alert_res", digest)
+
+	// Machine Mutes (Format: unmute:machineID, machine ID may contain ':')
+	case "unmute":
+		h.notifyUC.UnmuteMachine(c.Sender().ID, strings.Join(parts[1:], ":"))
+		return h.cmdHandler.showPreferences(c)
+
+	// Alerts History (Format: alerts:page / alert:alertID:page)
+	case "alerts":
+		return h.cmdHandler.showAlerts(c, idVal)
+	case "alerts_st":
+		status := parts[1]
+		if status == "all" {
+			status = ""
+		}
+		h.settingsUC.SetAlertFilterStatus(c.Sender().ID, status)
+		return h.cmdHandler.showAlerts(c, 0)
+	case "alerts_m":
+		h.settingsUC.SetAlertFilterMachine(c.Sender().ID, strings.Join(parts[1:], ":"))
+		return h.cmdHandler.showAlerts(c, 0)
+	case "alert", "alert_vack", "alert_vres":
+		page := 0
+		if len(parts) > 2 {
+			page, _ = strconv.Atoi(parts[2])
+		}
+		if action != "alert" {
+			if _, err := h.changeAlert(c, uID, action == "alert_vres"); err != nil {
+				c.Respond(&tele.CallbackResponse{Text: "⚠️ " + err.Error(), ShowAlert: true})
+			}
+		}
+		return h.onViewAlert(c, uID, page)
+
+	case "seek_start":
+		if len(parts) < 3 {
+			return nil
+		}
+		keyID, _ := strconv.Atoi(parts[2])
+		return h.onSeekStart(c, uID, uint(keyID))
+
+	// Format: sk:targetID:keyID:partition:offset:unixMilli:direction
+	case "sk":
+		if len(parts) < 7 {
+			return nil
+		}
+		keyID, _ := strconv.Atoi(parts[2])
+		partition, _ := strconv.Atoi(parts[3])
+		offset, _ := strconv.ParseInt(parts[4], 10, 64)
+		ms, _ := strconv.ParseInt(parts[5], 10, 64)
+		pos := models.MessagePosition{Partition: partition, Offset: offset, Time: time.UnixMilli(ms)}
+		return h.onSeekNeighbour(c, uID, uint(keyID), pos, parts[6] == "n")
+
 	case "live_mode":
 		if len(parts) < 3 {
 			return nil
@@ -135,9 +347,15 @@ func (h *CallbackHandler) handleDynamicCallback(c tele.Context, data string) err
 		return h.onDeleteService(c, uID)
 	case "add_conn":
 		return h.onAddConnectionStart(c, uID)
+	case "bulk_sp":
+		return h.onBulkPollWizard(c, uID)
+	case "bulk_stp":
+		return h.onBulkAction(c, uID, models.BulkStopPolling)
+	case "bulk_chk":
+		return h.onBulkAction(c, uID, models.BulkRecheck)
 
 	// Machine Actions (Format: action:svcID:machineID)
-	case "vm", "sp", "stp", "gp", "dc":
+	case "vm", "sp", "stp", "gp", "dc", "msub", "munsub", "mmute", "munmute":
 		if len(parts) < 3 {
 			return nil
 		}
@@ -153,6 +371,10 @@ func (h *CallbackHandler) handleDynamicCallback(c tele.Context, data string) err
 			return h.onGetProgram(c, uID, machineID)
 		case "dc": // delete connection
 			return h.onDeleteConnection(c, uID, machineID)
+		case "msub", "munsub": // status notifications
+			return h.onMachineSubscription(c, uID, machineID, action == "msub")
+		case "mmute", "munmute": // mute notifications for a while
+			return h.onMachineMute(c, uID, machineID, action == "mmute")
 		}
 	}
 	return nil
@@ -171,7 +393,7 @@ func (h *CallbackHandler) onListServices(c tele.Context) error {
 	}
 
 	text := fmt.Sprintf("🌐 <b>Ваши сервисы (%d)</b>\n\nВыберите <code>API Service</code> для управления:", len(services))
-	markup := h.menu.BuildServicesList(services)
+	markup := h.menu.BuildServicesList(services, h.cmdHandler.unreachableServices(services))
 
 	if c.Callback() != nil {
 		return c.Edit(text, markup)
@@ -289,7 +511,16 @@ func (h *CallbackHandler) onViewMachine(c tele.Context, svcID uint, machineID st
 		text += fmt.Sprintf("\n\n⚠️ <b>Внимание:</b>\n%s", safeErr)
 	}
 
-	markup := h.menu.BuildMachineView(svcID, *machine)
+	subscribed, _ := h.watchUC.IsSubscribed(c.Sender().ID, svcID, machineID)
+	if subscribed {
+		text += "\n🔔 Уведомления о смене состояния включены"
+	}
+	muted, _ := h.notifyUC.IsMuted(c.Sender().ID, machineID)
+	if muted {
+		text += "\n🔇 Уведомления о станке заглушены"
+	}
+
+	markup := h.menu.BuildMachineView(svcID, *machine, subscribed, muted)
 
 	if c.Callback() != nil {
 		return c.Edit(text, markup)
@@ -297,6 +528,34 @@ func (h *CallbackHandler) onViewMachine(c tele.Context, svcID uint, machineID st
 	return c.Send(text, markup)
 }
 
+func (h *CallbackHandler) onMachineMute(c tele.Context, svcID uint, machineID string, mute bool) error {
+	var err error
+	if mute {
+		err = h.notifyUC.MuteMachine(c.Sender().ID, machineID, machineQuickMute)
+	} else {
+		err = h.notifyUC.UnmuteMachine(c.Sender().ID, machineID)
+	}
+	if err != nil {
+		c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error()})
+	} else if mute {
+		c.Respond(&tele.CallbackResponse{Text: "🔇 Заглушено на " + models.HumanDuration(machineQuickMute)})
+	} else {
+		c.Respond(&tele.CallbackResponse{Text: "🔊 Уведомления о станке включены"})
+	}
+	return h.onViewMachine(c, svcID, machineID)
+}
+
+func (h *CallbackHandler) onMachineSubscription(c tele.Context, svcID uint, machineID string, subscribe bool) error {
+	if err := h.watchUC.SetSubscription(c.Sender().ID, svcID, machineID, subscribe); err != nil {
+		c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error()})
+	} else if subscribe {
+		c.Respond(&tele.CallbackResponse{Text: "🔔 Уведомления включены"})
+	} else {
+		c.Respond(&tele.CallbackResponse{Text: "🔕 Уведомления выключены"})
+	}
+	return h.onViewMachine(c, svcID, machineID)
+}
+
 func (h *CallbackHandler) onAddConnectionStart(c tele.Context, svcID uint) error {
 	userID := c.Sender().ID
 	h.settingsUC.SetState(userID, entities.StateWaitingConnEndpoint)
@@ -326,6 +585,27 @@ func (h *CallbackHandler) onStartPollWizard(c tele.Context, svcID uint, machineI
 	return c.Edit("⏱ <b>Настройка опроса</b>\n\nВведите интервал опроса в миллисекундах (например, 5000):", h.menu.BuildCancel())
 }
 
+func (h *CallbackHandler) onBulkPollWizard(c tele.Context, svcID uint) error {
+	userID := c.Sender().ID
+	h.settingsUC.SetState(userID, entities.StateWaitingBulkPollInterval)
+	h.settingsUC.SetContextSvcID(userID, svcID)
+
+	return c.Edit("⏱ <b>Опрос всех станков</b>\n\nВведите интервал опроса в миллисекундах (например, 5000).\n"+
+		"Он будет установлен для всех станков сервиса.", h.menu.BuildCancel())
+}
+
+func (h *CallbackHandler) onBulkAction(c tele.Context, svcID uint, action string) error {
+	msg := c.Message()
+	if msg == nil {
+		return nil
+	}
+	if err := c.Edit(bulkTitle(action, 0) + "\n\n⏳ Получение списка станков..."); err != nil {
+		return err
+	}
+	go h.cmdHandler.runBulk(c.Bot(), msg, svcID, action, 0)
+	return nil
+}
+
 func (h *CallbackHandler) onStopPoll(c tele.Context, svcID uint, machineID string) error {
 	c.Notify(tele.Typing)
 	err := h.controlUC.StopPolling(context.Background(), svcID, machineID)
@@ -408,8 +688,38 @@ func (h *CallbackHandler) onViewTarget(c tele.Context, targetID uint) error {
 	safeBroker := html.EscapeString(t.Broker)
 	safeTopic := html.EscapeString(t.Topic)
 
-	text := fmt.Sprintf("📋 <b>Target: %s</b>\nBroker: <code>%s</code>\nTopic: <code>%s</code>\n\nВыберите ключ для мониторинга или действие:",
-		safeName, safeBroker, safeTopic)
+	auth := "нет"
+	if t.SASLMechanism != models.SASLNone {
+		auth = fmt.Sprintf("%s (%s)", t.SASLMechanism, html.EscapeString(t.SASLUsername))
+	}
+	tlsInfo := "выкл"
+	if t.TLSEnabled {
+		tlsInfo = "вкл"
+		if t.TLSCACert != "" {
+			tlsInfo += ", свой CA"
+		}
+		if t.TLSInsecure {
+			tlsInfo += ", без проверки сертификата"
+		}
+	}
+
+	text := fmt.Sprintf("📋 <b>Target: %s</b>\nBootstrap: <code>%s</code>\nTopic: <code>%s</code>\nSASL: %s\nTLS: %s\n",
+		safeName, safeBroker, safeTopic, auth, tlsInfo)
+
+	// Cluster metadata (short timeout: the view must open even if Kafka is down)
+	c.Notify(tele.Typing)
+	infoCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	info, errInfo := h.monitoringUC.GetClusterInfo(infoCtx, targetID)
+	cancel()
+
+	if errInfo != nil {
+		safeErr := html.EscapeString(errInfo.Error())
+		text += fmt.Sprintf("\n⚠️ <b>Кластер недоступен:</b>\n%s\n", safeErr)
+	} else {
+		text += "\n" + formatClusterInfo(info)
+	}
+
+	text += "\nВыберите ключ для мониторинга или действие:"
 	markup := h.menu.BuildTargetView(*t)
 
 	if c.Callback() != nil {
@@ -418,6 +728,40 @@ func (h *CallbackHandler) onViewTarget(c tele.Context, targetID uint) error {
 	return c.Send(text, markup)
 }
 
+// --- Topic Stats ---
+
+func (h *CallbackHandler) onTopicStats(c tele.Context, targetID uint) error {
+	h.stopUserLiveSession(c.Sender().ID)
+	h.settingsUC.SetState(c.Sender().ID, entities.StateIdle)
+
+	t, err := h.settingsUC.GetTargetByID(targetID)
+	if err != nil {
+		return h.onListTargets(c)
+	}
+
+	c.Notify(tele.Typing)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	stats, err := h.monitoringUC.GetTopicStats(ctx, targetID)
+	cancel()
+
+	title := fmt.Sprintf("📊 <b>Статистика: %s</b>\nTopic: <code>%s</code>\n", html.EscapeString(t.Name), html.EscapeString(t.Topic))
+	if err != nil {
+		safeErr := html.EscapeString(err.Error())
+		return c.Edit(title+fmt.Sprintf("\n❌ Ошибка:\n%s", safeErr), h.menu.BuildStatsView(targetID))
+	}
+
+	return c.Edit(title+formatTopicStats(stats, time.Now()), h.menu.BuildStatsView(targetID))
+}
+
+func (h *CallbackHandler) onConsumerGroupStart(c tele.Context, targetID uint) error {
+	userID := c.Sender().ID
+	h.settingsUC.SetContextTargetID(userID, targetID)
+	h.settingsUC.SetState(userID, entities.StateWaitingTargetGroup)
+
+	return c.Edit("👥 <b>Consumer group</b>\n\nВведите group ID, чей lag нужно показывать в статистике.\n"+
+		"Отправьте <code>-</code>, чтобы убрать.", h.menu.BuildCancel())
+}
+
 func (h *CallbackHandler) onDeleteTarget(c tele.Context, targetID uint) error {
 	h.settingsUC.DeleteTarget(c.Sender().ID, targetID)
 	c.Respond(&tele.CallbackResponse{Text: "✅ Target удален"})
@@ -429,8 +773,37 @@ func (h *CallbackHandler) onDeleteTarget(c tele.Context, targetID uint) error {
 func (h *CallbackHandler) onAddKeyStart(c tele.Context, targetID uint) error {
 	h.settingsUC.SetState(c.Sender().ID, entities.StateWaitingNewKey)
 	h.settingsUC.SetContextTargetID(c.Sender().ID, targetID)
+	h.settingsUC.SetDraftKeyMode(c.Sender().ID, models.KeyMatchExact)
+	h.settingsUC.SetDraftKeyHeader(c.Sender().ID, "")
 
-	return c.Edit("🔑 <b>Добавление ключа</b>\n\nВведите ключ (фильтр):", h.menu.BuildCancel())
+	return h.cmdHandler.promptNewKey(c, models.KeyMatchExact, "")
+}
+
+func (h *CallbackHandler) onKeyMode(c tele.Context, mode string) error {
+	if err := h.settingsUC.SetDraftKeyMode(c.Sender().ID, mode); err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "⚠️ " + err.Error()})
+	}
+	user, err := h.settingsUC.GetUser(c.Sender().ID)
+	if err != nil {
+		return err
+	}
+	return h.cmdHandler.promptNewKey(c, mode, user.DraftKeyHeader)
+}
+
+// onKeySource switches the new key between the record key and a header value
+func (h *CallbackHandler) onKeySource(c tele.Context, source string) error {
+	userID := c.Sender().ID
+	if source == "header" {
+		h.settingsUC.SetState(userID, entities.StateWaitingKeyHeader)
+		return c.Edit("🏷 <b>Фильтр по заголовку</b>\n\nВведите имя заголовка, например <code>machine_id</code>:", h.menu.BuildCancel())
+	}
+
+	h.settingsUC.SetDraftKeyHeader(userID, "")
+	user, err := h.settingsUC.GetUser(userID)
+	if err != nil {
+		return err
+	}
+	return h.cmdHandler.promptNewKey(c, user.DraftKeyMode, "")
 }
 
 func (h *CallbackHandler) onViewKey(c tele.Context, targetID, keyID uint) error {
@@ -448,6 +821,22 @@ func (h *CallbackHandler) onViewKey(c tele.Context, targetID, keyID uint) error
 			return h.onViewTarget(c, targetID)
 		}
 		text = fmt.Sprintf("🔑 <b>Ключ</b>: <code>%s</code>", html.EscapeString(key.Key))
+		if key.Header != "" {
+			text += fmt.Sprintf("\n🏷 По заголовку: <code>%s</code>", html.EscapeString(key.Header))
+		}
+		if key.MatchMode != "" && key.MatchMode != models.KeyMatchExact {
+			text += fmt.Sprintf("\nРежим: <code>%s</code>", key.MatchMode)
+		}
+		if key.Projection != "" {
+			text += fmt.Sprintf("\n🔍 Поля: <code>%s</code>", html.EscapeString(key.Projection))
+		}
+		if key.Predicate != "" {
+			text += fmt.Sprintf("\n🎯 Условие: <code>%s</code>", html.EscapeString(key.Predicate))
+		}
+	}
+
+	if watch, _ := h.watchdogUC.GetSilenceWatch(targetID, keyID); watch != nil {
+		text += "\n" + formatSilenceWatch(watch)
 	}
 
 	markup := h.menu.BuildKeyView(targetID, keyID)
@@ -466,7 +855,7 @@ func (h *CallbackHandler) onDeleteKey(c tele.Context, targetID, keyID uint) erro
 
 func (h *CallbackHandler) onCheckMessage(c tele.Context, targetID, keyID uint) error {
 	c.Notify(tele.Typing)
-	foundKey, msgRaw, err := h.monitoringUC.FetchLastKafkaMessage(context.Background(), targetID, keyID)
+	msg, err := h.monitoringUC.FetchLastKafkaMessage(context.Background(), targetID, keyID)
 
 	// Always go back to the key view (even if it's default)
 	backMarkup := h.menu.BuildKeyView(targetID, keyID)
@@ -476,22 +865,567 @@ func (h *CallbackHandler) onCheckMessage(c tele.Context, targetID, keyID uint) e
 		return c.Edit(fmt.Sprintf("❌ Ошибка:\n%s", safeErr), backMarkup)
 	}
 
-	prettyMsg := prettyPrintJSON(msgRaw)
-	if len(prettyMsg) > 3800 {
-		prettyMsg = prettyMsg[:3800] + "\n...[обрезано]"
-	}
-	safeMsg := html.EscapeString(prettyMsg)
-
 	// Format text
 	var textBuilder strings.Builder
-	if foundKey != "" {
-		textBuilder.WriteString(fmt.Sprintf("🔑 Ключ: <code>%s</code>\n", html.EscapeString(foundKey)))
+	if msg.Key != "" {
+		textBuilder.WriteString(fmt.Sprintf("🔑 Ключ: <code>%s</code>\n", html.EscapeString(msg.Key)))
 	}
-	textBuilder.WriteString(fmt.Sprintf("📨 Результат:\n<pre>%s</pre>", safeMsg))
+	textBuilder.WriteString(formatHeaders(msg.Headers))
+	textBuilder.WriteString("📨 Результат:\n")
+	textBuilder.WriteString(h.formatPayload(msg.Value))
 
 	return c.Edit(textBuilder.String(), backMarkup)
 }
 
+// --- History ---
+
+func (h *CallbackHandler) onHistory(c tele.Context, targetID, keyID uint, idx int, fresh bool) error {
+	backMarkup := h.menu.BuildKeyView(targetID, keyID)
+
+	history, err := h.historyWindow(c, targetID, keyID, fresh)
+	if err != nil {
+		safeErr := html.EscapeString(err.Error())
+		return c.Edit(fmt.Sprintf("❌ Ошибка:\n%s", safeErr), backMarkup)
+	}
+	if len(history) == 0 {
+		return c.Edit("⚠️ Сообщения не найдены", backMarkup)
+	}
+
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(history) {
+		idx = len(history) - 1
+	}
+	msg := history[idx]
+
+	prettyMsg := prettyPrintJSON(msg.Value)
+	if len(prettyMsg) > 3500 {
+		prettyMsg = prettyMsg[:3500] + "\n...[обрезано]"
+	}
+
+	var textBuilder strings.Builder
+	textBuilder.WriteString(fmt.Sprintf("📜 <b>История</b> [%d/%d]\n", idx+1, len(history)))
+	textBuilder.WriteString(formatMessageMeta(msg))
+	textBuilder.WriteString(fmt.Sprintf("<pre>%s</pre>", html.EscapeString(prettyMsg)))
+
+	return c.Edit(textBuilder.String(), h.menu.BuildHistoryView(targetID, keyID, idx, len(history)))
+}
+
+// historyWindow returns the history window of the message, reading Kafka only when the history is opened
+// or the window has expired, so pages do not shift while new messages arrive
+func (h *CallbackHandler) historyWindow(c tele.Context, targetID, keyID uint, fresh bool) ([]models.KafkaMessage, error) {
+	var key historyKey
+	if m := c.Message(); m != nil {
+		key = historyKey{chatID: m.Chat.ID, messageID: m.ID}
+	}
+	now := time.Now()
+
+	if val, ok := h.histories.Load(key); ok && !fresh {
+		w := val.(*historyWindow)
+		if w.targetID == targetID && w.keyID == keyID && now.Sub(w.fetchedAt) < historyCacheTTL {
+			return w.messages, nil
+		}
+	}
+
+	c.Notify(tele.Typing)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	history, err := h.monitoringUC.FetchHistory(ctx, targetID, keyID, historySize)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop windows of abandoned messages
+	h.histories.Range(func(k, val interface{}) bool {
+		if now.Sub(val.(*historyWindow).fetchedAt) >= historyCacheTTL {
+			h.histories.Delete(k)
+		}
+		return true
+	})
+	h.histories.Store(key, &historyWindow{targetID: targetID, keyID: keyID, messages: history, fetchedAt: now})
+	return history, nil
+}
+
+// --- Alert Rules ---
+
+func (h *CallbackHandler) onListRules(c tele.Context, targetID, keyID uint) error {
+	h.stopUserLiveSession(c.Sender().ID)
+	h.settingsUC.SetState(c.Sender().ID, entities.StateIdle)
+
+	rules, err := h.alertUC.GetRules(targetID, keyID)
+	if err != nil {
+		safeErr := html.EscapeString(err.Error())
+		return c.Edit("❌ Ошибка: "+safeErr, h.menu.BuildKeyView(targetID, keyID))
+	}
+
+	text := fmt.Sprintf("🔔 <b>Правила оповещений (%d)</b>\n\n"+
+		"Когда условие выполняется для нового сообщения, бот пришлет уведомление.", len(rules))
+	markup := h.menu.BuildRulesView(targetID, keyID, rules)
+
+	if c.Callback() != nil {
+		return c.Edit(text, markup)
+	}
+	return c.Send(text, markup)
+}
+
+func (h *CallbackHandler) onAddRuleStart(c tele.Context, targetID, keyID uint) error {
+	userID := c.Sender().ID
+	h.settingsUC.SetContextTargetID(userID, targetID)
+	h.settingsUC.SetContextKeyID(userID, keyID)
+	h.settingsUC.SetState(userID, entities.StateWaitingAlertRule)
+
+	return c.Edit("🔔 <b>Новое правило</b>\n\n"+
+		"Введите условие в формате фильтра ключа, при необходимости с длительностью <code>for</code>:\n"+
+		"• <code>data.spindle.load &gt; 120 for 30s</code>\n"+
+		"• <code>data.alarms not empty</code>\n"+
+		"• <code>data.mode == \"AUTO\" &amp;&amp; data.feed.rate == 0 for 5m</code>\n\n"+
+		"Проверки без значения: <code>empty</code>, <code>not empty</code>, <code>exists</code>, <code>not exists</code>.",
+		h.menu.BuildCancel())
+}
+
+func (h *CallbackHandler) onViewRule(c tele.Context, ruleID uint) error {
+	rule, err := h.alertUC.GetRule(ruleID)
+	if err != nil || rule.UserID != c.Sender().ID {
+		c.Respond(&tele.CallbackResponse{Text: "❌ Правило не найдено"})
+		return h.cmdHandler.OnStart(c)
+	}
+
+	status := "🟢 Включено"
+	if !rule.Enabled {
+		status = "⚪ Выключено"
+	}
+	text := fmt.Sprintf("🔔 <b>Правило</b>\n📏 <code>%s</code>\n%s\n🕒 Создано: %s",
+		html.EscapeString(rule.String()), status, rule.CreatedAt.Local().Format("2006-01-02 15:04"))
+
+	text += "\n📶 Важность: " + severityTitle(rule.Severity)
+	text += "\n\n🔁 Повтор: "
+	if rule.RenotifySec > 0 {
+		text += "каждые " + models.HumanDuration(time.Duration(rule.RenotifySec)*time.Second)
+	} else {
+		text += "выключен"
+	}
+	text += "\n📣 Эскалация: "
+	if rule.EscalateSec > 0 && rule.EscalateTo != "" {
+		text += fmt.Sprintf("через %s → <code>%s</code>",
+			models.HumanDuration(time.Duration(rule.EscalateSec)*time.Second), html.EscapeString(rule.EscalateTo))
+	} else {
+		text += "выключена"
+	}
+
+	return c.Edit(text, h.menu.BuildRuleView(*rule))
+}
+
+func (h *CallbackHandler) onToggleRule(c tele.Context, ruleID uint) error {
+	if err := h.alertUC.ToggleRule(c.Sender().ID, ruleID); err != nil {
+		c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error()})
+	}
+	return h.onViewRule(c, ruleID)
+}
+
+func (h *CallbackHandler) onDeleteRule(c tele.Context, ruleID uint) error {
+	rule, err := h.alertUC.GetRule(ruleID)
+	if err != nil {
+		return h.cmdHandler.OnStart(c)
+	}
+	if err := h.alertUC.DeleteRule(c.Sender().ID, ruleID); err != nil {
+		c.Respond(&tele.CallbackResponse{Text: "❌ Ошибка удаления правила"})
+	} else {
+		c.Respond(&tele.CallbackResponse{Text: "✅ Правило удалено"})
+	}
+	return h.onListRules(c, rule.TargetID, rule.KeyID)
+}
+
+func (h *CallbackHandler) onRuleRenotifyStart(c tele.Context, ruleID uint) error {
+	userID := c.Sender().ID
+	h.settingsUC.SetContextRuleID(userID, ruleID)
+	h.settingsUC.SetState(userID, entities.StateWaitingRuleRenotify)
+
+	return c.Edit("🔁 <b>Повтор оповещения</b>\n\n"+
+		"Пока оповещение никто не принял, бот будет повторять его с этим интервалом.\n\n"+
+		"Введите интервал: <code>10m</code>, <code>30m</code>, <code>1h</code>.\n"+
+		"Отправьте <code>-</code>, чтобы выключить повторы.",
+		h.menu.BuildCancel())
+}
+
+func (h *CallbackHandler) onRuleEscalationStart(c tele.Context, ruleID uint) error {
+	userID := c.Sender().ID
+	h.settingsUC.SetContextRuleID(userID, ruleID)
+	h.settingsUC.SetState(userID, entities.StateWaitingRuleEscalation)
+
+	return c.Edit("📣 <b>Эскалация</b>\n\n"+
+		"Если оповещение не принято за указанное время, оно уйдет другим пользователям. "+
+		"Получатели должны хотя бы раз запустить бота (/start), свой ID они видят в /profile.\n\n"+
+		"Введите задержку и Telegram ID через запятую:\n"+
+		"<code>30m 123456789, 987654321</code>\n"+
+		"Отправьте <code>-</code>, чтобы выключить эскалацию.",
+		h.menu.BuildCancel())
+}
+
+func (h *CallbackHandler) onCycleRuleSeverity(c tele.Context, ruleID uint) error {
+	rule, err := h.alertUC.GetRule(ruleID)
+	if err != nil {
+		return h.cmdHandler.OnStart(c)
+	}
+	next := nextSeverity(rule.Severity)
+	if err := h.alertUC.SetRuleSeverity(c.Sender().ID, ruleID, next); err != nil {
+		c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error()})
+	}
+	return h.onViewRule(c, ruleID)
+}
+
+// --- Alerts ---
+
+// onAlertAction updates the notification in place; a digest holds other notifications too,
+// so the updated alert is sent as a separate message instead
+func (h *CallbackHandler) onAlertAction(c tele.Context, alertID uint, resolve, digest bool) error {
+	alert, err := h.changeAlert(c, alertID, resolve)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "⚠️ " + err.Error(), ShowAlert: true})
+	}
+
+	events, _ := h.alertUC.GetAlertEvents(alertID)
+	text := alert.Text + "\n\n" + formatAlertEvents(events)
+	if digest {
+		toast := "👀 Принято"
+		if resolve {
+			toast = "✅ Решено"
+		}
+		c.Respond(&tele.CallbackResponse{Text: toast})
+		return c.Send(text, h.menu.BuildAlertActions(*alert))
+	}
+	return c.Edit(text, h.menu.BuildAlertActions(*alert))
+}
+
+// changeAlert acknowledges or resolves the alert on behalf of the sender
+func (h *CallbackHandler) changeAlert(c tele.Context, alertID uint, resolve bool) (*entities.Alert, error) {
+	sender := c.Sender()
+	name := sender.FirstName
+	if sender.Username != "" {
+		name += " (@" + sender.Username + ")"
+	}
+
+	if resolve {
+		return h.alertUC.Resolve(sender.ID, name, alertID)
+	}
+	return h.alertUC.Acknowledge(sender.ID, name, alertID)
+}
+
+func (h *CallbackHandler) onViewAlert(c tele.Context, alertID uint, page int) error {
+	alert, err := h.alertUC.GetAlert(c.Sender().ID, alertID)
+	if err != nil {
+		c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error()})
+		return h.cmdHandler.showAlerts(c, page)
+	}
+
+	var b strings.Builder
+	b.WriteString(alert.Text)
+	if alert.TargetID == nil {
+		b.WriteString(fmt.Sprintf("\n\n🗑 Топик «%s» удален", html.EscapeString(alert.TargetName)))
+	}
+	b.WriteString(fmt.Sprintf("\n\nСтатус: %s", alertStatusTitle(alert.Status)))
+	b.WriteString(fmt.Sprintf("\n🔔 Уведомлений: %d", alert.NotifyCount))
+	if events, _ := h.alertUC.GetAlertEvents(alertID); len(events) > 0 {
+		if history := formatAlertEvents(events); history != "" {
+			b.WriteString("\n" + history)
+		}
+	}
+
+	return c.Edit(b.String(), h.menu.BuildAlertView(*alert, page))
+}
+
+func (h *CallbackHandler) onAlertMachineFilter(c tele.Context) error {
+	user, err := h.settingsUC.GetUser(c.Sender().ID)
+	if err != nil {
+		return h.cmdHandler.OnStart(c)
+	}
+	machines, err := h.alertUC.GetAlertMachines(user.ID)
+	if err != nil {
+		safeErr := html.EscapeString(err.Error())
+		return c.Edit("❌ Ошибка: "+safeErr, h.menu.BuildMainMenu())
+	}
+	return c.Edit("🏭 <b>Фильтр по станку</b>\n\nПоказывать оповещения станка:",
+		h.menu.BuildAlertMachines(machines, user.AlertFilterMachine))
+}
+
+// formatAlertEvents lists who handled the alert and when
+func formatAlertEvents(events []entities.AlertEvent) string {
+	var lines []string
+	for _, e := range events {
+		at := e.CreatedAt.Local().Format("15:04")
+		switch e.Action {
+		case entities.AlertActionEscalated:
+			lines = append(lines, "📣 Эскалация · "+at)
+		case entities.AlertActionAcked:
+			lines = append(lines, fmt.Sprintf("👀 Принял: %s · %s", html.EscapeString(e.UserName), at))
+		case entities.AlertActionResolved:
+			lines = append(lines, fmt.Sprintf("✅ Решил: %s · %s", html.EscapeString(e.UserName), at))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// --- Notification Preferences ---
+
+// Быстрое заглушение станка с экрана станка
+const machineQuickMute = 2 * time.Hour
+
+// Варианты интервала сводки, переключаются по кругу (0 = уведомления сразу)
+var digestIntervals = []time.Duration{0, 30 * time.Minute, time.Hour, 4 * time.Hour, 8 * time.Hour}
+
+func (h *CallbackHandler) onPreferenceInput(c tele.Context, state, prompt string) error {
+	h.settingsUC.SetState(c.Sender().ID, state)
+	return c.Edit(prompt, h.menu.BuildCancel())
+}
+
+func (h *CallbackHandler) onCycleMinSeverity(c tele.Context) error {
+	userID := c.Sender().ID
+	settings, err := h.notifyUC.GetSettings(userID)
+	if err != nil {
+		return h.cmdHandler.OnStart(c)
+	}
+	if err := h.notifyUC.SetMinSeverity(userID, nextSeverity(settings.MinSeverity)); err != nil {
+		c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error()})
+	}
+	return h.cmdHandler.showPreferences(c)
+}
+
+func (h *CallbackHandler) onCycleDigest(c tele.Context) error {
+	userID := c.Sender().ID
+	settings, err := h.notifyUC.GetSettings(userID)
+	if err != nil {
+		return h.cmdHandler.OnStart(c)
+	}
+
+	current := time.Duration(settings.DigestMinutes) * time.Minute
+	next := digestIntervals[0]
+	for i, d := range digestIntervals {
+		if d == current {
+			next = digestIntervals[(i+1)%len(digestIntervals)]
+			break
+		}
+	}
+	if err := h.notifyUC.SetDigestInterval(userID, next); err != nil {
+		c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error()})
+	}
+	return h.cmdHandler.showPreferences(c)
+}
+
+func (h *CallbackHandler) onToggleReportCSV(c tele.Context) error {
+	userID := c.Sender().ID
+	settings, err := h.notifyUC.GetSettings(userID)
+	if err != nil {
+		return h.cmdHandler.OnStart(c)
+	}
+	if err := h.reportUC.SetReportCSV(userID, !settings.ReportCSV); err != nil {
+		c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error()})
+	}
+	return h.cmdHandler.showReports(c)
+}
+
+func (h *CallbackHandler) onSendReportNow(c tele.Context) error {
+	if err := h.reportUC.SendReportNow(context.Background(), c.Sender().ID); err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+	}
+	return c.Respond(&tele.CallbackResponse{Text: "📤 Отчет отправлен"})
+}
+
+// nextSeverity cycles info → warning → critical → info
+func nextSeverity(severity string) string {
+	return models.Severities[(models.SeverityLevel(severity)+1)%len(models.Severities)]
+}
+
+// --- Silence Watchdog ---
+
+func (h *CallbackHandler) onMaxSilenceStart(c tele.Context, targetID, keyID uint) error {
+	userID := c.Sender().ID
+	h.stopUserLiveSession(userID)
+	h.settingsUC.SetContextTargetID(userID, targetID)
+	h.settingsUC.SetContextKeyID(userID, keyID)
+	h.settingsUC.SetState(userID, entities.StateWaitingMaxSilence)
+
+	current := "не задана"
+	if watch, _ := h.watchdogUC.GetSilenceWatch(targetID, keyID); watch != nil {
+		current = models.HumanDuration(time.Duration(watch.MaxSilenceSec) * time.Second)
+	}
+
+	return c.Edit(fmt.Sprintf("🔇 <b>Максимальная тишина</b>\nСейчас: %s\n\n"+
+		"Если новых сообщений не будет дольше этого времени, бот пришлет оповещение, "+
+		"а когда данные снова появятся - уведомление о восстановлении.\n\n"+
+		"Введите паузу: <code>5m</code>, <code>30m</code>, <code>2h</code>.\n"+
+		"Отправьте <code>-</code>, чтобы выключить контроль.", current),
+		h.menu.BuildCancel())
+}
+
+// formatSilenceWatch describes the watchdog settings and state on the key view
+func formatSilenceWatch(w *entities.SilenceWatch) string {
+	text := fmt.Sprintf("🔇 Макс. тишина: %s", models.HumanDuration(time.Duration(w.MaxSilenceSec)*time.Second))
+	if w.Silent && w.SilentSince != nil {
+		text += fmt.Sprintf(" · 🔴 нет данных %s", models.HumanDuration(time.Since(*w.SilentSince)))
+	}
+	return text
+}
+
+// --- JSON Filters ---
+
+func (h *CallbackHandler) onKeyFilterStart(c tele.Context, targetID, keyID uint, isPredicate bool) error {
+	userID := c.Sender().ID
+	h.stopUserLiveSession(userID)
+	h.settingsUC.SetContextTargetID(userID, targetID)
+	h.settingsUC.SetContextKeyID(userID, keyID)
+
+	if isPredicate {
+		h.settingsUC.SetState(userID, entities.StateWaitingKeyPredicate)
+		return c.Edit("🎯 <b>Условие</b>\n\nБудут показаны только сообщения, удовлетворяющие условию.\n"+
+			"Операторы: <code>== != &gt; &gt;= &lt; &lt;=</code>, <code>empty</code>, <code>not empty</code>, объединение через <code>&amp;&amp;</code>.\n"+
+			"Пример: <code>data.mode == \"AUTO\" &amp;&amp; data.spindle.speed &gt; 0</code>\n\n"+
+			"Отправьте <code>-</code>, чтобы убрать условие.", h.menu.BuildCancel())
+	}
+
+	h.settingsUC.SetState(userID, entities.StateWaitingKeyProjection)
+	return c.Edit("🔍 <b>Поля</b>\n\nУкажите пути к полям через запятую, остальное будет скрыто.\n"+
+		"Пример: <code>data.spindle.speed, data.alarms[*]</code>\n\n"+
+		"Отправьте <code>-</code>, чтобы показывать сообщение целиком.", h.menu.BuildCancel())
+}
+
+// --- Export ---
+
+func (h *CallbackHandler) onExportStart(c tele.Context, targetID, keyID uint) error {
+	h.stopUserLiveSession(c.Sender().ID)
+	h.settingsUC.SetState(c.Sender().ID, entities.StateIdle)
+	return c.Edit("📤 <b>Экспорт сообщений</b>\n\nВыберите диапазон:", h.menu.BuildExportRanges(targetID, keyID))
+}
+
+func (h *CallbackHandler) onExportWindowStart(c tele.Context, targetID, keyID uint) error {
+	userID := c.Sender().ID
+	h.settingsUC.SetContextTargetID(userID, targetID)
+	h.settingsUC.SetContextKeyID(userID, keyID)
+	h.settingsUC.SetState(userID, entities.StateWaitingExportWindow)
+
+	return c.Edit("📤 <b>Экспорт: свой интервал</b>\n\nВведите начало и конец через <code> - </code>, например\n"+
+		"<code>вчера 14:00 - вчера 16:30</code>\n"+seekTimeHint, h.menu.BuildCancel())
+}
+
+func (h *CallbackHandler) onExportRange(c tele.Context, targetID, keyID uint, rangeCode string) error {
+	_, desc, err := parseExportRange(rangeCode, time.Now())
+	if err != nil {
+		return h.onExportStart(c, targetID, keyID)
+	}
+	return c.Edit(fmt.Sprintf("📤 <b>Экспорт</b>: %s\n\nВыберите формат:", desc), h.menu.BuildExportFormats(targetID, keyID, rangeCode))
+}
+
+func (h *CallbackHandler) onExportRun(c tele.Context, targetID, keyID uint, rangeCode, format string) error {
+	rng, desc, err := parseExportRange(rangeCode, time.Now())
+	if err != nil {
+		return h.onExportStart(c, targetID, keyID)
+	}
+
+	c.Edit(fmt.Sprintf("📤 <b>Экспорт</b>: %s\n⏳ Чтение сообщений...", desc))
+	c.Notify(tele.UploadingDocument)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	file, err := h.monitoringUC.ExportMessages(ctx, targetID, keyID, rng, format)
+	cancel()
+
+	if err != nil {
+		safeErr := html.EscapeString(err.Error())
+		return c.Edit(fmt.Sprintf("❌ Ошибка:\n%s", safeErr), h.menu.BuildExportRanges(targetID, keyID))
+	}
+	if file.Count == 0 {
+		return c.Edit(fmt.Sprintf("⚠️ Сообщений не найдено: %s", desc), h.menu.BuildExportRanges(targetID, keyID))
+	}
+
+	caption := fmt.Sprintf("📤 Экспорт: %s\nСообщений: %d", desc, file.Count)
+	if file.Compressed {
+		caption += " (gzip)"
+	}
+	if file.Truncated {
+		caption += "\n⚠️ Достигнут лимит, файл неполный"
+	}
+
+	doc := &tele.Document{
+		File:     tele.FromReader(bytes.NewReader(file.Data)),
+		FileName: file.Name,
+		Caption:  caption,
+		MIME:     file.MIME,
+	}
+
+	if err := c.Send(doc); err != nil {
+		return c.Edit("❌ Не удалось отправить файл: "+html.EscapeString(err.Error()), h.menu.BuildExportRanges(targetID, keyID))
+	}
+
+	return h.onViewKey(c, targetID, keyID)
+}
+
+// parseExportRange decodes the range code of export buttons into a range and its description
+func parseExportRange(code string, now time.Time) (models.ExportRange, string, error) {
+	if len(code) < 2 {
+		return models.ExportRange{}, "", fmt.Errorf("invalid range")
+	}
+
+	switch code[0] {
+	case 'n':
+		n, err := strconv.Atoi(code[1:])
+		if err != nil || n <= 0 {
+			return models.ExportRange{}, "", fmt.Errorf("invalid range")
+		}
+		return models.ExportRange{Last: n}, fmt.Sprintf("последние %d сообщений", n), nil
+
+	case 'h':
+		hours, err := strconv.Atoi(code[1:])
+		if err != nil || hours <= 0 {
+			return models.ExportRange{}, "", fmt.Errorf("invalid range")
+		}
+		return models.ExportRange{From: now.Add(-time.Duration(hours) * time.Hour), To: now},
+			fmt.Sprintf("за последние %d ч", hours), nil
+
+	case 'w':
+		from, to, ok := strings.Cut(code[1:], "-")
+		fromSec, errFrom := strconv.ParseInt(from, 10, 64)
+		toSec, errTo := strconv.ParseInt(to, 10, 64)
+		if !ok || errFrom != nil || errTo != nil || fromSec >= toSec {
+			return models.ExportRange{}, "", fmt.Errorf("invalid range")
+		}
+		rng := models.ExportRange{From: time.Unix(fromSec, 0), To: time.Unix(toSec, 0)}
+		return rng, fmt.Sprintf("%s — %s", rng.From.Format("2006-01-02 15:04:05"), rng.To.Format("2006-01-02 15:04:05")), nil
+	}
+
+	return models.ExportRange{}, "", fmt.Errorf("invalid range")
+}
+
+// --- Seek by Timestamp ---
+
+func (h *CallbackHandler) onSeekStart(c tele.Context, targetID, keyID uint) error {
+	userID := c.Sender().ID
+	h.stopUserLiveSession(userID)
+	h.settingsUC.SetContextTargetID(userID, targetID)
+	h.settingsUC.SetContextKeyID(userID, keyID)
+	h.settingsUC.SetState(userID, entities.StateWaitingSeekTime)
+
+	return c.Edit("🕒 <b>Поиск по времени</b>\n\nВведите дату и время, будет показано ближайшее сообщение.\n"+seekTimeHint, h.menu.BuildCancel())
+}
+
+func (h *CallbackHandler) onSeekNeighbour(c tele.Context, targetID, keyID uint, pos models.MessagePosition, newer bool) error {
+	c.Notify(tele.Typing)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	msg, err := h.monitoringUC.FetchNeighbour(ctx, targetID, keyID, pos, newer)
+	cancel()
+
+	if err != nil {
+		safeErr := html.EscapeString(err.Error())
+		return c.Edit(fmt.Sprintf("❌ Ошибка:\n%s", safeErr), h.menu.BuildKeyView(targetID, keyID))
+	}
+	if msg == nil {
+		text := "⚠️ Более ранних сообщений нет"
+		if newer {
+			text = "⚠️ Более новых сообщений нет"
+		}
+		return c.Respond(&tele.CallbackResponse{Text: text})
+	}
+
+	return c.Edit(formatSeekResult(*msg, time.Time{}), h.menu.BuildSeekView(targetID, keyID, msg.Position()))
+}
+
 // --- Live Mode ---
 
 func (h *CallbackHandler) onLiveModeStart(c tele.Context, targetID, keyID uint) error {
@@ -509,7 +1443,7 @@ func (h *CallbackHandler) onLiveModeStart(c tele.Context, targetID, keyID uint)
 	if keyID > 0 {
 		k, _ := h.settingsUC.GetKeyByID(keyID)
 		if k != nil {
-			title += fmt.Sprintf(" [%s]", html.EscapeString(k.Key))
+			title += fmt.Sprintf(" [%s]", html.EscapeString(models.KeyFilter{Mode: k.MatchMode, Pattern: k.Key, Header: k.Header}.String()))
 		}
 	} else {
 		title += " [Default]"
@@ -528,37 +1462,34 @@ func (h *CallbackHandler) onStopLive(c tele.Context, targetID, keyID uint) error
 }
 
 func (h *CallbackHandler) runLiveUpdateLoop(ctx context.Context, c tele.Context, targetID, keyID uint, title string) {
-	ticker := time.NewTicker(1500 * time.Millisecond)
-	defer ticker.Stop()
-	var lastContent string
+	// Subscribe before the initial read so no message is lost in between
+	updates, unsubscribe := h.monitoringUC.Subscribe(targetID, keyID)
+	defer unsubscribe()
 
-	update := func() {
-		fetchCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		_, msgRaw, err := h.monitoringUC.FetchLastKafkaMessage(fetchCtx, targetID, keyID)
-		cancel()
-		if ctx.Err() != nil {
-			return
-		}
+	var lastContent string
+	var lastEdit time.Time
 
-		timestamp := time.Now().Format("15:04:05")
+	render := func(msg models.KafkaMessage, updatedAt time.Time, err error) {
+		timestamp := updatedAt.Format("15:04:05")
 		var textBuilder strings.Builder
 		textBuilder.WriteString(fmt.Sprintf("🔴 <b>%s</b>\nОбновлено: %s\n", title, timestamp))
+		if err == nil {
+			if msg.Key != "" {
+				textBuilder.WriteString(fmt.Sprintf("🔑 Ключ: <code>%s</code>\n", html.EscapeString(msg.Key)))
+			}
+			textBuilder.WriteString(formatHeaders(msg.Headers))
+		}
 
 		if err != nil {
 			safeErr := html.EscapeString(err.Error())
 			textBuilder.WriteString(fmt.Sprintf("❌ %s", safeErr))
 		} else {
-
-			p := prettyPrintJSON(msgRaw)
-			if len(p) > 3500 {
-				p = p[:3500] + "..."
-			}
-			safeP := html.EscapeString(p)
-			textBuilder.WriteString(fmt.Sprintf("<pre>%s</pre>", safeP))
+			textBuilder.WriteString(h.formatPayload(msg.Value))
 		}
 
 		text := textBuilder.String()
 		if text != lastContent {
+			lastEdit = time.Now()
 			if err := c.Edit(text, h.menu.BuildLiveView(targetID, keyID)); err != nil {
 				h.stopUserLiveSession(c.Sender().ID)
 			} else {
@@ -566,13 +1497,55 @@ func (h *CallbackHandler) runLiveUpdateLoop(ctx context.Context, c tele.Context,
 			}
 		}
 	}
-	update()
+
+	// 1. Initial state: latest known message
+	fetchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	msg, err := h.monitoringUC.FetchLastKafkaMessage(fetchCtx, targetID, keyID)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+	render(msg, time.Now(), err)
+
+	// 2. Push updates with debouncing: Telegram limits how often a message can be edited
+	debounce := time.NewTimer(liveEditInterval)
+	debounce.Stop()
+	var pending *models.KafkaMessage
+
+	flush := func() {
+		if pending == nil {
+			return
+		}
+		updatedAt := pending.Time
+		if updatedAt.IsZero() {
+			updatedAt = time.Now()
+		}
+		render(*pending, updatedAt, nil)
+		pending = nil
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
+			debounce.Stop()
 			return
-		case <-ticker.C:
-			update()
+		case msg := <-updates:
+			waiting := pending != nil
+			pending = &msg
+			if waiting {
+				// Timer is already armed, it will publish the newest message
+				continue
+			}
+			if wait := liveEditInterval - time.Since(lastEdit); wait > 0 {
+				debounce.Reset(wait)
+				continue
+			}
+			flush()
+		case <-debounce.C:
+			if ctx.Err() != nil {
+				return
+			}
+			flush()
 		}
 	}
 }
@@ -586,14 +1559,312 @@ func (h *CallbackHandler) stopUserLiveSession(userID int64) {
 
 func (h *CallbackHandler) onAddTargetStart(c tele.Context) error {
 	h.settingsUC.SetState(c.Sender().ID, entities.StateWaitingName)
-	return c.Edit("🖊 <b>Шаг 1/3: Имя Kafka Target</b>\nВведите имя:", h.menu.BuildCancel())
+	return c.Edit("🖊 <b>Шаг 1/5: Имя Kafka Target</b>\nВведите имя:", h.menu.BuildCancel())
+}
+
+func (h *CallbackHandler) onAuthMechanism(c tele.Context, mechanism string) error {
+	if mechanism == "none" {
+		mechanism = models.SASLNone
+	}
+	if err := h.settingsUC.SetDraftAuthMechanism(c.Sender().ID, mechanism); err != nil {
+		c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error()})
+		return h.cmdHandler.promptAuthMechanism(c)
+	}
+	if mechanism == models.SASLNone {
+		return h.cmdHandler.promptTLS(c)
+	}
+	return h.cmdHandler.promptAuthUser(c)
+}
+
+func (h *CallbackHandler) onTLSOption(c tele.Context, option string) error {
+	enabled := option == "on" || option == "insecure"
+	insecure := option == "insecure"
+	if err := h.settingsUC.SetDraftTLS(c.Sender().ID, enabled, insecure, ""); err != nil {
+		c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error()})
+		return h.cmdHandler.promptTLS(c)
+	}
+	return h.cmdHandler.promptTopic(c)
+}
+
+func (h *CallbackHandler) onPickTopic(c tele.Context, idx int) error {
+	userID := c.Sender().ID
+	// Stale button of a finished wizard must not create a duplicate target
+	if user, err := h.settingsUC.GetUser(userID); err != nil || user.State != entities.StateWaitingTopic {
+		return h.onListTargets(c)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// Resolve the index against a fresh listing: the list is sorted by name
+	topics, err := h.settingsUC.ListDraftTopics(ctx, userID)
+	if err != nil || idx < 0 || idx >= len(topics) {
+		return h.cmdHandler.promptTopic(c)
+	}
+
+	if err := h.settingsUC.SetDraftTopicAndSave(ctx, userID, topics[idx].Name); err != nil {
+		safeErr := html.EscapeString(err.Error())
+		return c.Edit("⚠️ "+safeErr, h.menu.BuildTopics(topics))
+	}
+
+	c.Respond(&tele.CallbackResponse{Text: "✅ Kafka Target сохранен!"})
+	return h.onListTargets(c)
 }
 
 func (h *CallbackHandler) onCancelWizard(c tele.Context) error {
-	h.settingsUC.SetState(c.Sender().ID, entities.StateIdle)
+	h.settingsUC.CancelWizard(c.Sender().ID)
 	return h.cmdHandler.OnStart(c)
 }
 
+// formatMessageMeta describes where the record lives: partition, offset, timestamp and key
+func formatMessageMeta(m models.KafkaMessage) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("🧩 Partition: <code>%d</code> · Offset: <code>%d</code>\n", m.Partition, m.Offset))
+	if !m.Time.IsZero() {
+		b.WriteString(fmt.Sprintf("🕒 %s\n", m.Time.Local().Format("2006-01-02 15:04:05.000")))
+	}
+	if m.Key != "" {
+		b.WriteString(fmt.Sprintf("🔑 Ключ: <code>%s</code>\n", html.EscapeString(m.Key)))
+	}
+	b.WriteString(formatHeaders(m.Headers))
+	return b.String()
+}
+
+// formatPayload renders fanucService telemetry as a status card and anything else as raw JSON
+func (h *CallbackHandler) formatPayload(value string) string {
+	if st, ok := h.monitoringUC.DecodeStatus(value); ok {
+		return formatStatusCard(st)
+	}
+
+	prettyMsg := prettyPrintJSON(value)
+	if len(prettyMsg) > 3500 {
+		prettyMsg = prettyMsg[:3500] + "\n...[обрезано]"
+	}
+	return fmt.Sprintf("<pre>%s</pre>", html.EscapeString(prettyMsg))
+}
+
+// Максимум аварий в карточке состояния
+const maxCardAlarms = 10
+
+func formatStatusCard(st *models.MachineStatus) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("🏭 <b>%s</b>\n", html.EscapeString(st.MachineID)))
+
+	if st.RunMode != "" || st.RunState != "" {
+		parts := make([]string, 0, 2)
+		if st.RunMode != "" {
+			parts = append(parts, "<code>"+html.EscapeString(st.RunMode)+"</code>")
+		}
+		if st.RunState != "" {
+			parts = append(parts, runStateIcon(st.RunState)+" "+html.EscapeString(st.RunState))
+		}
+		b.WriteString("⚙️ Режим: " + strings.Join(parts, " · ") + "\n")
+	}
+
+	if st.ProgramNumber != "" || st.ProgramName != "" {
+		program := st.ProgramNumber
+		if program != "" && !strings.HasPrefix(strings.ToUpper(program), "O") {
+			program = "O" + program
+		}
+		if st.ProgramName != "" {
+			if program != "" {
+				program += " "
+			}
+			program += "(" + st.ProgramName + ")"
+		}
+		b.WriteString(fmt.Sprintf("📄 Программа: <code>%s</code>\n", html.EscapeString(program)))
+	}
+
+	if st.SpindleSpeed != nil || st.SpindleLoad != nil {
+		parts := make([]string, 0, 2)
+		if st.SpindleSpeed != nil {
+			parts = append(parts, fmt.Sprintf("%s об/мин", formatNumber(*st.SpindleSpeed)))
+		}
+		if st.SpindleLoad != nil {
+			parts = append(parts, fmt.Sprintf("нагрузка %s%%", formatNumber(*st.SpindleLoad)))
+		}
+		b.WriteString("🌀 Шпиндель: " + strings.Join(parts, " · ") + "\n")
+	}
+
+	if st.FeedRate != nil || st.FeedOverride != nil {
+		parts := make([]string, 0, 2)
+		if st.FeedRate != nil {
+			parts = append(parts, fmt.Sprintf("%s мм/мин", formatNumber(*st.FeedRate)))
+		}
+		if st.FeedOverride != nil {
+			parts = append(parts, fmt.Sprintf("коррекция %s%%", formatNumber(*st.FeedOverride)))
+		}
+		b.WriteString("➡️ Подача: " + strings.Join(parts, " · ") + "\n")
+	}
+
+	if st.PartCount != nil {
+		b.WriteString(fmt.Sprintf("🔢 Детали: <b>%d</b>\n", *st.PartCount))
+	}
+
+	if st.HasAlarms {
+		if len(st.Alarms) == 0 {
+			b.WriteString("✅ Аварий нет\n")
+		} else {
+			b.WriteString(fmt.Sprintf("🚨 <b>Аварии (%d):</b>\n", len(st.Alarms)))
+			for i, a := range st.Alarms {
+				if i == maxCardAlarms {
+					b.WriteString(fmt.Sprintf("  … и ещё %d\n", len(st.Alarms)-maxCardAlarms))
+					break
+				}
+				line := a.Message
+				if a.Code != "" {
+					line = a.Code
+					if a.Message != "" {
+						line += ": " + a.Message
+					}
+				}
+				b.WriteString("  • " + html.EscapeString(line) + "\n")
+			}
+		}
+	}
+
+	if !st.Time.IsZero() {
+		b.WriteString(fmt.Sprintf("🕒 %s\n", st.Time.Local().Format("2006-01-02 15:04:05")))
+	}
+	return b.String()
+}
+
+func runStateIcon(state string) string {
+	switch strings.ToUpper(state) {
+	case "START", "RUN", "RUNNING", "STRT":
+		return "▶️"
+	case "HOLD", "HOLDING", "HLD", "PAUSE":
+		return "⏸"
+	case "STOP", "STOPPED", "RESET":
+		return "⏹"
+	}
+	return "•"
+}
+
+// formatNumber prints integers without a fractional part and other values with one decimal
+func formatNumber(v float64) string {
+	if v == float64(int64(v)) {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+// Ограничения на вывод заголовков записи
+const (
+	maxHeaderLines  = 10
+	maxHeaderLength = 100
+)
+
+func formatHeaders(headers []models.Header) string {
+	var b strings.Builder
+	for i, h := range headers {
+		if i == maxHeaderLines {
+			b.WriteString(fmt.Sprintf("🏷 … и ещё %d\n", len(headers)-maxHeaderLines))
+			break
+		}
+		value := h.Value
+		if runes := []rune(value); len(runes) > maxHeaderLength {
+			value = string(runes[:maxHeaderLength]) + "…"
+		}
+		b.WriteString(fmt.Sprintf("🏷 %s: <code>%s</code>\n", html.EscapeString(h.Key), html.EscapeString(value)))
+	}
+	return b.String()
+}
+
+// formatSeekResult renders a message found by time; at is the requested time (zero when navigating)
+func formatSeekResult(m models.KafkaMessage, at time.Time) string {
+	prettyMsg := prettyPrintJSON(m.Value)
+	if len(prettyMsg) > 3500 {
+		prettyMsg = prettyMsg[:3500] + "\n...[обрезано]"
+	}
+
+	var b strings.Builder
+	b.WriteString("🕒 <b>Поиск по времени</b>\n")
+	if !at.IsZero() {
+		delta := m.Time.Sub(at).Round(time.Millisecond)
+		sign := "+"
+		if delta < 0 {
+			sign, delta = "-", -delta
+		}
+		b.WriteString(fmt.Sprintf("Запрошено: %s (Δ %s%s)\n", at.Format("2006-01-02 15:04:05"), sign, delta))
+	}
+	b.WriteString(formatMessageMeta(m))
+	b.WriteString(fmt.Sprintf("<pre>%s</pre>", html.EscapeString(prettyMsg)))
+	return b.String()
+}
+
+func formatTopicStats(stats *models.TopicStats, now time.Time) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("\n📦 Сообщений в хранении: <b>%d</b>\n", stats.Total))
+	b.WriteString(fmt.Sprintf("⚡ Скорость: <b>%.1f</b> сообщ./мин (за %s)\n", stats.PerMinute, models.HumanDuration(stats.Window)))
+	if stats.NewestTime.IsZero() {
+		b.WriteString("🕒 Последняя запись: нет\n")
+	} else {
+		age := now.Sub(stats.NewestTime)
+		icon := "🟢"
+		if age > stats.Window {
+			icon = "🔴"
+		}
+		b.WriteString(fmt.Sprintf("%s Последняя запись: %s назад (%s)\n", icon, models.HumanDuration(age), stats.NewestTime.Local().Format("2006-01-02 15:04:05")))
+	}
+
+	if stats.Group != "" {
+		safeGroup := html.EscapeString(stats.Group)
+		if stats.GroupError != "" {
+			b.WriteString(fmt.Sprintf("👥 Группа <code>%s</code>: ⚠️ %s\n", safeGroup, html.EscapeString(stats.GroupError)))
+		} else {
+			b.WriteString(fmt.Sprintf("👥 Группа <code>%s</code>: lag <b>%d</b>\n", safeGroup, stats.TotalLag))
+		}
+	}
+
+	b.WriteString(fmt.Sprintf("\n🧩 <b>Партиции (%d):</b>\n", len(stats.Partitions)))
+	for i, p := range stats.Partitions {
+		if i == maxPartitionLines {
+			b.WriteString(fmt.Sprintf("… и ещё %d\n", len(stats.Partitions)-maxPartitionLines))
+			break
+		}
+		line := fmt.Sprintf("P%d: %d…%d (%d), %.1f/мин", p.Partition, p.First, p.Last, p.Last-p.First,
+			float64(p.RecentCount)/stats.Window.Minutes())
+		if lag, ok := stats.Lag[p.Partition]; ok {
+			line += fmt.Sprintf(", lag %d", lag)
+		} else if stats.Lag != nil {
+			line += ", lag —"
+		}
+		b.WriteString(line + "\n")
+	}
+
+	return b.String()
+}
+
+// Максимум строк с лидерами партиций в описании кластера
+const maxPartitionLines = 24
+
+func formatClusterInfo(info *models.ClusterInfo) string {
+	var b strings.Builder
+
+	controller := "неизвестен"
+	if info.Controller.Addr != "" {
+		controller = fmt.Sprintf("#%d <code>%s</code>", info.Controller.ID, html.EscapeString(info.Controller.Addr))
+	}
+	b.WriteString(fmt.Sprintf("🛰 <b>Кластер:</b> брокеров %d, контроллер %s\n", len(info.Brokers), controller))
+
+	b.WriteString(fmt.Sprintf("🧩 <b>Партиции (%d):</b>\n", len(info.Partitions)))
+	for i, p := range info.Partitions {
+		if i == maxPartitionLines {
+			b.WriteString(fmt.Sprintf("… и ещё %d\n", len(info.Partitions)-maxPartitionLines))
+			break
+		}
+		leader := "🔴 нет лидера"
+		if p.Leader.ID >= 0 {
+			leader = fmt.Sprintf("#%d <code>%s</code>", p.Leader.ID, html.EscapeString(p.Leader.Addr))
+		}
+		b.WriteString(fmt.Sprintf("P%d → %s (ISR %d/%d)\n", p.ID, leader, p.ISR, p.Replicas))
+	}
+
+	return b.String()
+}
+
 func prettyPrintJSON(input string) string {
 	var temp interface{}
 	if err := json.Unmarshal([]byte(input), &temp); err != nil {
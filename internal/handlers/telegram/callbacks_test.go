@@ -0,0 +1,20 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+)
+
+func TestFormatHeadersTruncatesRunes(t *testing.T) {
+	long := strings.Repeat("ё", maxHeaderLength+10)
+	got := formatHeaders([]models.Header{{Key: "alarm", Value: long}})
+	if !utf8.ValidString(got) {
+		t.Fatalf("formatHeaders() produced invalid UTF-8: %q", got)
+	}
+	if want := strings.Repeat("ё", maxHeaderLength) + "…"; !strings.Contains(got, want) {
+		t.Errorf("formatHeaders() = %q, want the value cut to %d characters", got, maxHeaderLength)
+	}
+}
@@ -2,32 +2,52 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html"
+	"log"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
 	"github.com/iwtcode/fanucClient/internal/interfaces"
 	"github.com/iwtcode/fanucService"
 	tele "gopkg.in/telebot.v3"
 )
 
 type CommandHandler struct {
-	menu       *Menu
-	settingsUC interfaces.SettingsUsecase
-	controlUC  interfaces.ControlUsecase
+	menu         *Menu
+	settingsUC   interfaces.SettingsUsecase
+	monitoringUC interfaces.MonitoringUsecase
+	controlUC    interfaces.ControlUsecase
+	alertUC      interfaces.AlertUsecase
+	watchdogUC   interfaces.WatchdogUsecase
+	notifyUC     interfaces.NotificationUsecase
+	reportUC     interfaces.ReportUsecase
 }
 
 func NewCommandHandler(
 	menu *Menu,
 	settingsUC interfaces.SettingsUsecase,
+	monitoringUC interfaces.MonitoringUsecase,
 	controlUC interfaces.ControlUsecase,
+	alertUC interfaces.AlertUsecase,
+	watchdogUC interfaces.WatchdogUsecase,
+	notifyUC interfaces.NotificationUsecase,
+	reportUC interfaces.ReportUsecase,
 ) *CommandHandler {
 	return &CommandHandler{
-		menu:       menu,
-		settingsUC: settingsUC,
-		controlUC:  controlUC,
+		menu:         menu,
+		settingsUC:   settingsUC,
+		monitoringUC: monitoringUC,
+		controlUC:    controlUC,
+		alertUC:      alertUC,
+		watchdogUC:   watchdogUC,
+		notifyUC:     notifyUC,
+		reportUC:     reportUC,
 	}
 }
 
@@ -102,11 +122,238 @@ func (h *CommandHandler) OnServices(c tele.Context) error {
 	}
 
 	text := fmt.Sprintf("🌐 <b>Ваши сервисы (%d)</b>\n\nВыберите <code>API Service</code> для управления:", len(services))
-	markup := h.menu.BuildServicesList(services)
+	markup := h.menu.BuildServicesList(services, h.unreachableServices(services))
 
 	return c.Send(text, markup)
 }
 
+// unreachableServices marks services whose recent calls failed, without calling them
+func (h *CommandHandler) unreachableServices(services []entities.FanucService) map[uint]bool {
+	unreachable := make(map[uint]bool)
+	for _, s := range services {
+		if h.controlUC.IsUnreachable(s) {
+			unreachable[s.ID] = true
+		}
+	}
+	return unreachable
+}
+
+// Ожидание ответа сервиса на команду из мастера: изменения выполняются одной попыткой
+const controlTimeout = 35 * time.Second
+
+// serviceUnreachable reports that the service is known to be down, so the user gets an answer right away
+func (h *CommandHandler) serviceUnreachable(svcID uint) bool {
+	svc, err := h.settingsUC.GetServiceByID(svcID)
+	return err == nil && h.controlUC.IsUnreachable(*svc)
+}
+
+// showPreferences renders the notification preferences of the user
+func (h *CommandHandler) showPreferences(c tele.Context) error {
+	userID := c.Sender().ID
+	settings, err := h.notifyUC.GetSettings(userID)
+	if err != nil {
+		safeErr := html.EscapeString(err.Error())
+		return c.Send("❌ Ошибка получения настроек: " + safeErr)
+	}
+	mutes, _ := h.notifyUC.GetMutes(userID)
+
+	tz := settings.TimeZone
+	if tz == "" {
+		tz = "UTC"
+	}
+	quiet := "выключены"
+	if settings.QuietFrom != "" {
+		quiet = fmt.Sprintf("%s–%s", settings.QuietFrom, settings.QuietTo)
+	}
+	digest := "выключена, уведомления приходят сразу"
+	if settings.DigestMinutes > 0 {
+		digest = "каждые " + models.HumanDuration(time.Duration(settings.DigestMinutes)*time.Minute)
+	}
+
+	var b strings.Builder
+	b.WriteString("🔔 <b>Настройки уведомлений</b>\n\n")
+	b.WriteString(fmt.Sprintf("🌍 Часовой пояс: <code>%s</code>\n", html.EscapeString(tz)))
+	b.WriteString(fmt.Sprintf("🌙 Тихие часы: %s\n", quiet))
+	b.WriteString(fmt.Sprintf("📶 Мин. важность: %s\n", severityTitle(settings.MinSeverity)))
+	b.WriteString(fmt.Sprintf("🗂 Сводка: %s\n", digest))
+	if len(mutes) > 0 {
+		b.WriteString("\n🔇 <b>Заглушены станки:</b>\n")
+		for _, m := range mutes {
+			b.WriteString(fmt.Sprintf("• <code>%s</code> ещё %s\n", html.EscapeString(m.MachineID), models.HumanDuration(time.Until(m.Until))))
+		}
+	}
+	b.WriteString("\nВ тихие часы и в режиме сводки уведомления копятся и приходят одним сообщением. " +
+		"Уведомления важности 🚨 critical приходят сразу.")
+
+	markup := h.menu.BuildPreferencesView(settings, mutes)
+	if c.Callback() != nil {
+		return c.Edit(b.String(), markup)
+	}
+	return c.Send(b.String(), markup)
+}
+
+// showReports renders the shift report schedule of the user
+func (h *CommandHandler) showReports(c tele.Context) error {
+	settings, err := h.notifyUC.GetSettings(c.Sender().ID)
+	if err != nil {
+		safeErr := html.EscapeString(err.Error())
+		return c.Send("❌ Ошибка получения настроек: " + safeErr)
+	}
+
+	schedule := "выключены"
+	if settings.ReportTimes != "" {
+		schedule = strings.ReplaceAll(settings.ReportTimes, ",", ", ")
+	}
+	tz := settings.TimeZone
+	if tz == "" {
+		tz = "UTC"
+	}
+	csv := "нет"
+	if settings.ReportCSV {
+		csv = "да"
+	}
+
+	var b strings.Builder
+	b.WriteString("📊 <b>Отчеты по сменам</b>\n\n")
+	b.WriteString(fmt.Sprintf("🕒 Время отправки: %s (<code>%s</code>)\n", schedule, html.EscapeString(tz)))
+	b.WriteString(fmt.Sprintf("📎 CSV по станкам: %s\n", csv))
+	b.WriteString("\nОтчет приходит в конце каждой смены и охватывает время с предыдущего отчета: " +
+		"доступность и опрос станков, сработавшие и принятые оповещения, топики без данных. " +
+		"Тихие часы и режим сводки на отчеты не действуют.")
+
+	markup := h.menu.BuildReportsView(settings)
+	if c.Callback() != nil {
+		return c.Edit(b.String(), markup)
+	}
+	return c.Send(b.String(), markup)
+}
+
+// Оповещений на одной странице истории
+const alertsPerPage = 8
+
+// OnAlerts обрабатывает команду /alerts: история сработавших правил
+func (h *CommandHandler) OnAlerts(c tele.Context) error {
+	h.settingsUC.SetState(c.Sender().ID, entities.StateIdle)
+	return h.showAlerts(c, 0)
+}
+
+// showAlerts renders a page of the alert history with the user's filters
+func (h *CommandHandler) showAlerts(c tele.Context, page int) error {
+	user, err := h.settingsUC.GetUser(c.Sender().ID)
+	if err != nil {
+		return c.Send("❌ Ошибка получения пользователя")
+	}
+	filter := models.AlertFilter{
+		UserID:    user.ID,
+		Status:    user.AlertFilterStatus,
+		MachineID: user.AlertFilterMachine,
+	}
+
+	page = max(page, 0)
+	alerts, total, err := h.alertUC.ListAlerts(filter, page*alertsPerPage, alertsPerPage)
+	if err != nil {
+		safeErr := html.EscapeString(err.Error())
+		return c.Send("❌ Ошибка получения оповещений: " + safeErr)
+	}
+	pages := max(int((total+alertsPerPage-1)/alertsPerPage), 1)
+	if page >= pages {
+		// Filter changed since the page was opened
+		return h.showAlerts(c, pages-1)
+	}
+
+	machine := "все"
+	if filter.MachineID != "" {
+		machine = "<code>" + html.EscapeString(filter.MachineID) + "</code>"
+	}
+	text := fmt.Sprintf("🚨 <b>Оповещения (%d)</b>\nСостояние: %s · Станок: %s",
+		total, alertStatusTitle(filter.Status), machine)
+	if total == 0 {
+		text += "\n\nОповещений нет."
+	} else {
+		text += fmt.Sprintf("\nСтраница %d/%d", page+1, pages)
+	}
+	markup := h.menu.BuildAlertsList(alerts, filter, page, pages)
+
+	if c.Callback() != nil {
+		return c.Edit(text, markup)
+	}
+	return c.Send(text, markup)
+}
+
+// --- Kafka Wizard Prompts ---
+// Используются и при текстовом вводе, и при нажатии кнопок мастера
+
+func (h *CommandHandler) promptAuthMechanism(c tele.Context) error {
+	text := "🔐 <b>Шаг 3/5: Аутентификация</b>\nВыберите SASL механизм:"
+	if c.Callback() != nil {
+		return c.Edit(text, h.menu.BuildAuthMechanisms())
+	}
+	return c.Send(text, h.menu.BuildAuthMechanisms())
+}
+
+func (h *CommandHandler) promptAuthUser(c tele.Context) error {
+	text := "👤 <b>Шаг 3/5: Имя пользователя SASL</b>"
+	if c.Callback() != nil {
+		return c.Edit(text, h.menu.BuildCancel())
+	}
+	return c.Send(text, h.menu.BuildCancel())
+}
+
+func (h *CommandHandler) promptTLS(c tele.Context) error {
+	text := "🔒 <b>Шаг 4/5: TLS</b>\nВыберите вариант или отправьте CA сертификат кластера в формате PEM."
+	if c.Callback() != nil {
+		return c.Edit(text, h.menu.BuildTLSOptions())
+	}
+	return c.Send(text, h.menu.BuildTLSOptions())
+}
+
+// promptTopic connects to the cluster and offers its topics as buttons
+func (h *CommandHandler) promptTopic(c tele.Context) error {
+	c.Notify(tele.Typing)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	topics, err := h.settingsUC.ListDraftTopics(ctx, c.Sender().ID)
+	cancel()
+
+	var text string
+	markup := h.menu.BuildCancel()
+	switch {
+	case err != nil:
+		safeErr := html.EscapeString(err.Error())
+		text = fmt.Sprintf("📂 <b>Шаг 5/5: Topic</b>\n❌ %s\n\nПроверьте адрес брокера и параметры подключения.", safeErr)
+	case len(topics) == 0:
+		text = "📂 <b>Шаг 5/5: Topic</b>\n⚠️ В кластере нет топиков."
+	default:
+		text = fmt.Sprintf("📂 <b>Шаг 5/5: Topic</b>\nНайдено топиков: %d. Выберите или введите имя:", len(topics))
+		markup = h.menu.BuildTopics(topics)
+	}
+
+	if c.Callback() != nil {
+		return c.Edit(text, markup)
+	}
+	return c.Send(text, markup)
+}
+
+// --- Kafka Key Prompt ---
+
+var keyModeHints = map[string]string{
+	models.KeyMatchExact:  "Точное совпадение ключа записи.",
+	models.KeyMatchPrefix: "Ключ начинается с шаблона, например <code>line3/</code>.",
+	models.KeyMatchGlob:   "<code>*</code> - любая подстрока, <code>?</code> - один символ, например <code>line3/cnc-*/status</code>.",
+	models.KeyMatchRegex:  "Регулярное выражение (RE2), например <code>^line[1-3]/cnc-0[0-9]/</code>.",
+}
+
+func (h *CommandHandler) promptNewKey(c tele.Context, mode, header string) error {
+	text := fmt.Sprintf("🔑 <b>Добавление ключа</b>\n\nРежим: %s\nВведите ключ (фильтр):", keyModeHints[mode])
+	if header != "" {
+		text = fmt.Sprintf("🔑 <b>Добавление ключа</b>\n🏷 Заголовок: <code>%s</code>\n\nРежим: %s\nВведите значение заголовка (фильтр):",
+			html.EscapeString(header), keyModeHints[mode])
+	}
+	if c.Callback() != nil {
+		return c.Edit(text, h.menu.BuildKeyModes(mode, header))
+	}
+	return c.Send(text, h.menu.BuildKeyModes(mode, header))
+}
+
 func (h *CommandHandler) OnText(c tele.Context) error {
 	userID := c.Sender().ID
 	user, err := h.settingsUC.GetUser(userID)
@@ -123,21 +370,202 @@ func (h *CommandHandler) OnText(c tele.Context) error {
 	// --- Kafka Wizard ---
 	case entities.StateWaitingName:
 		h.settingsUC.SetDraftName(userID, input)
-		return c.Send("🔌 <b>Шаг 2/3: Broker (IP:PORT)</b>", h.menu.BuildCancel())
+		return c.Send("🔌 <b>Шаг 2/5: Bootstrap Brokers (IP:PORT)</b>\nМожно указать несколько через запятую: при недоступности одного будет использован следующий.", h.menu.BuildCancel())
 	case entities.StateWaitingBroker:
-		h.settingsUC.SetDraftBroker(userID, input)
-		return c.Send("📂 <b>Шаг 3/3: Topic</b>", h.menu.BuildCancel())
+		if err := h.settingsUC.SetDraftBroker(userID, input); err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildCancel())
+		}
+		return h.promptAuthMechanism(c)
+	case entities.StateWaitingAuthMechanism:
+		// Механизм выбирается кнопками
+		return h.promptAuthMechanism(c)
+	case entities.StateWaitingAuthUser:
+		h.settingsUC.SetDraftAuthUser(userID, input)
+		return c.Send("🔑 <b>Шаг 3/5: Пароль SASL</b>\nСообщение с паролем будет удалено из чата.", h.menu.BuildCancel())
+	case entities.StateWaitingAuthPassword:
+		h.settingsUC.SetDraftAuthPassword(userID, input)
+		// Не оставляем пароль в истории чата
+		c.Delete()
+		return h.promptTLS(c)
+	case entities.StateWaitingTLS:
+		// Текстом можно прислать только CA сертификат (PEM), остальные варианты - кнопками
+		if err := h.settingsUC.SetDraftTLS(userID, true, false, input); err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildTLSOptions())
+		}
+		return h.promptTopic(c)
 	case entities.StateWaitingTopic:
-		h.settingsUC.SetDraftTopicAndSave(userID, input)
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		err := h.settingsUC.SetDraftTopicAndSave(ctx, userID, input)
+		cancel()
+		if err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildCancel())
+		}
 		c.Send("✅ Kafka Target сохранен!")
 		return h.OnKafka(c)
 
 	// --- Adding Key to existing Target ---
 	case entities.StateWaitingNewKey:
-		h.settingsUC.AddKeyToTarget(userID, input)
+		if err := h.settingsUC.AddKeyToTarget(userID, input); err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildKeyModes(user.DraftKeyMode, user.DraftKeyHeader))
+		}
 		c.Send("✅ Ключ добавлен!")
 		return h.OnKafka(c)
 
+	case entities.StateWaitingKeyHeader:
+		if input == "" {
+			return c.Send("⚠️ Имя заголовка не может быть пустым", h.menu.BuildCancel())
+		}
+		h.settingsUC.SetDraftKeyHeader(userID, input)
+		return h.promptNewKey(c, user.DraftKeyMode, input)
+
+	// --- JSON Filters of a Key ---
+	case entities.StateWaitingKeyProjection, entities.StateWaitingKeyPredicate:
+		if input == "-" {
+			input = ""
+		}
+		if user.State == entities.StateWaitingKeyPredicate {
+			err = h.settingsUC.SetKeyPredicate(userID, user.ContextKeyID, input)
+		} else {
+			err = h.settingsUC.SetKeyProjection(userID, user.ContextKeyID, input)
+		}
+		if err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildCancel())
+		}
+		return c.Send("✅ Фильтр сохранен!", h.menu.BuildKeyView(user.ContextTargetID, user.ContextKeyID))
+
+	// --- Alert rule of a key ---
+	case entities.StateWaitingAlertRule:
+		rule, err := h.alertUC.CreateRule(userID, user.ContextTargetID, user.ContextKeyID, input)
+		if err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildCancel())
+		}
+		h.settingsUC.SetState(userID, entities.StateIdle)
+
+		rules, _ := h.alertUC.GetRules(rule.TargetID, rule.KeyID)
+		return c.Send(fmt.Sprintf("✅ Правило сохранено: <code>%s</code>", html.EscapeString(rule.String())),
+			h.menu.BuildRulesView(rule.TargetID, rule.KeyID, rules))
+
+	// --- Re-notification and escalation of a rule ---
+	case entities.StateWaitingRuleRenotify:
+		var d time.Duration
+		if input != "-" {
+			d, err = time.ParseDuration(input)
+			if err != nil || d <= 0 {
+				return c.Send("⚠️ Некорректный интервал. Примеры: <code>10m</code>, <code>30m</code>, <code>1h</code>", h.menu.BuildCancel())
+			}
+		}
+		if err := h.alertUC.SetRenotify(userID, user.ContextRuleID, d); err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildCancel())
+		}
+		h.settingsUC.SetState(userID, entities.StateIdle)
+		return h.sendRuleSaved(c, user.ContextRuleID, "✅ Повтор оповещений сохранен")
+
+	case entities.StateWaitingRuleEscalation:
+		if err := h.alertUC.SetEscalation(userID, user.ContextRuleID, input); err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildCancel())
+		}
+		h.settingsUC.SetState(userID, entities.StateIdle)
+		return h.sendRuleSaved(c, user.ContextRuleID, "✅ Эскалация сохранена")
+
+	// --- Notification preferences ---
+	case entities.StateWaitingTimeZone:
+		if err := h.notifyUC.SetTimeZone(userID, input); err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildCancel())
+		}
+		h.settingsUC.SetState(userID, entities.StateIdle)
+		return h.showPreferences(c)
+
+	case entities.StateWaitingQuietHours:
+		if err := h.notifyUC.SetQuietHours(userID, input); err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr+"\nФормат: <code>22:00-07:00</code>", h.menu.BuildCancel())
+		}
+		h.settingsUC.SetState(userID, entities.StateIdle)
+		return h.showPreferences(c)
+
+	case entities.StateWaitingMachineMute:
+		machineID, d, err := parseMachineMute(input)
+		if err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr+"\nФормат: <code>M-01 2h</code> (примеры времени: <code>30m</code>, <code>2h</code>, <code>24h</code>)", h.menu.BuildCancel())
+		}
+		if err := h.notifyUC.MuteMachine(userID, machineID, d); err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildCancel())
+		}
+		h.settingsUC.SetState(userID, entities.StateIdle)
+		return h.showPreferences(c)
+
+	case entities.StateWaitingReportTimes:
+		if err := h.reportUC.SetReportTimes(userID, input); err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildCancel())
+		}
+		h.settingsUC.SetState(userID, entities.StateIdle)
+		return h.showReports(c)
+
+	// --- Max silence of a key ---
+	case entities.StateWaitingMaxSilence:
+		var d time.Duration
+		if input != "-" {
+			d, err = time.ParseDuration(input)
+			if err != nil || d <= 0 {
+				return c.Send("⚠️ Некорректная пауза. Примеры: <code>5m</code>, <code>30m</code>, <code>2h</code>", h.menu.BuildCancel())
+			}
+		}
+		if err := h.watchdogUC.SetMaxSilence(userID, user.ContextTargetID, user.ContextKeyID, d); err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("⚠️ "+safeErr, h.menu.BuildCancel())
+		}
+		h.settingsUC.SetState(userID, entities.StateIdle)
+
+		text := "✅ Контроль тишины выключен"
+		if d > 0 {
+			text = fmt.Sprintf("✅ Оповещение, если данных нет дольше %s", models.HumanDuration(d))
+		}
+		return c.Send(text, h.menu.BuildKeyView(user.ContextTargetID, user.ContextKeyID))
+
+	// --- Consumer group for topic stats ---
+	case entities.StateWaitingTargetGroup:
+		if input == "-" {
+			input = ""
+		}
+		if err := h.settingsUC.SetTargetConsumerGroup(userID, user.ContextTargetID, input); err != nil {
+			safeErr := html.EscapeString(err.Error())
+			return c.Send("❌ Ошибка: "+safeErr, h.menu.BuildCancel())
+		}
+		return c.Send("✅ Consumer group сохранена!", h.menu.BuildStatsView(user.ContextTargetID))
+
+	// --- Export of a custom window ---
+	case entities.StateWaitingExportWindow:
+		from, to, err := parseTimeWindow(input, time.Now())
+		if err != nil {
+			return c.Send("⚠️ "+html.EscapeString(err.Error())+"\n"+seekTimeHint, h.menu.BuildCancel())
+		}
+		h.settingsUC.SetState(userID, entities.StateIdle)
+
+		rangeCode := fmt.Sprintf("w%d-%d", from.Unix(), to.Unix())
+		text := fmt.Sprintf("📤 <b>Экспорт</b>: %s — %s\n\nВыберите формат:", from.Format("2006-01-02 15:04:05"), to.Format("2006-01-02 15:04:05"))
+		return c.Send(text, h.menu.BuildExportFormats(user.ContextTargetID, user.ContextKeyID, rangeCode))
+
+	// --- Seek by Timestamp ---
+	case entities.StateWaitingSeekTime:
+		at, err := parseSeekTime(input, time.Now())
+		if err != nil {
+			return c.Send("⚠️ Не удалось распознать время.\n"+seekTimeHint, h.menu.BuildCancel())
+		}
+		h.settingsUC.SetState(userID, entities.StateIdle)
+		return h.sendMessageAt(c, user.ContextTargetID, user.ContextKeyID, at)
+
 	// --- Service Registration Wizard ---
 	case entities.StateWaitingSvcName:
 		h.settingsUC.SetDraftSvcName(userID, input)
@@ -189,9 +617,16 @@ func (h *CommandHandler) OnText(c tele.Context) error {
 			Series:   series,
 		}
 
+		if h.serviceUnreachable(svcID) {
+			h.settingsUC.SetState(userID, entities.StateIdle)
+			c.Send("🔴 Сервис недоступен, попробуйте позже")
+			return h.OnServices(c)
+		}
 		c.Send("⏳ Создание подключения на удаленном сервисе...")
 
-		_, err := h.controlUC.CreateMachine(context.Background(), svcID, req)
+		ctx, cancel := context.WithTimeout(context.Background(), controlTimeout)
+		_, err := h.controlUC.CreateMachine(ctx, svcID, req)
+		cancel()
 		if err != nil {
 			c.Send(fmt.Sprintf("❌ Ошибка создания подключения: %v", err))
 		} else {
@@ -212,8 +647,15 @@ func (h *CommandHandler) OnText(c tele.Context) error {
 		svcID := user.ContextSvcID
 		machineID := user.ContextMachineID
 
+		if h.serviceUnreachable(svcID) {
+			h.settingsUC.SetState(userID, entities.StateIdle)
+			c.Send("🔴 Сервис недоступен, попробуйте позже")
+			return h.OnServices(c)
+		}
 		c.Send("⏳ Запуск опроса...")
-		err = h.controlUC.StartPolling(context.Background(), svcID, machineID, interval)
+		ctx, cancel := context.WithTimeout(context.Background(), controlTimeout)
+		err = h.controlUC.StartPolling(ctx, svcID, machineID, interval)
+		cancel()
 		if err != nil {
 			c.Send(fmt.Sprintf("❌ Ошибка запуска опроса: %v", err))
 		} else {
@@ -223,8 +665,255 @@ func (h *CommandHandler) OnText(c tele.Context) error {
 		h.settingsUC.SetState(userID, entities.StateIdle)
 		return h.OnServices(c)
 
+	case entities.StateWaitingBulkPollInterval:
+		interval, err := strconv.Atoi(input)
+		if err != nil || interval < 100 {
+			return c.Send("⚠️ Пожалуйста, введите корректное число (минимум 100 мс).")
+		}
+		h.settingsUC.SetState(userID, entities.StateIdle)
+
+		msg, err := c.Bot().Send(c.Recipient(), "⏳ Запуск опроса всех станков...")
+		if err != nil {
+			return err
+		}
+		go h.runBulk(c.Bot(), msg, user.ContextSvcID, models.BulkStartPolling, interval)
+		return nil
+
 	default:
 		// Если состояние Idle, любой текстовый ввод перенаправляет в главное меню.
 		return h.OnStart(c)
 	}
 }
+
+// --- Bulk Actions ---
+
+const (
+	// Минимальный интервал между редактированиями сообщения о ходе массового действия
+	bulkEditInterval = time.Second
+	// Общее время массового действия: отдельные вызовы ограничены таймаутами сервиса
+	bulkTimeout = 3 * time.Minute
+)
+
+// runBulk applies the action to all machines of the service and keeps msg updated with a per-machine table.
+// It runs in its own goroutine, outside the bot's handlers, so it recovers from panics itself.
+func (h *CommandHandler) runBulk(bot *tele.Bot, msg *tele.Message, svcID uint, action string, intervalMs int) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("⚠️ Массовое действие %s сервиса %d прервано: %v\n%s", action, svcID, r, debug.Stack())
+			bot.Edit(msg, "❌ Массовое действие прервано внутренней ошибкой", h.menu.BuildBulkResult(svcID))
+		}
+	}()
+
+	title := bulkTitle(action, intervalMs)
+	if svc, err := h.settingsUC.GetServiceByID(svcID); err == nil {
+		title += ": " + html.EscapeString(svc.Name)
+	}
+	markup := h.menu.BuildBulkResult(svcID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
+	defer cancel()
+
+	var lastEdit time.Time
+	results, err := h.controlUC.RunBulk(ctx, svcID, action, intervalMs, func(results []models.BulkResult) {
+		// Telegram limits how often a message can be edited; the final table is always sent below
+		if time.Since(lastEdit) < bulkEditInterval {
+			return
+		}
+		lastEdit = time.Now()
+		bot.Edit(msg, formatBulkResults(title, results), markup)
+	})
+	if err != nil {
+		safeErr := html.EscapeString(err.Error())
+		bot.Edit(msg, fmt.Sprintf("%s\n\n❌ Ошибка:\n%s", title, safeErr), markup)
+		return
+	}
+	bot.Edit(msg, formatBulkResults(title, results), markup)
+}
+
+func bulkTitle(action string, intervalMs int) string {
+	switch action {
+	case models.BulkStartPolling:
+		return fmt.Sprintf("▶ <b>Запуск опроса</b> (%d мс)", intervalMs)
+	case models.BulkStopPolling:
+		return "⏹ <b>Остановка опроса</b>"
+	}
+	return "🔁 <b>Проверка станков</b>"
+}
+
+// formatBulkResults renders the progress header and one line per machine
+func formatBulkResults(title string, results []models.BulkResult) string {
+	var ok, failed, pending int
+	var lines strings.Builder
+	for i, r := range results {
+		if lines.Len() > 3500 {
+			lines.WriteString(fmt.Sprintf("… и еще %d станков\n", len(results)-i))
+			break
+		}
+
+		name := r.Endpoint
+		if name == "" {
+			name = r.MachineID
+		}
+		line := fmt.Sprintf("<code>%s</code>", html.EscapeString(name))
+		switch {
+		case !r.Done:
+			line = "⏳ " + line
+		case r.Err != nil:
+			errText := []rune(r.Err.Error())
+			if len(errText) > 80 {
+				errText = append(errText[:80], '…')
+			}
+			line = fmt.Sprintf("❌ %s — %s", line, html.EscapeString(string(errText)))
+		case r.Status != "":
+			line = fmt.Sprintf("✅ %s — %s", line, html.EscapeString(r.Status))
+			if r.Mode != "" {
+				line += " · " + html.EscapeString(r.Mode)
+			}
+		default:
+			line = "✅ " + line
+		}
+		lines.WriteString(line + "\n")
+	}
+	for _, r := range results {
+		switch {
+		case !r.Done:
+			pending++
+		case r.Err != nil:
+			failed++
+		default:
+			ok++
+		}
+	}
+
+	summary := fmt.Sprintf("✅ %d · ❌ %d", ok, failed)
+	if pending > 0 {
+		summary += fmt.Sprintf(" · ⏳ %d", pending)
+	}
+	if len(results) == 0 {
+		return title + "\n\nНа сервисе нет станков"
+	}
+	return fmt.Sprintf("%s\n%s\n\n%s", title, summary, strings.TrimRight(lines.String(), "\n"))
+}
+
+// --- Seek by Timestamp ---
+
+const seekTimeHint = "Форматы: <code>14:32</code>, <code>вчера 14:32</code>, <code>05.03 14:32</code>, " +
+	"<code>05.03.2025 14:32:10</code>, <code>2025-03-05 14:32</code>"
+
+func (h *CommandHandler) sendMessageAt(c tele.Context, targetID, keyID uint, at time.Time) error {
+	c.Notify(tele.Typing)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	msg, err := h.monitoringUC.FetchMessageAt(ctx, targetID, keyID, at)
+	cancel()
+
+	backMarkup := h.menu.BuildKeyView(targetID, keyID)
+
+	if err != nil {
+		safeErr := html.EscapeString(err.Error())
+		return c.Send(fmt.Sprintf("❌ Ошибка:\n%s", safeErr), backMarkup)
+	}
+	if msg == nil {
+		return c.Send(fmt.Sprintf("⚠️ Сообщения около %s не найдены", at.Format("2006-01-02 15:04:05")), backMarkup)
+	}
+
+	return c.Send(formatSeekResult(*msg, at), h.menu.BuildSeekView(targetID, keyID, msg.Position()))
+}
+
+// sendRuleSaved confirms the rule settings change and returns to the rule view
+func (h *CommandHandler) sendRuleSaved(c tele.Context, ruleID uint, text string) error {
+	rule, err := h.alertUC.GetRule(ruleID)
+	if err != nil {
+		return c.Send(text, h.menu.BuildMainMenu())
+	}
+	return c.Send(fmt.Sprintf("%s\n📏 <code>%s</code>", text, html.EscapeString(rule.String())), h.menu.BuildRuleView(*rule))
+}
+
+// parseMachineMute splits "mute M-01 2h" (the word "mute" is optional) into the machine ID and duration
+func parseMachineMute(input string) (string, time.Duration, error) {
+	fields := strings.Fields(input)
+	if len(fields) > 0 && strings.EqualFold(fields[0], "mute") {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return "", 0, fmt.Errorf("укажите станок и время")
+	}
+	d, err := time.ParseDuration(fields[len(fields)-1])
+	if err != nil || d <= 0 {
+		return "", 0, fmt.Errorf("некорректное время '%s'", fields[len(fields)-1])
+	}
+	return strings.Join(fields[:len(fields)-1], " "), d, nil
+}
+
+// parseTimeWindow разбирает интервал "начало - конец" (разделитель " - " или "—")
+func parseTimeWindow(input string, now time.Time) (time.Time, time.Time, error) {
+	fromRaw, toRaw, ok := strings.Cut(input, " - ")
+	if !ok {
+		fromRaw, toRaw, ok = strings.Cut(input, "—")
+	}
+	if !ok {
+		return time.Time{}, time.Time{}, errors.New("укажите начало и конец через \" - \"")
+	}
+
+	from, err := parseSeekTime(fromRaw, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("не удалось распознать начало интервала")
+	}
+	to, err := parseSeekTime(toRaw, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("не удалось распознать конец интервала")
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("начало интервала должно быть раньше конца")
+	}
+	return from, to, nil
+}
+
+// parseSeekTime понимает время в часовом поясе бота. Время без даты относится
+// к последнему прошедшему моменту: "14:32" в 10:00 означает вчера.
+func parseSeekTime(input string, now time.Time) (time.Time, error) {
+	input = strings.ToLower(strings.Join(strings.Fields(input), " "))
+	loc := now.Location()
+
+	// 1. Full date
+	for _, layout := range []string{
+		"2006-01-02 15:04:05", "2006-01-02 15:04",
+		"02.01.2006 15:04:05", "02.01.2006 15:04",
+	} {
+		if t, err := time.ParseInLocation(layout, input, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	// 2. Day and month of the current year
+	for _, layout := range []string{"02.01 15:04:05", "02.01 15:04"} {
+		if t, err := time.ParseInLocation(layout, input, loc); err == nil {
+			t = t.AddDate(now.Year()-t.Year(), 0, 0)
+			if t.After(now) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			return t, nil
+		}
+	}
+
+	// 3. Clock only, optionally relative to today/yesterday
+	dayShift, explicitDay := 0, false
+	if rest, ok := strings.CutPrefix(input, "вчера"); ok {
+		dayShift, explicitDay, input = -1, true, strings.TrimSpace(rest)
+	} else if rest, ok := strings.CutPrefix(input, "сегодня"); ok {
+		explicitDay, input = true, strings.TrimSpace(rest)
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		clock, err := time.ParseInLocation(layout, input, loc)
+		if err != nil {
+			continue
+		}
+		t := time.Date(now.Year(), now.Month(), now.Day()+dayShift,
+			clock.Hour(), clock.Minute(), clock.Second(), 0, loc)
+		if !explicitDay && t.After(now) {
+			t = t.AddDate(0, 0, -1)
+		}
+		return t, nil
+	}
+
+	return time.Time{}, errors.New("unknown time format")
+}
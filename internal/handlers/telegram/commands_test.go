@@ -0,0 +1,116 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMachineMute(t *testing.T) {
+	tests := []struct {
+		input     string
+		machineID string
+		d         time.Duration
+		wantErr   bool
+	}{
+		{input: "M-01 2h", machineID: "M-01", d: 2 * time.Hour},
+		{input: "mute M-01 30m", machineID: "M-01", d: 30 * time.Minute},
+		{input: "Line 3 CNC 1h30m", machineID: "Line 3 CNC", d: 90 * time.Minute},
+		{input: "M-01", wantErr: true},
+		{input: "mute 2h", wantErr: true},
+		{input: "M-01 soon", wantErr: true},
+		{input: "M-01 -1h", wantErr: true},
+		{input: "<b>M</b> <i>", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			machineID, d, err := parseMachineMute(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMachineMute(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if machineID != tt.machineID || d != tt.d {
+				t.Errorf("parseMachineMute(%q) = %q, %v; want %q, %v", tt.input, machineID, d, tt.machineID, tt.d)
+			}
+			// The handler escapes the error, it must not rely on HTML
+			if err != nil && strings.Contains(err.Error(), "<code>") {
+				t.Errorf("error should be plain text: %q", err)
+			}
+		})
+	}
+}
+
+func TestParseSeekTime(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*3600)
+	now := time.Date(2026, 3, 10, 10, 0, 0, 0, loc)
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{input: "2026-03-09 14:32:10", want: time.Date(2026, 3, 9, 14, 32, 10, 0, loc)},
+		{input: "2026-03-09  14:32", want: time.Date(2026, 3, 9, 14, 32, 0, 0, loc)},
+		{input: "09.03.2026 14:32", want: time.Date(2026, 3, 9, 14, 32, 0, 0, loc)},
+		{input: "09.03 14:32", want: time.Date(2026, 3, 9, 14, 32, 0, 0, loc)},
+		{input: "11.03 08:00", want: time.Date(2025, 3, 11, 8, 0, 0, 0, loc)},
+		{input: "09:30", want: time.Date(2026, 3, 10, 9, 30, 0, 0, loc)},
+		{input: "14:32", want: time.Date(2026, 3, 9, 14, 32, 0, 0, loc)},
+		{input: "сегодня 14:32", want: time.Date(2026, 3, 10, 14, 32, 0, 0, loc)},
+		{input: "Вчера 09:30:15", want: time.Date(2026, 3, 9, 9, 30, 15, 0, loc)},
+		{input: "вчера", wantErr: true},
+		{input: "25:00", wantErr: true},
+		{input: "now", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseSeekTime(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSeekTime(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseSeekTime(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeWindow(t *testing.T) {
+	now := time.Date(2026, 3, 10, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input    string
+		from, to time.Time
+		wantErr  bool
+	}{
+		{
+			input: "2026-03-09 08:00 - 2026-03-09 20:00",
+			from:  time.Date(2026, 3, 9, 8, 0, 0, 0, time.UTC),
+			to:    time.Date(2026, 3, 9, 20, 0, 0, 0, time.UTC),
+		},
+		{
+			input: "08:00—09:30",
+			from:  time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC),
+			to:    time.Date(2026, 3, 10, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			input: "вчера 22:00 - сегодня 06:00",
+			from:  time.Date(2026, 3, 9, 22, 0, 0, 0, time.UTC),
+			to:    time.Date(2026, 3, 10, 6, 0, 0, 0, time.UTC),
+		},
+		{input: "2026-03-09 08:00", wantErr: true},
+		{input: "08:00-09:00", wantErr: true},
+		{input: "soon - 09:00", wantErr: true},
+		{input: "08:00 - later", wantErr: true},
+		{input: "09:00 - 08:00", wantErr: true},
+		{input: "08:00 - 08:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			from, to, err := parseTimeWindow(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimeWindow(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("parseTimeWindow(%q) = %v, %v; want %v, %v", tt.input, from, to, tt.from, tt.to)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
 	"github.com/iwtcode/fanucService"
 	tele "gopkg.in/telebot.v3"
 )
@@ -68,6 +69,7 @@ func (m *Menu) BuildMainMenu() *tele.ReplyMarkup {
 	markup.Inline(
 		markup.Row(markup.Data("📋 Kafka Targets", "targets_list")),
 		markup.Row(markup.Data("🌐 API Services", "services_list")),
+		markup.Row(markup.Data("🚨 Оповещения", "alerts:0")),
 		markup.Row(markup.Data("👤 Профиль", "who_btn")),
 	)
 	return markup
@@ -76,11 +78,76 @@ func (m *Menu) BuildMainMenu() *tele.ReplyMarkup {
 func (m *Menu) BuildWhoMenu() *tele.ReplyMarkup {
 	markup := &tele.ReplyMarkup{}
 	markup.Inline(
+		markup.Row(markup.Data("🔔 Уведомления", "prefs")),
 		markup.Row(m.BtnHomeInline),
 	)
 	return markup
 }
 
+func (m *Menu) BuildPreferencesView(settings *entities.NotificationSettings, mutes []entities.MachineMute) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	digest := "🗂 Сводка: выкл"
+	if settings.DigestMinutes > 0 {
+		digest = fmt.Sprintf("🗂 Сводка: %d мин", settings.DigestMinutes)
+	}
+
+	rows := []tele.Row{
+		markup.Row(
+			markup.Data("🌍 Часовой пояс", "prefs_tz"),
+			markup.Data("🌙 Тихие часы", "prefs_quiet"),
+		),
+		markup.Row(
+			markup.Data("📶 "+severityTitle(settings.MinSeverity), "prefs_sev"),
+			markup.Data(digest, "prefs_dig"),
+		),
+		markup.Row(
+			markup.Data("🔇 Заглушить станок", "prefs_mute"),
+			markup.Data("📊 Отчеты по сменам", "reports"),
+		),
+	}
+	for _, mute := range mutes {
+		data := "unmute:" + mute.MachineID
+		if len(data) > 64 {
+			continue
+		}
+		rows = append(rows, markup.Row(markup.Data("🔊 Включить "+mute.MachineID, data)))
+	}
+	rows = append(rows, markup.Row(markup.Data("🔙 Профиль", "who_btn")))
+
+	markup.Inline(rows...)
+	return markup
+}
+
+func (m *Menu) BuildReportsView(settings *entities.NotificationSettings) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	csv := "📎 CSV: выкл"
+	if settings.ReportCSV {
+		csv = "📎 CSV: вкл"
+	}
+
+	markup.Inline(
+		markup.Row(
+			markup.Data("🕒 Время отчетов", "reports_time"),
+			markup.Data(csv, "reports_csv"),
+		),
+		markup.Row(markup.Data("📤 Отчет сейчас", "reports_now")),
+		markup.Row(markup.Data("🔙 Уведомления", "prefs")),
+	)
+	return markup
+}
+
+func severityTitle(severity string) string {
+	switch severity {
+	case models.SeverityWarning:
+		return "⚠️ warning"
+	case models.SeverityCritical:
+		return "🚨 critical"
+	}
+	return "ℹ️ info"
+}
+
 // --- Kafka Menus ---
 
 func (m *Menu) BuildTargetsList(targets []entities.MonitoringTarget) *tele.ReplyMarkup {
@@ -109,15 +176,17 @@ func (m *Menu) BuildTargetView(t entities.MonitoringTarget) *tele.ReplyMarkup {
 
 	// User defined keys
 	for _, k := range t.Keys {
-		btnKey := markup.Data(fmt.Sprintf("🔑 %s", k.Key), fmt.Sprintf("view_key:%d:%d", t.ID, k.ID))
+		filter := models.KeyFilter{Mode: k.MatchMode, Pattern: k.Key, Header: k.Header}
+		btnKey := markup.Data(fmt.Sprintf("🔑 %s", filter), fmt.Sprintf("view_key:%d:%d", t.ID, k.ID))
 		entryRows = append(entryRows, markup.Row(btnKey))
 	}
 
 	// 2. Management
 	btnAddKey := markup.Data("➕ Добавить ключ", fmt.Sprintf("add_key_start:%d", t.ID))
+	btnStats := markup.Data("📊 Статистика", fmt.Sprintf("stats:%d", t.ID))
 	btnDelTarget := markup.Data("🗑 Удалить Target", fmt.Sprintf("del_target:%d", t.ID))
 
-	entryRows = append(entryRows, markup.Row(btnAddKey))
+	entryRows = append(entryRows, markup.Row(btnAddKey, btnStats))
 	entryRows = append(entryRows, markup.Row(btnDelTarget))
 	entryRows = append(entryRows, markup.Row(m.BtnBackTargets))
 
@@ -125,20 +194,42 @@ func (m *Menu) BuildTargetView(t entities.MonitoringTarget) *tele.ReplyMarkup {
 	return markup
 }
 
+func (m *Menu) BuildStatsView(targetID uint) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(
+		markup.Row(markup.Data("🔄 Обновить", fmt.Sprintf("stats:%d", targetID))),
+		markup.Row(markup.Data("👥 Consumer group", fmt.Sprintf("grp_start:%d", targetID))),
+		markup.Row(markup.Data("🔙 К Kafka Target", fmt.Sprintf("view_target:%d", targetID))),
+	)
+	return markup
+}
+
 func (m *Menu) BuildKeyView(targetID, keyID uint) *tele.ReplyMarkup {
 	markup := &tele.ReplyMarkup{}
 
 	btnMsg := markup.Data("📨 Последнее сообщение", fmt.Sprintf("check_msg:%d:%d", targetID, keyID))
 	btnLive := markup.Data("🔴 Live Mode", fmt.Sprintf("live_mode:%d:%d", targetID, keyID))
+	btnHistory := markup.Data("📜 История", fmt.Sprintf("hist:%d:%d:0", targetID, keyID))
+	btnSeek := markup.Data("🕒 Поиск по времени", fmt.Sprintf("seek_start:%d:%d", targetID, keyID))
+	btnExport := markup.Data("📤 Экспорт", fmt.Sprintf("exp:%d:%d", targetID, keyID))
+	btnRules := markup.Data("🔔 Правила", fmt.Sprintf("rules:%d:%d", targetID, keyID))
+	btnSilence := markup.Data("🔇 Тишина", fmt.Sprintf("silence:%d:%d", targetID, keyID))
 	btnBack := markup.Data("🔙 К Kafka Target", fmt.Sprintf("view_target:%d", targetID))
 
 	// Control rows
 	rows := []tele.Row{
 		markup.Row(btnMsg, btnLive),
+		markup.Row(btnHistory, btnSeek),
+		markup.Row(btnExport),
+		markup.Row(btnRules, btnSilence),
 	}
 
-	// Delete button only for real keys (ID > 0)
+	// JSON filters and delete button only for real keys (ID > 0)
 	if keyID > 0 {
+		btnProj := markup.Data("🔍 Поля", fmt.Sprintf("proj_start:%d:%d", targetID, keyID))
+		btnPred := markup.Data("🎯 Условие", fmt.Sprintf("pred_start:%d:%d", targetID, keyID))
+		rows = append(rows, markup.Row(btnProj, btnPred))
+
 		btnDelKey := markup.Data("🗑 Удалить ключ", fmt.Sprintf("del_key:%d:%d", targetID, keyID))
 		rows = append(rows, markup.Row(btnDelKey))
 	}
@@ -148,6 +239,274 @@ func (m *Menu) BuildKeyView(targetID, keyID uint) *tele.ReplyMarkup {
 	return markup
 }
 
+// BuildHistoryView - навигация по истории: idx 0 = самое новое сообщение
+// BuildHistoryView - листание истории: "histp" берет страницы из уже прочитанного окна сообщений
+func (m *Menu) BuildHistoryView(targetID, keyID uint, idx, total int) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	var nav []tele.Btn
+	if idx+1 < total {
+		nav = append(nav, markup.Data("◀ Старее", fmt.Sprintf("histp:%d:%d:%d", targetID, keyID, idx+1)))
+	}
+	if idx > 0 {
+		nav = append(nav, markup.Data("Новее ▶", fmt.Sprintf("histp:%d:%d:%d", targetID, keyID, idx-1)))
+	}
+
+	var rows []tele.Row
+	if len(nav) > 0 {
+		rows = append(rows, markup.Row(nav...))
+	}
+	rows = append(rows, markup.Row(markup.Data("🔙 К ключу", fmt.Sprintf("view_key:%d:%d", targetID, keyID))))
+
+	markup.Inline(rows...)
+	return markup
+}
+
+// BuildSeekView - навигация от найденного по времени сообщения к соседним
+func (m *Menu) BuildSeekView(targetID, keyID uint, pos models.MessagePosition) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	// Format: sk:targetID:keyID:partition:offset:unixMilli:direction
+	ref := fmt.Sprintf("sk:%d:%d:%d:%d:%d", targetID, keyID, pos.Partition, pos.Offset, pos.Time.UnixMilli())
+	markup.Inline(
+		markup.Row(
+			markup.Data("◀ Раньше", ref+":o"),
+			markup.Data("Позже ▶", ref+":n"),
+		),
+		markup.Row(markup.Data("🕒 Другое время", fmt.Sprintf("seek_start:%d:%d", targetID, keyID))),
+		markup.Row(markup.Data("🔙 К ключу", fmt.Sprintf("view_key:%d:%d", targetID, keyID))),
+	)
+	return markup
+}
+
+// BuildExportRanges - выбор диапазона экспорта.
+// Коды диапазонов: n<N> - последние N сообщений, h<H> - последние H часов, w<from>-<to> - окно (unix)
+func (m *Menu) BuildExportRanges(targetID, keyID uint) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	ref := fmt.Sprintf("exp_r:%d:%d", targetID, keyID)
+
+	markup.Inline(
+		markup.Row(
+			markup.Data("100 посл.", ref+":n100"),
+			markup.Data("1000 посл.", ref+":n1000"),
+			markup.Data("5000 посл.", ref+":n5000"),
+		),
+		markup.Row(
+			markup.Data("За 1 ч", ref+":h1"),
+			markup.Data("За 6 ч", ref+":h6"),
+			markup.Data("За 24 ч", ref+":h24"),
+		),
+		markup.Row(markup.Data("🕒 Свой интервал", fmt.Sprintf("exp_win:%d:%d", targetID, keyID))),
+		markup.Row(markup.Data("🔙 К ключу", fmt.Sprintf("view_key:%d:%d", targetID, keyID))),
+	)
+	return markup
+}
+
+func (m *Menu) BuildExportFormats(targetID, keyID uint, rangeCode string) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	ref := fmt.Sprintf("exp_f:%d:%d:%s", targetID, keyID, rangeCode)
+
+	markup.Inline(
+		markup.Row(
+			markup.Data("📄 JSONL", ref+":"+models.ExportJSONL),
+			markup.Data("📊 CSV", ref+":"+models.ExportCSV),
+		),
+		markup.Row(markup.Data("🔙 Назад", fmt.Sprintf("exp:%d:%d", targetID, keyID))),
+	)
+	return markup
+}
+
+// Максимальная длина условия правила на кнопке
+const maxRuleButtonText = 48
+
+func (m *Menu) BuildRulesView(targetID, keyID uint, rules []entities.AlertRule) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for _, r := range rules {
+		icon := "🟢"
+		if !r.Enabled {
+			icon = "⚪"
+		}
+		text := r.String()
+		if len([]rune(text)) > maxRuleButtonText {
+			text = string([]rune(text)[:maxRuleButtonText]) + "…"
+		}
+		rows = append(rows, markup.Row(markup.Data(icon+" "+text, fmt.Sprintf("rule:%d", r.ID))))
+	}
+	rows = append(rows, markup.Row(markup.Data("➕ Добавить правило", fmt.Sprintf("rule_add:%d:%d", targetID, keyID))))
+	rows = append(rows, markup.Row(markup.Data("🔙 К ключу", fmt.Sprintf("view_key:%d:%d", targetID, keyID))))
+	markup.Inline(rows...)
+	return markup
+}
+
+func (m *Menu) BuildRuleView(rule entities.AlertRule) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	btnToggle := markup.Data("⏸ Выключить", fmt.Sprintf("rule_tgl:%d", rule.ID))
+	if !rule.Enabled {
+		btnToggle = markup.Data("▶ Включить", fmt.Sprintf("rule_tgl:%d", rule.ID))
+	}
+	btnSeverity := markup.Data("📶 "+severityTitle(rule.Severity), fmt.Sprintf("rule_sev:%d", rule.ID))
+	btnRenotify := markup.Data("🔁 Повтор", fmt.Sprintf("rule_rn:%d", rule.ID))
+	btnEscalate := markup.Data("📣 Эскалация", fmt.Sprintf("rule_esc:%d", rule.ID))
+	btnDel := markup.Data("🗑 Удалить правило", fmt.Sprintf("rule_del:%d", rule.ID))
+	btnBack := markup.Data("🔙 К правилам", fmt.Sprintf("rules:%d:%d", rule.TargetID, rule.KeyID))
+
+	markup.Inline(
+		markup.Row(btnToggle, btnSeverity),
+		markup.Row(btnRenotify, btnEscalate),
+		markup.Row(btnDel),
+		markup.Row(btnBack),
+	)
+	return markup
+}
+
+// Максимальная длина описания оповещения на кнопке
+const maxAlertButtonText = 56
+
+// Фильтры состояния истории оповещений ("" = все)
+var alertStatusFilters = []string{"", entities.AlertStatusOpen, entities.AlertStatusAcked, entities.AlertStatusResolved}
+
+func (m *Menu) BuildAlertsList(alerts []entities.Alert, filter models.AlertFilter, page, pages int) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+
+	for _, a := range alerts {
+		text := fmt.Sprintf("%s %s", alertStatusIcon(a.Status), a.FiredAt.Local().Format("01-02 15:04"))
+		if a.MachineID != "" {
+			text += " · " + a.MachineID
+		}
+		text += " · " + a.RuleText
+		if len([]rune(text)) > maxAlertButtonText {
+			text = string([]rune(text)[:maxAlertButtonText]) + "…"
+		}
+		rows = append(rows, markup.Row(markup.Data(text, fmt.Sprintf("alert:%d:%d", a.ID, page))))
+	}
+
+	// Pagination (newest first)
+	var nav []tele.Btn
+	if page > 0 {
+		nav = append(nav, markup.Data("◀ Новее", fmt.Sprintf("alerts:%d", page-1)))
+	}
+	if page+1 < pages {
+		nav = append(nav, markup.Data("Старее ▶", fmt.Sprintf("alerts:%d", page+1)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, markup.Row(nav...))
+	}
+
+	// Filters
+	var statusRow tele.Row
+	for _, status := range alertStatusFilters {
+		text := alertStatusIcon(status)
+		if status == filter.Status {
+			text = "• " + text + " •"
+		}
+		code := status
+		if code == "" {
+			code = "all"
+		}
+		statusRow = append(statusRow, markup.Data(text, "alerts_st:"+code))
+	}
+	rows = append(rows, statusRow)
+
+	machineText := "🏭 Станок: все"
+	if filter.MachineID != "" {
+		machineText = "🏭 Станок: " + filter.MachineID
+	}
+	rows = append(rows, markup.Row(markup.Data(machineText, "alerts_mf")))
+	rows = append(rows, markup.Row(m.BtnHomeInline))
+
+	markup.Inline(rows...)
+	return markup
+}
+
+// BuildAlertMachines - выбор станка для фильтра истории
+func (m *Menu) BuildAlertMachines(machines []string, current string) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+
+	allText := "Все станки"
+	if current == "" {
+		allText = "• " + allText
+	}
+	rows = append(rows, markup.Row(markup.Data(allText, "alerts_m:")))
+	for _, id := range machines {
+		data := "alerts_m:" + id
+		if len(data) > 64 {
+			// Не помещается в callback data Telegram
+			continue
+		}
+		text := id
+		if id == current {
+			text = "• " + text
+		}
+		rows = append(rows, markup.Row(markup.Data(text, data)))
+	}
+	rows = append(rows, markup.Row(markup.Data("🔙 К оповещениям", "alerts:0")))
+
+	markup.Inline(rows...)
+	return markup
+}
+
+// BuildAlertView - карточка оповещения из истории
+func (m *Menu) BuildAlertView(alert entities.Alert, page int) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+
+	btnResolve := markup.Data("✅ Решено", fmt.Sprintf("alert_vres:%d:%d", alert.ID, page))
+	switch alert.Status {
+	case entities.AlertStatusOpen:
+		rows = append(rows, markup.Row(markup.Data("👀 Принять", fmt.Sprintf("alert_vack:%d:%d", alert.ID, page)), btnResolve))
+	case entities.AlertStatusAcked:
+		rows = append(rows, markup.Row(btnResolve))
+	}
+	rows = append(rows, markup.Row(markup.Data("🔙 К оповещениям", fmt.Sprintf("alerts:%d", page))))
+
+	markup.Inline(rows...)
+	return markup
+}
+
+func alertStatusIcon(status string) string {
+	switch status {
+	case entities.AlertStatusOpen:
+		return "🔴"
+	case entities.AlertStatusAcked:
+		return "👀"
+	case entities.AlertStatusResolved:
+		return "✅"
+	}
+	return "📋"
+}
+
+func alertStatusTitle(status string) string {
+	switch status {
+	case entities.AlertStatusOpen:
+		return "🔴 открытые"
+	case entities.AlertStatusAcked:
+		return "👀 в работе"
+	case entities.AlertStatusResolved:
+		return "✅ решенные"
+	}
+	return "все"
+}
+
+// BuildAlertActions - кнопки под уведомлением о сработавшем правиле (пусто после решения)
+func (m *Menu) BuildAlertActions(alert entities.Alert) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	btnResolve := markup.Data("✅ Решено", fmt.Sprintf("alert_res:%d", alert.ID))
+
+	switch alert.Status {
+	case entities.AlertStatusOpen:
+		markup.Inline(markup.Row(markup.Data("👀 Принять", fmt.Sprintf("alert_ack:%d", alert.ID)), btnResolve))
+	case entities.AlertStatusAcked:
+		markup.Inline(markup.Row(btnResolve))
+	default:
+		markup.Inline()
+	}
+	return markup
+}
+
 func (m *Menu) BuildLiveView(targetID, keyID uint) *tele.ReplyMarkup {
 	markup := &tele.ReplyMarkup{}
 	btnStop := markup.Data("⏹ Стоп", fmt.Sprintf("stop_live:%d:%d", targetID, keyID))
@@ -157,11 +516,21 @@ func (m *Menu) BuildLiveView(targetID, keyID uint) *tele.ReplyMarkup {
 
 // --- Services Menus ---
 
-func (m *Menu) BuildServicesList(services []entities.FanucService) *tele.ReplyMarkup {
+func (m *Menu) BuildBulkResult(svcID uint) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(markup.Row(markup.Data("🔙 К сервису", fmt.Sprintf("view_service:%d", svcID))))
+	return markup
+}
+
+func (m *Menu) BuildServicesList(services []entities.FanucService, unreachable map[uint]bool) *tele.ReplyMarkup {
 	markup := &tele.ReplyMarkup{}
 	var rows []tele.Row
 	for _, s := range services {
-		btn := markup.Data(fmt.Sprintf("🌐 %s", s.Name), fmt.Sprintf("view_service:%d", s.ID))
+		title := fmt.Sprintf("🌐 %s", s.Name)
+		if unreachable[s.ID] {
+			title = fmt.Sprintf("🔴 %s · недоступен", s.Name)
+		}
+		btn := markup.Data(title, fmt.Sprintf("view_service:%d", s.ID))
 		rows = append(rows, markup.Row(btn))
 	}
 	rows = append(rows, markup.Row(m.BtnAddService))
@@ -187,7 +556,16 @@ func (m *Menu) BuildServiceView(svcID uint, machines []fanucService.MachineDTO)
 		rows = append(rows, markup.Row(btn))
 	}
 
-	// 2. Service Management
+	// 2. Bulk Actions
+	if len(machines) > 0 {
+		rows = append(rows, markup.Row(
+			markup.Data("▶ Опрос всех", fmt.Sprintf("bulk_sp:%d", svcID)),
+			markup.Data("⏹ Остановить все", fmt.Sprintf("bulk_stp:%d", svcID)),
+		))
+		rows = append(rows, markup.Row(markup.Data("🔁 Проверить все", fmt.Sprintf("bulk_chk:%d", svcID))))
+	}
+
+	// 3. Service Management
 	btnAdd := markup.Data("➕ Подключить станок", fmt.Sprintf("add_conn:%d", svcID))
 	btnDel := markup.Data("🗑 Удалить сервис", fmt.Sprintf("del_service:%d", svcID))
 
@@ -201,7 +579,7 @@ func (m *Menu) BuildServiceView(svcID uint, machines []fanucService.MachineDTO)
 
 // --- Machine Menus ---
 
-func (m *Menu) BuildMachineView(svcID uint, machine fanucService.MachineDTO) *tele.ReplyMarkup {
+func (m *Menu) BuildMachineView(svcID uint, machine fanucService.MachineDTO, subscribed, muted bool) *tele.ReplyMarkup {
 	markup := &tele.ReplyMarkup{}
 
 	var btnPoll tele.Btn
@@ -211,12 +589,24 @@ func (m *Menu) BuildMachineView(svcID uint, machine fanucService.MachineDTO) *te
 		btnPoll = markup.Data("▶ Запустить опрос", fmt.Sprintf("sp:%d:%s", svcID, machine.ID))
 	}
 
+	btnSub := markup.Data("🔔 Уведомлять о статусе", fmt.Sprintf("msub:%d:%s", svcID, machine.ID))
+	if subscribed {
+		btnSub = markup.Data("🔕 Не уведомлять о статусе", fmt.Sprintf("munsub:%d:%s", svcID, machine.ID))
+	}
+
+	btnMute := markup.Data("🔇 Заглушить на 2ч", fmt.Sprintf("mmute:%d:%s", svcID, machine.ID))
+	if muted {
+		btnMute = markup.Data("🔊 Включить уведомления", fmt.Sprintf("munmute:%d:%s", svcID, machine.ID))
+	}
+
 	btnProg := markup.Data("📄 Скачать программу", fmt.Sprintf("gp:%d:%s", svcID, machine.ID))
 	btnDel := markup.Data("🗑 Удалить", fmt.Sprintf("dc:%d:%s", svcID, machine.ID))
 	btnBack := markup.Data("🔙 К сервису", fmt.Sprintf("view_service:%d", svcID))
 
 	markup.Inline(
 		markup.Row(btnPoll),
+		markup.Row(btnSub),
+		markup.Row(btnMute),
 		markup.Row(btnProg),
 		markup.Row(btnDel),
 		markup.Row(btnBack),
@@ -224,6 +614,89 @@ func (m *Menu) BuildMachineView(svcID uint, machine fanucService.MachineDTO) *te
 	return markup
 }
 
+// --- Kafka Auth Wizard ---
+
+func (m *Menu) BuildAuthMechanisms() *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(
+		markup.Row(markup.Data("🔓 Без аутентификации", "auth_mech:none")),
+		markup.Row(markup.Data("PLAIN", "auth_mech:"+models.SASLPlain)),
+		markup.Row(
+			markup.Data("SCRAM-SHA-256", "auth_mech:"+models.SASLScramSHA256),
+			markup.Data("SCRAM-SHA-512", "auth_mech:"+models.SASLScramSHA512),
+		),
+		markup.Row(m.BtnCancelWizard),
+	)
+	return markup
+}
+
+// Максимум топиков в виде кнопок, остальные вводятся текстом
+const maxTopicButtons = 40
+
+// BuildTopics - кнопки выбора топика; callback содержит индекс в отсортированном списке,
+// т.к. имя топика может не поместиться в 64 байта callback data
+func (m *Menu) BuildTopics(topics []models.TopicInfo) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for i, t := range topics {
+		if i == maxTopicButtons {
+			break
+		}
+		btn := markup.Data(fmt.Sprintf("📂 %s (%d)", t.Name, t.Partitions), fmt.Sprintf("pick_topic:%d", i))
+		rows = append(rows, markup.Row(btn))
+	}
+	rows = append(rows, markup.Row(m.BtnCancelWizard))
+	markup.Inline(rows...)
+	return markup
+}
+
+func (m *Menu) BuildTLSOptions() *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(
+		markup.Row(markup.Data("🔓 Без TLS", "tls:off")),
+		markup.Row(markup.Data("🔒 TLS (системные CA)", "tls:on")),
+		markup.Row(markup.Data("⚠️ TLS без проверки сертификата", "tls:insecure")),
+		markup.Row(m.BtnCancelWizard),
+	)
+	return markup
+}
+
+// --- Kafka Key Wizard ---
+
+var keyModeTitles = map[string]string{
+	models.KeyMatchExact:  "Точно",
+	models.KeyMatchPrefix: "Префикс",
+	models.KeyMatchGlob:   "Glob",
+	models.KeyMatchRegex:  "Regex",
+}
+
+func (m *Menu) BuildKeyModes(current, header string) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	var btns []tele.Btn
+	for _, mode := range models.KeyMatchModes {
+		title := keyModeTitles[mode]
+		if mode == current {
+			title = "✅ " + title
+		}
+		btns = append(btns, markup.Data(title, "key_mode:"+mode))
+	}
+
+	// Source of the compared value: record key or header
+	btnSource := markup.Data("🏷 По заголовку", "key_src:header")
+	if header != "" {
+		btnSource = markup.Data("🔑 По ключу записи", "key_src:key")
+	}
+
+	markup.Inline(
+		markup.Row(btns[:2]...),
+		markup.Row(btns[2:]...),
+		markup.Row(btnSource),
+		markup.Row(m.BtnCancelWizard),
+	)
+	return markup
+}
+
 func (m *Menu) BuildCancel() *tele.ReplyMarkup {
 	markup := &tele.ReplyMarkup{}
 	markup.Inline(markup.Row(m.BtnCancelWizard))
@@ -26,6 +26,7 @@ func (r *Router) Register(b *tele.Bot) {
 	// Добавляем обработку новых команд меню
 	b.Handle("/kafka", r.commands.OnKafka)
 	b.Handle("/services", r.commands.OnServices)
+	b.Handle("/alerts", r.commands.OnAlerts)
 
 	// Callbacks & Text
 	// Text хендлер нужен для работы Wizard-ов (ввод IP, имен и т.д.)
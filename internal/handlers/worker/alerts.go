@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+)
+
+const (
+	// Как часто проверяем правила с длительностью "for" без новых сообщений
+	alertCheckInterval = time.Second
+	// Как часто перечитываем правила из БД (удаленные вместе с target и т.п.)
+	// и проверяем неподтвержденные оповещения на повтор и эскалацию
+	alertSyncInterval = 30 * time.Second
+)
+
+// AlertEvaluator проверяет сообщения consumer-а по правилам оповещений
+type AlertEvaluator struct {
+	monitoringUC interfaces.MonitoringUsecase
+	alertUC      interfaces.AlertUsecase
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewAlertEvaluator(monitoringUC interfaces.MonitoringUsecase, alertUC interfaces.AlertUsecase) *AlertEvaluator {
+	return &AlertEvaluator{
+		monitoringUC: monitoringUC,
+		alertUC:      alertUC,
+	}
+}
+
+func (e *AlertEvaluator) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		e.run(ctx)
+	}()
+}
+
+func (e *AlertEvaluator) Stop() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.wg.Wait()
+}
+
+func (e *AlertEvaluator) run(ctx context.Context) {
+	checkTicker := time.NewTicker(alertCheckInterval)
+	defer checkTicker.Stop()
+	syncTicker := time.NewTicker(alertSyncInterval)
+	defer syncTicker.Stop()
+
+	e.reload()
+	events := e.monitoringUC.Events()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			e.alertUC.Evaluate(ctx, ev)
+		case now := <-checkTicker.C:
+			e.alertUC.CheckPending(ctx, now)
+		case now := <-syncTicker.C:
+			e.reload()
+			e.alertUC.CheckEscalations(ctx, now)
+		}
+	}
+}
+
+func (e *AlertEvaluator) reload() {
+	if err := e.alertUC.ReloadRules(); err != nil {
+		log.Printf("⚠️ AlertEvaluator: не удалось загрузить правила: %v", err)
+	}
+}
@@ -1 +1,142 @@
 package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/fanucClient"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+)
+
+const (
+	// Как часто перечитываем список таргетов из БД
+	consumerSyncInterval = 30 * time.Second
+	// Пауза перед переподключением после ошибки чтения
+	consumerRetryDelay = 5 * time.Second
+)
+
+// Consumer подписывается на все уникальные Broker/Topic из MonitoringTarget
+// и передает сообщения в MonitoringUsecase.
+type Consumer struct {
+	cfg          *fanucClient.Config
+	kafkaSvc     interfaces.KafkaReader
+	monitoringUC interfaces.MonitoringUsecase
+
+	mu      sync.Mutex
+	readers map[string]context.CancelFunc // source ID -> cancel
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func NewConsumer(
+	cfg *fanucClient.Config,
+	kafkaSvc interfaces.KafkaReader,
+	monitoringUC interfaces.MonitoringUsecase,
+) *Consumer {
+	return &Consumer{
+		cfg:          cfg,
+		kafkaSvc:     kafkaSvc,
+		monitoringUC: monitoringUC,
+		readers:      make(map[string]context.CancelFunc),
+	}
+}
+
+func (c *Consumer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.run(ctx)
+	}()
+}
+
+func (c *Consumer) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+}
+
+func (c *Consumer) run(ctx context.Context) {
+	ticker := time.NewTicker(consumerSyncInterval)
+	defer ticker.Stop()
+
+	c.sync(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.sync(ctx)
+		case <-c.monitoringUC.SourcesChanged():
+			c.sync(ctx)
+		}
+	}
+}
+
+// sync starts readers for new sources and stops readers of removed ones
+func (c *Consumer) sync(ctx context.Context) {
+	sources, err := c.monitoringUC.ReloadSources()
+	if err != nil {
+		log.Printf("⚠️ Consumer: не удалось загрузить Kafka Targets: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	active := make(map[string]bool, len(sources))
+	for _, src := range sources {
+		active[src.ID()] = true
+		if _, ok := c.readers[src.ID()]; ok {
+			continue
+		}
+
+		readerCtx, cancel := context.WithCancel(ctx)
+		c.readers[src.ID()] = cancel
+
+		c.wg.Add(1)
+		go func(src models.KafkaSource) {
+			defer c.wg.Done()
+			c.consume(readerCtx, src)
+		}(src)
+	}
+
+	for id, cancel := range c.readers {
+		if !active[id] {
+			log.Printf("🔌 Consumer: отписка от %s", id)
+			cancel()
+			delete(c.readers, id)
+		}
+	}
+}
+
+// consume reads the source until ctx is cancelled, reconnecting on errors
+func (c *Consumer) consume(ctx context.Context, src models.KafkaSource) {
+	// Отдельная группа на каждый источник, чтобы подписки не вызывали ребалансировку друг друга
+	groupID := src.GroupID(c.cfg.KafkaGroupID)
+
+	for {
+		log.Printf("📡 Consumer: подписка на %s (group %s)", src.ID(), groupID)
+		err := c.kafkaSvc.Consume(ctx, src, groupID, func(msg models.KafkaMessage) {
+			c.monitoringUC.HandleMessage(src, msg)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("⚠️ Consumer: ошибка чтения %s: %v", src.ID(), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(consumerRetryDelay):
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+)
+
+// Как часто опрашиваем списки станков всех сервисов
+const machineWatchInterval = 30 * time.Second
+
+// MachineWatcher периодически проверяет состояние станков и уведомляет подписчиков об изменениях
+type MachineWatcher struct {
+	watchUC interfaces.MachineWatchUsecase
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewMachineWatcher(watchUC interfaces.MachineWatchUsecase) *MachineWatcher {
+	return &MachineWatcher{watchUC: watchUC}
+}
+
+func (w *MachineWatcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.run(ctx)
+	}()
+}
+
+func (w *MachineWatcher) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+func (w *MachineWatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(machineWatchInterval)
+	defer ticker.Stop()
+
+	w.watchUC.PollServices(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.watchUC.PollServices(ctx)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+)
+
+// Как часто проверяем конец тихих часов и время сводок
+const heldFlushInterval = time.Minute
+
+// HeldNotificationsFlusher доставляет уведомления, отложенные тихими часами и режимом сводки
+type HeldNotificationsFlusher struct {
+	notifyUC interfaces.NotificationUsecase
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewHeldNotificationsFlusher(notifyUC interfaces.NotificationUsecase) *HeldNotificationsFlusher {
+	return &HeldNotificationsFlusher{notifyUC: notifyUC}
+}
+
+func (f *HeldNotificationsFlusher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.run(ctx)
+	}()
+}
+
+func (f *HeldNotificationsFlusher) Stop() {
+	if f.cancel != nil {
+		f.cancel()
+	}
+	f.wg.Wait()
+}
+
+func (f *HeldNotificationsFlusher) run(ctx context.Context) {
+	ticker := time.NewTicker(heldFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			f.notifyUC.FlushHeld(ctx, now)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+)
+
+// Как часто проверяем расписание отчетов
+const shiftReportCheckInterval = time.Minute
+
+// ShiftReporter отправляет отчеты за смену по расписанию пользователей
+type ShiftReporter struct {
+	reportUC interfaces.ReportUsecase
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewShiftReporter(reportUC interfaces.ReportUsecase) *ShiftReporter {
+	return &ShiftReporter{reportUC: reportUC}
+}
+
+func (r *ShiftReporter) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(ctx)
+	}()
+}
+
+func (r *ShiftReporter) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+func (r *ShiftReporter) run(ctx context.Context) {
+	ticker := time.NewTicker(shiftReportCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.reportUC.CheckSchedules(ctx, now)
+		}
+	}
+}
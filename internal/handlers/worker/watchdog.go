@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+)
+
+// Как часто проверяем время последних сообщений (минимальная настройка тишины - 1 минута)
+const silenceCheckInterval = 30 * time.Second
+
+// SilenceWatchdog оповещает владельцев (target, key), в которые перестали поступать сообщения
+type SilenceWatchdog struct {
+	watchdogUC interfaces.WatchdogUsecase
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewSilenceWatchdog(watchdogUC interfaces.WatchdogUsecase) *SilenceWatchdog {
+	return &SilenceWatchdog{watchdogUC: watchdogUC}
+}
+
+func (w *SilenceWatchdog) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.run(ctx)
+	}()
+}
+
+func (w *SilenceWatchdog) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+func (w *SilenceWatchdog) run(ctx context.Context) {
+	ticker := time.NewTicker(silenceCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.watchdogUC.CheckSilence(ctx)
+		}
+	}
+}
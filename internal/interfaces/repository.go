@@ -1,6 +1,11 @@
 package interfaces
 
-import "github.com/iwtcode/fanucClient/internal/domain/entities"
+import (
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+)
 
 type UserRepository interface {
 	Save(user *entities.User) error
@@ -15,10 +20,13 @@ type UserRepository interface {
 	DeleteTarget(targetID uint, userID int64) error
 	GetTargets(userID int64) ([]entities.MonitoringTarget, error)
 	GetTargetByID(targetID uint) (*entities.MonitoringTarget, error)
+	GetAllTargets() ([]entities.MonitoringTarget, error)
+	UpdateTarget(targetID uint, updates map[string]interface{}) error
 
 	// Kafka Keys
 	AddKey(key *entities.MonitoringKey) error
 	DeleteKey(keyID uint) error
+	UpdateKey(keyID uint, updates map[string]interface{}) error
 	GetKeyByID(keyID uint) (*entities.MonitoringKey, error)
 
 	// Fanuc Services
@@ -26,4 +34,72 @@ type UserRepository interface {
 	DeleteService(svcID uint, userID int64) error
 	GetServices(userID int64) ([]entities.FanucService, error)
 	GetServiceByID(svcID uint) (*entities.FanucService, error)
+	GetAllServices() ([]entities.FanucService, error)
+
+	// Machine Status
+	AddMachineSubscription(sub *entities.MachineSubscription) error
+	DeleteMachineSubscription(userID int64, svcID uint, machineID string) error
+	// GetMachineSubscription returns nil if the user is not subscribed
+	GetMachineSubscription(userID int64, svcID uint, machineID string) (*entities.MachineSubscription, error)
+	GetMachineSubscribers(svcID uint, machineID string) ([]entities.MachineSubscription, error)
+	AddMachineStatus(entry *entities.MachineStatusLog) error
+	// GetLastMachineStatus returns nil if the machine has no recorded status
+	GetLastMachineStatus(svcID uint, machineID string) (*entities.MachineStatusLog, error)
+	// GetMachineStatusesAt returns the last recorded status of every machine of the service before the moment
+	GetMachineStatusesAt(svcID uint, at time.Time) ([]entities.MachineStatusLog, error)
+	// GetMachineStatusLog returns status changes of the service machines in [from, to) in order
+	GetMachineStatusLog(svcID uint, from, to time.Time) ([]entities.MachineStatusLog, error)
+
+	// Alert Rules
+	AddAlertRule(rule *entities.AlertRule) error
+	DeleteAlertRule(ruleID uint, userID int64) error
+	UpdateAlertRule(ruleID uint, updates map[string]interface{}) error
+	GetAlertRuleByID(ruleID uint) (*entities.AlertRule, error)
+	// GetAlertRules returns rules of the (target, key) pair, keyID == 0 for the default entry point
+	GetAlertRules(targetID, keyID uint) ([]entities.AlertRule, error)
+	GetEnabledAlertRules() ([]entities.AlertRule, error)
+
+	// Alerts
+	AddAlert(alert *entities.Alert) error
+	GetAlertByID(alertID uint) (*entities.Alert, error)
+	UpdateAlert(alertID uint, updates map[string]interface{}) error
+	// TransitionAlert applies updates only if the alert is in one of the given statuses,
+	// returns false if another user has already moved it
+	TransitionAlert(alertID uint, from []string, updates map[string]interface{}) (bool, error)
+	GetOpenAlerts() ([]entities.Alert, error)
+	// GetAlerts returns a page of alerts newest first and the total count matching the filter
+	GetAlerts(filter models.AlertFilter, offset, limit int) ([]entities.Alert, int64, error)
+	// GetAlertMachines returns distinct machine IDs of the user's alerts
+	GetAlertMachines(userID int64) ([]string, error)
+	// GetAlertsFiredBetween returns alerts of the user's rules fired in [from, to)
+	GetAlertsFiredBetween(userID int64, from, to time.Time) ([]entities.Alert, error)
+	AddAlertEvent(event *entities.AlertEvent) error
+	GetAlertEvents(alertID uint) ([]entities.AlertEvent, error)
+
+	// Notification Preferences
+	// GetNotificationSettings returns nil if the user has not changed the defaults
+	GetNotificationSettings(userID int64) (*entities.NotificationSettings, error)
+	SaveNotificationSettings(settings *entities.NotificationSettings) error
+	UpdateNotificationSettings(userID int64, updates map[string]interface{}) error
+	// GetReportSchedules returns settings of users with shift reports enabled
+	GetReportSchedules() ([]entities.NotificationSettings, error)
+	// SaveMachineMute creates or prolongs the mute of the (user, machine) pair
+	SaveMachineMute(mute *entities.MachineMute) error
+	DeleteMachineMute(userID int64, machineID string) error
+	// GetActiveMachineMutes returns mutes of the user that have not expired at the given time
+	GetActiveMachineMutes(userID int64, now time.Time) ([]entities.MachineMute, error)
+	DeleteExpiredMachineMutes(now time.Time) error
+	AddHeldNotification(n *entities.HeldNotification) error
+	GetHeldNotifications() ([]entities.HeldNotification, error)
+	DeleteHeldNotifications(ids []uint) error
+
+	// Silence Watchdog
+	// SaveSilenceWatch creates or updates the max silence of the (target, key) pair
+	SaveSilenceWatch(watch *entities.SilenceWatch) error
+	DeleteSilenceWatch(targetID, keyID uint) error
+	// GetSilenceWatch returns nil if the (target, key) pair is not watched
+	GetSilenceWatch(targetID, keyID uint) (*entities.SilenceWatch, error)
+	GetAllSilenceWatches() ([]entities.SilenceWatch, error)
+	GetUserSilenceWatches(userID int64) ([]entities.SilenceWatch, error)
+	UpdateSilenceWatch(watchID uint, updates map[string]interface{}) error
 }
@@ -2,15 +2,46 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
+	"github.com/iwtcode/fanucClient/internal/domain/models"
 	"github.com/iwtcode/fanucService"
 )
 
 type KafkaReader interface {
-	// GetLastMessage returns (key, value, error)
-	GetLastMessage(ctx context.Context, broker, topic, keyFilter string) (string, string, error)
+	// GetLastMessage returns the newest matching record; if there is none, Value holds a human readable reason
+	GetLastMessage(ctx context.Context, src models.KafkaSource, filter models.KeyFilter) (models.KafkaMessage, error)
+
+	// Consume reads the source as a member of the consumer group and calls handle for every message.
+	// Blocks until ctx is cancelled or the reader fails.
+	Consume(ctx context.Context, src models.KafkaSource, groupID string, handle func(models.KafkaMessage)) error
+
+	// GetClusterInfo returns brokers, controller and partition leaders of the topic
+	// GetPartitionStats returns offsets of each partition, the number of records written after since
+	// and the timestamp of the newest record
+	GetPartitionStats(ctx context.Context, src models.KafkaSource, since time.Time) ([]models.PartitionStats, error)
+	// GetCommittedOffsets returns offsets committed by the consumer group (partitions without a commit are omitted)
+	GetCommittedOffsets(ctx context.Context, src models.KafkaSource, groupID string) (map[int]int64, error)
+	// ListTopics returns user topics of the cluster sorted by name (src.Topic is ignored)
+	ListTopics(ctx context.Context, src models.KafkaSource) ([]models.TopicInfo, error)
+	GetClusterInfo(ctx context.Context, src models.KafkaSource) (*models.ClusterInfo, error)
+
+	// GetPartitionOffsets returns first and last (next to be written) offsets of every partition
+	GetPartitionOffsets(ctx context.Context, src models.KafkaSource) ([]models.PartitionOffsets, error)
+	// GetOffsetsForTime returns, per partition, the offset of the first record with timestamp >= t
+	// (end of the partition if there is none)
+	GetOffsetsForTime(ctx context.Context, src models.KafkaSource, t time.Time) (map[int]int64, error)
+	// ReadRange reads records [startOffset, endOffset) of the partition, clamped to retained offsets
+	ReadRange(ctx context.Context, src models.KafkaSource, partition int, startOffset, endOffset int64) ([]models.KafkaMessage, error)
 }
 
+// NotificationSender delivers messages to Telegram users without exposing the bot to usecases
+type NotificationSender interface {
+	Send(ctx context.Context, n models.Notification) error
+}
+
+// FanucApiService calls fanucService with per-call timeouts; idempotent reads are retried,
+// and a per-host circuit breaker fails calls fast while the service is unreachable
 type FanucApiService interface {
 	// Connection Management
 	CreateConnection(ctx context.Context, baseURL, apiKey string, req fanucService.ConnectionRequest) (*fanucService.MachineDTO, error)
@@ -24,4 +55,8 @@ type FanucApiService interface {
 
 	// Program Management
 	GetControlProgram(ctx context.Context, baseURL, apiKey, machineID string) (string, error)
+
+	// Availability
+	// IsUnreachable reports an open circuit breaker of the host without calling it
+	IsUnreachable(baseURL string) bool
 }
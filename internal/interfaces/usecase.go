@@ -2,8 +2,10 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
 	"github.com/iwtcode/fanucService"
 )
 
@@ -11,11 +13,19 @@ type SettingsUsecase interface {
 	RegisterUser(user *entities.User) error
 	GetUser(id int64) (*entities.User, error)
 	SetState(id int64, state string) error
+	// CancelWizard returns the user to idle and clears credentials left in the wizard drafts
+	CancelWizard(id int64) error
 
 	// Context Helpers for Wizards
 	SetContextSvcID(userID int64, svcID uint) error
 	SetContextMachineID(userID int64, machineID string) error
 	SetContextTargetID(userID int64, targetID uint) error
+	SetContextKeyID(userID int64, keyID uint) error
+	SetContextRuleID(userID int64, ruleID uint) error
+
+	// Alert History Filters
+	SetAlertFilterStatus(userID int64, status string) error
+	SetAlertFilterMachine(userID int64, machineID string) error
 
 	// Connection Wizard Steps
 	SetDraftConnEndpoint(userID int64, endpoint string) error
@@ -25,15 +35,30 @@ type SettingsUsecase interface {
 	// Kafka Targets Management
 	SetDraftName(id int64, name string) error
 	SetDraftBroker(id int64, broker string) error
-	SetDraftTopicAndSave(id int64, topic string) error
+	SetDraftAuthMechanism(id int64, mechanism string) error
+	SetDraftAuthUser(id int64, username string) error
+	SetDraftAuthPassword(id int64, password string) error
+	SetDraftTLS(id int64, enabled, insecure bool, caCert string) error
+	// SetTargetConsumerGroup sets the group whose lag is shown in topic stats (empty = off)
+	SetTargetConsumerGroup(userID int64, targetID uint, group string) error
+	// ListDraftTopics connects with the draft broker/auth settings and lists available topics
+	ListDraftTopics(ctx context.Context, id int64) ([]models.TopicInfo, error)
+	// SetDraftTopicAndSave checks that the cluster is reachable and the topic exists before saving
+	SetDraftTopicAndSave(ctx context.Context, id int64, topic string) error
 
 	GetTargets(userID int64) ([]entities.MonitoringTarget, error)
 	DeleteTarget(userID int64, targetID uint) error
 	GetTargetByID(targetID uint) (*entities.MonitoringTarget, error)
 
 	// Kafka Key Management
+	SetDraftKeyMode(userID int64, mode string) error
+	SetDraftKeyHeader(userID int64, header string) error
+	// AddKeyToTarget validates the key against the draft match mode and saves it
 	AddKeyToTarget(userID int64, key string) error
 	DeleteKey(keyID uint) error
+	// SetKeyProjection / SetKeyPredicate validate and save JSON filters of the key (empty = off)
+	SetKeyProjection(userID int64, keyID uint, projection string) error
+	SetKeyPredicate(userID int64, keyID uint, predicate string) error
 	GetKeyByID(keyID uint) (*entities.MonitoringKey, error)
 
 	// Fanuc Services Management
@@ -47,8 +72,112 @@ type SettingsUsecase interface {
 
 type MonitoringUsecase interface {
 	// keyID == 0 means "no key" (default)
-	// Returns: foundKey, foundValue, error
-	FetchLastKafkaMessage(ctx context.Context, targetID uint, keyID uint) (string, string, error)
+	// Returns the record with key, headers and value; if nothing matched, Value holds the reason
+	FetchLastKafkaMessage(ctx context.Context, targetID uint, keyID uint) (models.KafkaMessage, error)
+	GetClusterInfo(ctx context.Context, targetID uint) (*models.ClusterInfo, error)
+	// GetTopicStats summarizes offsets, throughput, freshness and consumer group lag of the target topic
+	GetTopicStats(ctx context.Context, targetID uint) (*models.TopicStats, error)
+	// FetchHistory returns up to limit latest messages for (target, key), newest first
+	FetchHistory(ctx context.Context, targetID uint, keyID uint, limit int) ([]models.KafkaMessage, error)
+	// LastMessageTime returns the timestamp of the newest message of (target, key), zero if there is none
+	LastMessageTime(ctx context.Context, targetID uint, keyID uint) (time.Time, error)
+	// DecodeStatus decodes a fanucService telemetry payload; false for unknown shapes
+	DecodeStatus(value string) (*models.MachineStatus, bool)
+	// ExportMessages reads the range and encodes it as JSONL or CSV (large files are gzip-compressed)
+	ExportMessages(ctx context.Context, targetID uint, keyID uint, rng models.ExportRange, format string) (*models.ExportFile, error)
+	// FetchMessageAt returns the message closest to the wall-clock time (nil if nothing is retained around it)
+	FetchMessageAt(ctx context.Context, targetID uint, keyID uint, at time.Time) (*models.KafkaMessage, error)
+	// FetchNeighbour returns the next newer (or older) message relative to pos (nil if there is none nearby)
+	FetchNeighbour(ctx context.Context, targetID uint, keyID uint, pos models.MessagePosition, newer bool) (*models.KafkaMessage, error)
+
+	// Background Consumer
+	// ReloadSources re-reads targets from DB and returns distinct sources to subscribe to
+	ReloadSources() ([]models.KafkaSource, error)
+	// HandleMessage stores the message as the latest one for every matching (target, key)
+	// and pushes it to the Live Mode subscribers
+	HandleMessage(src models.KafkaSource, msg models.KafkaMessage)
+	// SourcesChanged signals the consumer that sources should be reloaded before the next sync tick
+	SourcesChanged() <-chan struct{}
+	// Events delivers every message accepted by a (target, key) to the alert evaluator
+	Events() <-chan models.TelemetryEvent
+
+	// Live Mode
+	// Subscribe returns a channel with new messages for (target, key) and a function to unsubscribe
+	Subscribe(targetID uint, keyID uint) (<-chan models.KafkaMessage, func())
+}
+
+type AlertUsecase interface {
+	// Rules Management
+	// CreateRule parses "<condition> [for <duration>]" and saves the rule for the target owner
+	CreateRule(userID int64, targetID, keyID uint, expr string) (*entities.AlertRule, error)
+	GetRules(targetID, keyID uint) ([]entities.AlertRule, error)
+	GetRule(ruleID uint) (*entities.AlertRule, error)
+	ToggleRule(userID int64, ruleID uint) error
+	DeleteRule(userID int64, ruleID uint) error
+	SetRuleSeverity(userID int64, ruleID uint, severity string) error
+	// SetRenotify sets how often an unacknowledged alert of the rule is repeated (0 = off)
+	SetRenotify(userID int64, ruleID uint, interval time.Duration) error
+	// SetEscalation parses "<delay> <id>, <id>..." ("-" = off) and saves the escalation of the rule
+	SetEscalation(userID int64, ruleID uint, expr string) error
+
+	// Alerts Workflow
+	// GetAlert returns the alert if the user is its owner or escalation recipient
+	GetAlert(userID int64, alertID uint) (*entities.Alert, error)
+	ListAlerts(filter models.AlertFilter, offset, limit int) ([]entities.Alert, int64, error)
+	GetAlertMachines(userID int64) ([]string, error)
+	GetAlertEvents(alertID uint) ([]entities.AlertEvent, error)
+	// Acknowledge and Resolve are allowed to the rule owner and escalation recipients
+	Acknowledge(userID int64, userName string, alertID uint) (*entities.Alert, error)
+	Resolve(userID int64, userName string, alertID uint) (*entities.Alert, error)
+	// CheckEscalations repeats and escalates alerts nobody has acknowledged
+	CheckEscalations(ctx context.Context, now time.Time)
+
+	// Background Evaluator
+	// ReloadRules re-reads enabled rules from DB keeping the state of unchanged ones
+	ReloadRules() error
+	// Evaluate checks the message against the rules of its (target, key);
+	// messages written before the bot started (replayed by the consumer group) are skipped
+	Evaluate(ctx context.Context, ev models.TelemetryEvent)
+	// CheckPending fires rules whose condition has held for the required duration
+	CheckPending(ctx context.Context, now time.Time)
+}
+
+type NotificationUsecase interface {
+	// Notify delivers the notification honouring the recipient's preferences:
+	// muted machines and low severity are dropped, quiet hours and digest mode hold it for later
+	Notify(ctx context.Context, n models.Notification) error
+	// FlushHeld sends held notifications as a digest once quiet hours are over or the digest is due
+	FlushHeld(ctx context.Context, now time.Time)
+
+	// Preferences
+	// GetSettings returns defaults if the user has not changed anything
+	GetSettings(userID int64) (*entities.NotificationSettings, error)
+	SetTimeZone(userID int64, name string) error
+	// SetQuietHours parses "22:00-07:00" ("-" = off)
+	SetQuietHours(userID int64, expr string) error
+	SetMinSeverity(userID int64, severity string) error
+	// SetDigestInterval switches to digests every interval (0 = instant notifications)
+	SetDigestInterval(userID int64, interval time.Duration) error
+
+	// Machine Mutes
+	MuteMachine(userID int64, machineID string, d time.Duration) error
+	UnmuteMachine(userID int64, machineID string) error
+	GetMutes(userID int64) ([]entities.MachineMute, error)
+	IsMuted(userID int64, machineID string) (bool, error)
+}
+
+type ReportUsecase interface {
+	// BuildReport collects machine availability, polling uptime, alerts and silent topics over [from, to)
+	BuildReport(userID int64, from, to time.Time) (*models.ShiftReport, error)
+	// SendReportNow sends the report for the current shift (the last 24h without a schedule)
+	SendReportNow(ctx context.Context, userID int64) error
+	// CheckSchedules sends reports for shifts finished since the last report
+	CheckSchedules(ctx context.Context, now time.Time)
+
+	// Schedule
+	// SetReportTimes parses "08:00, 20:00" ("-" = off)
+	SetReportTimes(userID int64, expr string) error
+	SetReportCSV(userID int64, enabled bool) error
 }
 
 type ControlUsecase interface {
@@ -62,4 +191,36 @@ type ControlUsecase interface {
 	StartPolling(ctx context.Context, svcID uint, machineID string, intervalMs int) error
 	StopPolling(ctx context.Context, svcID uint, machineID string) error
 	GetProgram(ctx context.Context, svcID uint, machineID string) (string, error)
+
+	// Bulk Actions
+	// RunBulk applies the action (models.Bulk*) to every machine of the service concurrently.
+	// progress receives a snapshot of all results each time a machine is done;
+	// it is never called concurrently and never gets an older snapshot after a newer one.
+	RunBulk(ctx context.Context, svcID uint, action string, intervalMs int, progress func([]models.BulkResult)) ([]models.BulkResult, error)
+
+	// Availability
+	// IsUnreachable reports that recent calls to the service failed, without calling it
+	IsUnreachable(svc entities.FanucService) bool
+}
+
+type WatchdogUsecase interface {
+	// SetMaxSilence sets the allowed pause between messages of (target, key); 0 turns the watchdog off
+	SetMaxSilence(userID int64, targetID, keyID uint, d time.Duration) error
+	// GetSilenceWatch returns nil if the pair is not watched
+	GetSilenceWatch(targetID, keyID uint) (*entities.SilenceWatch, error)
+
+	// Background Watchdog
+	// CheckSilence alerts owners of silent (target, key) pairs and reports recovery when data resumes
+	CheckSilence(ctx context.Context)
+}
+
+type MachineWatchUsecase interface {
+	// Subscriptions to machine status changes
+	SetSubscription(userID int64, svcID uint, machineID string, subscribed bool) error
+	IsSubscribed(userID int64, svcID uint, machineID string) (bool, error)
+
+	// Background Watcher
+	// PollServices lists machines of every registered service, records status changes
+	// and notifies subscribed users
+	PollServices(ctx context.Context)
 }
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --- Alert Rules ---
+
+func (r *userRepository) AddAlertRule(rule *entities.AlertRule) error {
+	return r.db.Create(rule).Error
+}
+
+func (r *userRepository) DeleteAlertRule(ruleID uint, userID int64) error {
+	return r.db.Delete(&entities.AlertRule{}, "id = ? AND user_id = ?", ruleID, userID).Error
+}
+
+func (r *userRepository) UpdateAlertRule(ruleID uint, updates map[string]interface{}) error {
+	return r.db.Model(&entities.AlertRule{}).Where("id = ?", ruleID).Updates(updates).Error
+}
+
+func (r *userRepository) GetAlertRuleByID(ruleID uint) (*entities.AlertRule, error) {
+	var rule entities.AlertRule
+	err := r.db.First(&rule, "id = ?", ruleID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *userRepository) GetAlertRules(targetID, keyID uint) ([]entities.AlertRule, error) {
+	var rules []entities.AlertRule
+	err := r.db.Where("target_id = ? AND key_id = ?", targetID, keyID).Order("id").Find(&rules).Error
+	return rules, err
+}
+
+func (r *userRepository) GetEnabledAlertRules() ([]entities.AlertRule, error) {
+	var rules []entities.AlertRule
+	err := r.db.Where("enabled = ?", true).Find(&rules).Error
+	return rules, err
+}
+
+// --- Silence Watchdog ---
+
+func (r *userRepository) SaveSilenceWatch(watch *entities.SilenceWatch) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "target_id"}, {Name: "key_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_silence_sec", "user_id"}),
+	}).Create(watch).Error
+}
+
+func (r *userRepository) DeleteSilenceWatch(targetID, keyID uint) error {
+	return r.db.Delete(&entities.SilenceWatch{}, "target_id = ? AND key_id = ?", targetID, keyID).Error
+}
+
+func (r *userRepository) GetSilenceWatch(targetID, keyID uint) (*entities.SilenceWatch, error) {
+	var watch entities.SilenceWatch
+	err := r.db.First(&watch, "target_id = ? AND key_id = ?", targetID, keyID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &watch, nil
+}
+
+func (r *userRepository) GetAllSilenceWatches() ([]entities.SilenceWatch, error) {
+	var watches []entities.SilenceWatch
+	err := r.db.Find(&watches).Error
+	return watches, err
+}
+
+func (r *userRepository) GetUserSilenceWatches(userID int64) ([]entities.SilenceWatch, error) {
+	var watches []entities.SilenceWatch
+	err := r.db.Where("user_id = ?", userID).Order("id").Find(&watches).Error
+	return watches, err
+}
+
+func (r *userRepository) UpdateSilenceWatch(watchID uint, updates map[string]interface{}) error {
+	return r.db.Model(&entities.SilenceWatch{}).Where("id = ?", watchID).Updates(updates).Error
+}
+
+// --- Alerts ---
+
+func (r *userRepository) AddAlert(alert *entities.Alert) error {
+	return r.db.Create(alert).Error
+}
+
+func (r *userRepository) GetAlertByID(alertID uint) (*entities.Alert, error) {
+	var alert entities.Alert
+	err := r.db.First(&alert, "id = ?", alertID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+func (r *userRepository) UpdateAlert(alertID uint, updates map[string]interface{}) error {
+	return r.db.Model(&entities.Alert{}).Where("id = ?", alertID).Updates(updates).Error
+}
+
+func (r *userRepository) TransitionAlert(alertID uint, from []string, updates map[string]interface{}) (bool, error) {
+	res := r.db.Model(&entities.Alert{}).Where("id = ? AND status IN ?", alertID, from).Updates(updates)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *userRepository) GetOpenAlerts() ([]entities.Alert, error) {
+	var alerts []entities.Alert
+	err := r.db.Where("status = ?", entities.AlertStatusOpen).Order("id").Find(&alerts).Error
+	return alerts, err
+}
+
+func (r *userRepository) GetAlerts(filter models.AlertFilter, offset, limit int) ([]entities.Alert, int64, error) {
+	var total int64
+	if err := r.userAlerts(filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var alerts []entities.Alert
+	err := r.userAlerts(filter).
+		Order("fired_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&alerts).Error
+	return alerts, total, err
+}
+
+func (r *userRepository) GetAlertMachines(userID int64) ([]string, error) {
+	var machines []string
+	err := r.userAlerts(models.AlertFilter{UserID: userID}).
+		Where("machine_id <> ''").
+		Distinct("machine_id").
+		Order("machine_id").
+		Pluck("machine_id", &machines).Error
+	return machines, err
+}
+
+func (r *userRepository) GetAlertsFiredBetween(userID int64, from, to time.Time) ([]entities.Alert, error) {
+	var alerts []entities.Alert
+	err := r.db.Where("user_id = ? AND fired_at >= ? AND fired_at < ?", userID, from, to).Order("fired_at").Find(&alerts).Error
+	return alerts, err
+}
+
+// userAlerts selects alerts of the user's rules and alerts escalated to the user
+func (r *userRepository) userAlerts(filter models.AlertFilter) *gorm.DB {
+	q := r.db.Model(&entities.Alert{}).
+		Where("(user_id = ? OR ',' || escalated_to || ',' LIKE ?)", filter.UserID, fmt.Sprintf("%%,%d,%%", filter.UserID))
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.MachineID != "" {
+		q = q.Where("machine_id = ?", filter.MachineID)
+	}
+	return q
+}
+
+func (r *userRepository) AddAlertEvent(event *entities.AlertEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r *userRepository) GetAlertEvents(alertID uint) ([]entities.AlertEvent, error) {
+	var events []entities.AlertEvent
+	err := r.db.Where("alert_id = ?", alertID).Order("id").Find(&events).Error
+	return events, err
+}
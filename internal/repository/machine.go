@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --- Machine Subscriptions ---
+
+func (r *userRepository) AddMachineSubscription(sub *entities.MachineSubscription) error {
+	// Repeated subscription is a no-op
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(sub).Error
+}
+
+func (r *userRepository) DeleteMachineSubscription(userID int64, svcID uint, machineID string) error {
+	return r.db.Delete(&entities.MachineSubscription{},
+		"user_id = ? AND service_id = ? AND machine_id = ?", userID, svcID, machineID).Error
+}
+
+func (r *userRepository) GetMachineSubscription(userID int64, svcID uint, machineID string) (*entities.MachineSubscription, error) {
+	var sub entities.MachineSubscription
+	err := r.db.First(&sub, "user_id = ? AND service_id = ? AND machine_id = ?", userID, svcID, machineID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *userRepository) GetMachineSubscribers(svcID uint, machineID string) ([]entities.MachineSubscription, error) {
+	var subs []entities.MachineSubscription
+	err := r.db.Where("service_id = ? AND machine_id = ?", svcID, machineID).Find(&subs).Error
+	return subs, err
+}
+
+// --- Machine Status Log ---
+
+func (r *userRepository) AddMachineStatus(entry *entities.MachineStatusLog) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *userRepository) GetMachineStatusesAt(svcID uint, at time.Time) ([]entities.MachineStatusLog, error) {
+	var entries []entities.MachineStatusLog
+	err := r.db.Raw(`SELECT DISTINCT ON (machine_id) * FROM machine_status_logs
+		WHERE service_id = ? AND created_at < ?
+		ORDER BY machine_id, created_at DESC, id DESC`, svcID, at).Scan(&entries).Error
+	return entries, err
+}
+
+func (r *userRepository) GetMachineStatusLog(svcID uint, from, to time.Time) ([]entities.MachineStatusLog, error) {
+	var entries []entities.MachineStatusLog
+	err := r.db.Where("service_id = ? AND created_at >= ? AND created_at < ?", svcID, from, to).
+		Order("created_at, id").
+		Find(&entries).Error
+	return entries, err
+}
+
+func (r *userRepository) GetLastMachineStatus(svcID uint, machineID string) (*entities.MachineStatusLog, error) {
+	var entry entities.MachineStatusLog
+	err := r.db.Where("service_id = ? AND machine_id = ?", svcID, machineID).Order("created_at DESC, id DESC").First(&entry).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
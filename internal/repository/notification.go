@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --- Notification Preferences ---
+
+func (r *userRepository) GetNotificationSettings(userID int64) (*entities.NotificationSettings, error) {
+	var settings entities.NotificationSettings
+	err := r.db.First(&settings, "user_id = ?", userID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *userRepository) SaveNotificationSettings(settings *entities.NotificationSettings) error {
+	return r.db.Save(settings).Error
+}
+
+func (r *userRepository) UpdateNotificationSettings(userID int64, updates map[string]interface{}) error {
+	return r.db.Model(&entities.NotificationSettings{}).Where("user_id = ?", userID).Updates(updates).Error
+}
+
+func (r *userRepository) GetReportSchedules() ([]entities.NotificationSettings, error) {
+	var schedules []entities.NotificationSettings
+	err := r.db.Where("report_times <> ''").Find(&schedules).Error
+	return schedules, err
+}
+
+// --- Machine Mutes ---
+
+func (r *userRepository) SaveMachineMute(mute *entities.MachineMute) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "machine_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"until"}),
+	}).Create(mute).Error
+}
+
+func (r *userRepository) DeleteMachineMute(userID int64, machineID string) error {
+	return r.db.Delete(&entities.MachineMute{}, "user_id = ? AND machine_id = ?", userID, machineID).Error
+}
+
+func (r *userRepository) GetActiveMachineMutes(userID int64, now time.Time) ([]entities.MachineMute, error) {
+	var mutes []entities.MachineMute
+	err := r.db.Where("user_id = ? AND until > ?", userID, now).Order("machine_id").Find(&mutes).Error
+	return mutes, err
+}
+
+func (r *userRepository) DeleteExpiredMachineMutes(now time.Time) error {
+	return r.db.Delete(&entities.MachineMute{}, "until <= ?", now).Error
+}
+
+// --- Held Notifications ---
+
+func (r *userRepository) AddHeldNotification(n *entities.HeldNotification) error {
+	return r.db.Create(n).Error
+}
+
+func (r *userRepository) GetHeldNotifications() ([]entities.HeldNotification, error) {
+	var held []entities.HeldNotification
+	err := r.db.Order("id").Find(&held).Error
+	return held, err
+}
+
+func (r *userRepository) DeleteHeldNotifications(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Delete(&entities.HeldNotification{}, ids).Error
+}
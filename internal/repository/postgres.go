@@ -54,9 +54,34 @@ func NewPostgresRepository(cfg *fanucClient.Config) *gorm.DB {
 		&entities.MonitoringTarget{},
 		&entities.MonitoringKey{},
 		&entities.FanucService{},
+		&entities.AlertRule{},
+		&entities.SilenceWatch{},
+		&entities.Alert{},
+		&entities.AlertEvent{},
+		&entities.NotificationSettings{},
+		&entities.MachineMute{},
+		&entities.HeldNotification{},
+		&entities.MachineSubscription{},
+		&entities.MachineStatusLog{},
 	); err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	// 4. AutoMigrate does not alter existing constraints: alerts used to be deleted with their target
+	var cascade bool
+	cascadeQuery := "SELECT EXISTS(SELECT 1 FROM pg_constraint WHERE conname = 'fk_monitoring_targets_alerts' AND confdeltype = 'c')"
+	if err := db.Raw(cascadeQuery).Scan(&cascade).Error; err != nil {
+		log.Fatalf("Failed to check alerts constraint: %v", err)
+	}
+	if cascade {
+		m := db.Migrator()
+		if err := m.DropConstraint(&entities.MonitoringTarget{}, "Alerts"); err != nil {
+			log.Fatalf("Failed to drop alerts constraint: %v", err)
+		}
+		if err := m.CreateConstraint(&entities.MonitoringTarget{}, "Alerts"); err != nil {
+			log.Fatalf("Failed to create alerts constraint: %v", err)
+		}
+	}
+
 	return db
 }
@@ -72,6 +72,17 @@ func (r *userRepository) GetTargetByID(targetID uint) (*entities.MonitoringTarge
 	return &t, nil
 }
 
+func (r *userRepository) GetAllTargets() ([]entities.MonitoringTarget, error) {
+	var targets []entities.MonitoringTarget
+	// Все таргеты всех пользователей вместе с ключами (для фонового consumer)
+	err := r.db.Preload("Keys").Find(&targets).Error
+	return targets, err
+}
+
+func (r *userRepository) UpdateTarget(targetID uint, updates map[string]interface{}) error {
+	return r.db.Model(&entities.MonitoringTarget{}).Where("id = ?", targetID).Updates(updates).Error
+}
+
 // --- Keys ---
 
 func (r *userRepository) AddKey(key *entities.MonitoringKey) error {
@@ -79,7 +90,20 @@ func (r *userRepository) AddKey(key *entities.MonitoringKey) error {
 }
 
 func (r *userRepository) DeleteKey(keyID uint) error {
-	return r.db.Delete(&entities.MonitoringKey{}, "id = ?", keyID).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Rules and watches of the key are not linked by a foreign key (KeyID == 0 is the default entry point)
+		if err := tx.Delete(&entities.AlertRule{}, "key_id = ?", keyID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&entities.SilenceWatch{}, "key_id = ?", keyID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entities.MonitoringKey{}, "id = ?", keyID).Error
+	})
+}
+
+func (r *userRepository) UpdateKey(keyID uint, updates map[string]interface{}) error {
+	return r.db.Model(&entities.MonitoringKey{}).Where("id = ?", keyID).Updates(updates).Error
 }
 
 func (r *userRepository) GetKeyByID(keyID uint) (*entities.MonitoringKey, error) {
@@ -107,6 +131,13 @@ func (r *userRepository) GetServices(userID int64) ([]entities.FanucService, err
 	return services, err
 }
 
+func (r *userRepository) GetAllServices() ([]entities.FanucService, error) {
+	var services []entities.FanucService
+	// Все сервисы всех пользователей (для фонового наблюдателя за станками)
+	err := r.db.Find(&services).Error
+	return services, err
+}
+
 func (r *userRepository) GetServiceByID(svcID uint) (*entities.FanucService, error) {
 	var s entities.FanucService
 	err := r.db.First(&s, "id = ?", svcID).Error
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// Таймаут одной попытки: чтение (список станков, проверка, программа) и изменения
+	fanucReadTimeout  = 10 * time.Second
+	fanucWriteTimeout = 30 * time.Second
+
+	// Повторы идемпотентных вызовов при сетевых ошибках: 300 мс, 600 мс (+ до 50% случайно)
+	fanucRetryAttempts = 3
+	fanucRetryBackoff  = 300 * time.Millisecond
+
+	// После breakerThreshold сетевых ошибок подряд сервис считается недоступным:
+	// вызовы сразу возвращают ошибку, раз в cooldown пропускается одна пробная попытка
+	breakerThreshold   = 3
+	breakerCooldown    = 30 * time.Second
+	breakerMaxCooldown = 5 * time.Minute
+)
+
+// unreachableError is returned without calling the service while its breaker is open
+type unreachableError struct {
+	retryIn time.Duration
+}
+
+func (e *unreachableError) Error() string {
+	return fmt.Sprintf("fanucService недоступен, следующая попытка через %d с", int(e.retryIn.Seconds())+1)
+}
+
+// circuitBreaker tracks consecutive network failures of one fanucService host
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	cooldown  time.Duration
+	probing   bool // пробная попытка после cooldown уже идет
+}
+
+// allow lets the call through unless the breaker is open; after the cooldown a single probe passes
+func (b *circuitBreaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < breakerThreshold {
+		return nil
+	}
+	if now.Before(b.openUntil) {
+		return &unreachableError{retryIn: b.openUntil.Sub(now)}
+	}
+	if b.probing {
+		return &unreachableError{retryIn: b.cooldown}
+	}
+	b.probing = true
+	return nil
+}
+
+// succeed closes the breaker: the service answered, even if with an API error
+func (b *circuitBreaker) succeed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.cooldown = 0
+	b.probing = false
+}
+
+// fail counts a network failure and opens the breaker once the threshold is reached.
+// A failed probe doubles the cooldown.
+func (b *circuitBreaker) fail(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.failures < breakerThreshold {
+		return
+	}
+	switch {
+	case b.cooldown == 0:
+		b.cooldown = breakerCooldown
+	case b.probing:
+		b.cooldown = min(2*b.cooldown, breakerMaxCooldown)
+	}
+	b.probing = false
+	b.openUntil = now.Add(b.cooldown)
+}
+
+// abort releases the probe of a call cancelled by the caller: it says nothing about the service
+func (b *circuitBreaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// unreachable reports an open breaker; after the cooldown the next call is let through as the probe
+func (b *circuitBreaker) unreachable(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= breakerThreshold && now.Before(b.openUntil)
+}
+
+// isNetworkError reports failures of the service itself, not API errors it answered with
+func isNetworkError(callCtx context.Context, err error) bool {
+	if errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// withJitter adds up to 50% to the backoff so retries of parallel calls do not align
+func withJitter(d time.Duration) time.Duration {
+	return d + rand.N(d/2+1)
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	type step struct {
+		op      string // allow, fail, succeed, abort
+		at      time.Duration
+		blocked bool // for allow
+	}
+	tests := []struct {
+		name            string
+		steps           []step
+		checkAt         time.Duration // момент вызова unreachable()
+		wantUnreachable bool
+	}{
+		{
+			name:  "closed below the threshold",
+			steps: []step{{op: "fail"}, {op: "fail"}, {op: "allow"}},
+		},
+		{
+			name:            "opens at the threshold",
+			steps:           []step{{op: "fail"}, {op: "fail"}, {op: "fail"}, {op: "allow", blocked: true}},
+			wantUnreachable: true,
+		},
+		{
+			name:  "success resets the failures",
+			steps: []step{{op: "fail"}, {op: "fail"}, {op: "succeed"}, {op: "fail"}, {op: "fail"}, {op: "allow"}},
+		},
+		{
+			name: "single probe after the cooldown",
+			steps: []step{
+				{op: "fail"}, {op: "fail"}, {op: "fail"},
+				{op: "allow", at: breakerCooldown - time.Second, blocked: true},
+				{op: "allow", at: breakerCooldown},
+				{op: "allow", at: breakerCooldown, blocked: true},
+			},
+			checkAt: breakerCooldown,
+		},
+		{
+			name: "successful probe closes the breaker",
+			steps: []step{
+				{op: "fail"}, {op: "fail"}, {op: "fail"},
+				{op: "allow", at: breakerCooldown},
+				{op: "succeed"},
+				{op: "allow", at: breakerCooldown},
+			},
+		},
+		{
+			name: "failed probe doubles the cooldown",
+			steps: []step{
+				{op: "fail"}, {op: "fail"}, {op: "fail"},
+				{op: "allow", at: breakerCooldown},
+				{op: "fail", at: breakerCooldown},
+				{op: "allow", at: 2*breakerCooldown + time.Second, blocked: true},
+				{op: "allow", at: 3 * breakerCooldown},
+			},
+			checkAt: 3 * breakerCooldown,
+		},
+		{
+			name: "failed probe reopens the breaker",
+			steps: []step{
+				{op: "fail"}, {op: "fail"}, {op: "fail"},
+				{op: "allow", at: breakerCooldown},
+				{op: "fail", at: breakerCooldown},
+			},
+			checkAt:         3*breakerCooldown - time.Second,
+			wantUnreachable: true,
+		},
+		{
+			name:    "open breaker is reachable again after the cooldown",
+			steps:   []step{{op: "fail"}, {op: "fail"}, {op: "fail"}},
+			checkAt: breakerCooldown,
+		},
+		{
+			name: "aborted probe lets the next call probe",
+			steps: []step{
+				{op: "fail"}, {op: "fail"}, {op: "fail"},
+				{op: "allow", at: breakerCooldown},
+				{op: "abort"},
+				{op: "allow", at: breakerCooldown},
+			},
+			checkAt: breakerCooldown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			b := &circuitBreaker{}
+			for i, s := range tt.steps {
+				now := start.Add(s.at)
+				switch s.op {
+				case "allow":
+					err := b.allow(now)
+					var unreachable *unreachableError
+					if s.blocked != errors.As(err, &unreachable) {
+						t.Fatalf("step %d: allow() = %v, blocked %v", i, err, s.blocked)
+					}
+				case "fail":
+					b.fail(now)
+				case "succeed":
+					b.succeed()
+				case "abort":
+					b.abort()
+				}
+			}
+			if got := b.unreachable(start.Add(tt.checkAt)); got != tt.wantUnreachable {
+				t.Errorf("unreachable() = %v, want %v", got, tt.wantUnreachable)
+			}
+		})
+	}
+}
+
+func TestBreakerMaxCooldown(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &circuitBreaker{}
+	for i := 0; i < breakerThreshold; i++ {
+		b.fail(now)
+	}
+	for i := 0; i < 10; i++ {
+		now = b.openUntil
+		if err := b.allow(now); err != nil {
+			t.Fatalf("probe %d: allow() = %v", i, err)
+		}
+		b.fail(now)
+	}
+	if b.cooldown != breakerMaxCooldown {
+		t.Errorf("cooldown = %v, want %v", b.cooldown, breakerMaxCooldown)
+	}
+}
+
+func TestIsNetworkError(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"api error", context.Background(), errors.New("machine not found"), false},
+		{"dial error", context.Background(), &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"attempt timeout", expired, errors.New("request failed"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNetworkError(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("isNetworkError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := withJitter(fanucRetryBackoff); d < fanucRetryBackoff || d > fanucRetryBackoff*3/2 {
+			t.Fatalf("withJitter() = %v, want within [%v, %v]", d, fanucRetryBackoff, fanucRetryBackoff*3/2)
+		}
+	}
+}
@@ -2,54 +2,144 @@ package services
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/iwtcode/fanucClient/internal/interfaces"
 	"github.com/iwtcode/fanucService"
 )
 
-type fanucApiService struct{}
+// fanucApiService создает клиента fanucService на каждый вызов: fanucService.Client
+// не принимает собственный http.Client или транспорт и ходит через http.DefaultTransport,
+// который уже переиспользует соединения. Кэш клиентов и отдельно настроенный транспорт
+// без поддержки со стороны библиотеки ничего не дают.
+type fanucApiService struct {
+	mu sync.Mutex
+	// Доступность хоста сервиса общая для всех ключей
+	breakers map[string]*circuitBreaker
+}
 
 func NewFanucApiService() interfaces.FanucApiService {
-	return &fanucApiService{}
+	return &fanucApiService{
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+// Availability
+
+func (s *fanucApiService) breaker(baseURL string) *circuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.breakers[baseURL]
+	if !ok {
+		b = &circuitBreaker{}
+		s.breakers[baseURL] = b
+	}
+	return b
+}
+
+func (s *fanucApiService) IsUnreachable(baseURL string) bool {
+	return s.breaker(baseURL).unreachable(time.Now())
+}
+
+// call runs fn with a per-attempt timeout through the breaker of the service host.
+// Idempotent calls are retried on network errors with exponential backoff.
+func (s *fanucApiService) call(ctx context.Context, baseURL string, idempotent bool, fn func(ctx context.Context) error) error {
+	b := s.breaker(baseURL)
+	attempts, timeout := 1, fanucWriteTimeout
+	if idempotent {
+		attempts, timeout = fanucRetryAttempts, fanucReadTimeout
+	}
+
+	backoff := fanucRetryBackoff
+	for attempt := 1; ; attempt++ {
+		if err := b.allow(time.Now()); err != nil {
+			return err
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := fn(callCtx)
+		network := err != nil && isNetworkError(callCtx, err)
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			b.abort()
+			return err
+		case !network:
+			b.succeed()
+			return err
+		}
+		b.fail(time.Now())
+		if attempt >= attempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(withJitter(backoff)):
+		}
+		backoff *= 2
+	}
 }
 
 // Connection
 
 func (s *fanucApiService) CreateConnection(ctx context.Context, baseURL, apiKey string, req fanucService.ConnectionRequest) (*fanucService.MachineDTO, error) {
-	client := fanucService.NewClient(baseURL, apiKey)
-	return client.CreateConnection(ctx, req)
+	var machine *fanucService.MachineDTO
+	err := s.call(ctx, baseURL, false, func(ctx context.Context) (err error) {
+		machine, err = fanucService.NewClient(baseURL, apiKey).CreateConnection(ctx, req)
+		return err
+	})
+	return machine, err
 }
 
 func (s *fanucApiService) GetConnections(ctx context.Context, baseURL, apiKey string) ([]fanucService.MachineDTO, error) {
-	client := fanucService.NewClient(baseURL, apiKey)
-	return client.GetConnections(ctx)
+	var machines []fanucService.MachineDTO
+	err := s.call(ctx, baseURL, true, func(ctx context.Context) (err error) {
+		machines, err = fanucService.NewClient(baseURL, apiKey).GetConnections(ctx)
+		return err
+	})
+	return machines, err
 }
 
 func (s *fanucApiService) CheckConnection(ctx context.Context, baseURL, apiKey, machineID string) (*fanucService.MachineDTO, error) {
-	client := fanucService.NewClient(baseURL, apiKey)
-	return client.CheckConnection(ctx, machineID)
+	var machine *fanucService.MachineDTO
+	err := s.call(ctx, baseURL, true, func(ctx context.Context) (err error) {
+		machine, err = fanucService.NewClient(baseURL, apiKey).CheckConnection(ctx, machineID)
+		return err
+	})
+	return machine, err
 }
 
 func (s *fanucApiService) DeleteConnection(ctx context.Context, baseURL, apiKey, machineID string) error {
-	client := fanucService.NewClient(baseURL, apiKey)
-	return client.DeleteConnection(ctx, machineID)
+	return s.call(ctx, baseURL, false, func(ctx context.Context) error {
+		return fanucService.NewClient(baseURL, apiKey).DeleteConnection(ctx, machineID)
+	})
 }
 
 // Polling
 
 func (s *fanucApiService) StartPolling(ctx context.Context, baseURL, apiKey, machineID string, intervalMs int) error {
-	client := fanucService.NewClient(baseURL, apiKey)
-	return client.StartPolling(ctx, machineID, intervalMs)
+	return s.call(ctx, baseURL, false, func(ctx context.Context) error {
+		return fanucService.NewClient(baseURL, apiKey).StartPolling(ctx, machineID, intervalMs)
+	})
 }
 
 func (s *fanucApiService) StopPolling(ctx context.Context, baseURL, apiKey, machineID string) error {
-	client := fanucService.NewClient(baseURL, apiKey)
-	return client.StopPolling(ctx, machineID)
+	return s.call(ctx, baseURL, false, func(ctx context.Context) error {
+		return fanucService.NewClient(baseURL, apiKey).StopPolling(ctx, machineID)
+	})
 }
 
 // Program
 
 func (s *fanucApiService) GetControlProgram(ctx context.Context, baseURL, apiKey, machineID string) (string, error) {
-	client := fanucService.NewClient(baseURL, apiKey)
-	return client.GetControlProgram(ctx, machineID)
+	var program string
+	err := s.call(ctx, baseURL, true, func(ctx context.Context) (err error) {
+		program, err = fanucService.NewClient(baseURL, apiKey).GetControlProgram(ctx, machineID)
+		return err
+	})
+	return program, err
 }
@@ -2,100 +2,760 @@ package services
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/iwtcode/fanucClient/internal/domain/models"
 	"github.com/iwtcode/fanucClient/internal/interfaces"
 	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+const (
+	kafkaDialTimeout = 10 * time.Second
+	kafkaReadTimeout = 5 * time.Second
+	// Попытка подключения к bootstrap брокеру, после которого в списке есть другие:
+	// недоступный (не отвечающий) хост не должен съедать время остальных
+	kafkaBootstrapAttemptTimeout = 3 * time.Second
+
+	// Сколько последних сообщений партиции просматриваем при поиске по ключу
+	kafkaKeyScanDepth = 1000
+	// Максимальный размер диапазона для ReadRange
+	kafkaMaxRange = 5000
 )
 
 type kafkaService struct{}
 
+// newDialer builds a dialer with SASL and TLS settings of the target
+func newDialer(auth models.KafkaAuth) (*kafka.Dialer, error) {
+	dialer := &kafka.Dialer{
+		Timeout:   kafkaDialTimeout,
+		DualStack: true,
+	}
+
+	mechanism, err := saslMechanism(auth)
+	if err != nil {
+		return nil, err
+	}
+	dialer.SASLMechanism = mechanism
+
+	if auth.TLS {
+		tlsCfg := &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: auth.InsecureSkipVerify,
+		}
+		if auth.CACert != "" {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(auth.CACert)) {
+				return nil, fmt.Errorf("некорректный CA сертификат")
+			}
+			tlsCfg.RootCAs = pool
+		}
+		dialer.TLS = tlsCfg
+	}
+
+	return dialer, nil
+}
+
+func saslMechanism(auth models.KafkaAuth) (sasl.Mechanism, error) {
+	switch auth.Mechanism {
+	case models.SASLNone:
+		return nil, nil
+	case models.SASLPlain:
+		return plain.Mechanism{Username: auth.Username, Password: auth.Password}, nil
+	case models.SASLScramSHA256:
+		return scram.Mechanism(scram.SHA256, auth.Username, auth.Password)
+	case models.SASLScramSHA512:
+		return scram.Mechanism(scram.SHA512, auth.Username, auth.Password)
+	default:
+		return nil, fmt.Errorf("неподдерживаемый SASL механизм: %s", auth.Mechanism)
+	}
+}
+
 func NewKafkaService() interfaces.KafkaReader {
 	return &kafkaService{}
 }
 
-func (s *kafkaService) GetLastMessage(ctx context.Context, broker, topic, keyFilter string) (string, string, error) {
-	if broker == "" || topic == "" {
-		return "", "", fmt.Errorf("broker или topic пусты")
+// partitionTail - результат чтения хвоста одной партиции
+type partitionTail struct {
+	msg   *models.KafkaMessage
+	empty bool
+	err   error
+}
+
+func (s *kafkaService) GetLastMessage(ctx context.Context, src models.KafkaSource, filter models.KeyFilter) (models.KafkaMessage, error) {
+	if len(src.Brokers) == 0 || src.Topic == "" {
+		return models.KafkaMessage{}, fmt.Errorf("broker или topic пусты")
+	}
+
+	match, err := filter.Compile()
+	if err != nil {
+		return models.KafkaMessage{}, err
+	}
+	keyed := filter.Pattern != ""
+
+	dialer, err := newDialer(src.Auth)
+	if err != nil {
+		return models.KafkaMessage{}, err
 	}
 
-	dialCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	dialCtx, cancel := context.WithTimeout(ctx, kafkaDialTimeout)
 	defer cancel()
 
-	// 1. Connect to partition 0 leader (Assuming single partition for simplicity or 0)
-	conn, err := kafka.DialLeader(dialCtx, "tcp", broker, topic, 0)
+	// 1. Read topic metadata
+	partitions, err := s.lookupPartitions(dialCtx, dialer, src)
+	if err != nil {
+		return models.KafkaMessage{}, err
+	}
+
+	// 2. Exact record key is set: the producer routes it to a single partition, check it first
+	scanned := make(map[int]bool)
+	allEmpty := true
+	if keyed && filter.IsExact() && filter.ByRecordKey() {
+		p := keyPartition(filter.Pattern, partitions)
+		scanned[p.ID] = true
+
+		tail := s.readPartitionTail(dialCtx, dialer, p, match, keyed)
+		if tail.err != nil {
+			return models.KafkaMessage{}, tail.err
+		}
+		if tail.msg != nil {
+			return *tail.msg, nil
+		}
+		allEmpty = tail.empty
+	}
+
+	// 3. Scan remaining partitions concurrently and take the newest message.
+	// Without a key or with a pattern this is the normal path, with an exact key
+	// it is a fallback for producers that use a different partitioner.
+	var rest []kafka.Partition
+	for _, p := range partitions {
+		if !scanned[p.ID] {
+			rest = append(rest, p)
+		}
+	}
+
+	tails := make([]partitionTail, len(rest))
+	var wg sync.WaitGroup
+	for i, p := range rest {
+		wg.Add(1)
+		go func(i int, p kafka.Partition) {
+			defer wg.Done()
+			tails[i] = s.readPartitionTail(dialCtx, dialer, p, match, keyed)
+		}(i, p)
+	}
+	wg.Wait()
+
+	var foundMsg *models.KafkaMessage
+	var errs []error
+	for _, t := range tails {
+		if t.err != nil {
+			errs = append(errs, t.err)
+			continue
+		}
+		if !t.empty {
+			allEmpty = false
+		}
+		if t.msg != nil && (foundMsg == nil || t.msg.Time.After(foundMsg.Time)) {
+			foundMsg = t.msg
+		}
+	}
+
+	if foundMsg == nil {
+		// All partitions failed - report the first error
+		if len(errs) > 0 && len(errs) == len(tails) {
+			return models.KafkaMessage{}, errs[0]
+		}
+		if allEmpty && len(errs) == 0 {
+			return models.KafkaMessage{Value: "⚠️ Топик пуст"}, nil
+		}
+		if keyed {
+			return models.KafkaMessage{Value: fmt.Sprintf("⚠️ Сообщение с ключом '%s' не найдено в последних %d записях партиций", filter, kafkaKeyScanDepth)}, nil
+		}
+		return models.KafkaMessage{Value: "⚠️ Не удалось прочитать сообщение"}, nil
+	}
+
+	return *foundMsg, nil
+}
+
+// dialBootstrap connects to the first reachable broker of the bootstrap list
+func (s *kafkaService) dialBootstrap(ctx context.Context, dialer *kafka.Dialer, src models.KafkaSource) (*kafka.Conn, error) {
+	var errs []error
+	for i, broker := range src.Brokers {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if i < len(src.Brokers)-1 {
+			attemptCtx, cancel = context.WithTimeout(ctx, kafkaBootstrapAttemptTimeout)
+		}
+		conn, err := dialer.DialContext(attemptCtx, "tcp", broker)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", broker, err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		return conn, nil
+	}
+	return nil, fmt.Errorf("нет доступных брокеров: %w", errors.Join(errs...))
+}
+
+// lookupPartitions returns partition metadata of the topic sorted by ID.
+// Leaders may live on any broker of the cluster, not only on bootstrap ones.
+func (s *kafkaService) lookupPartitions(ctx context.Context, dialer *kafka.Dialer, src models.KafkaSource) ([]kafka.Partition, error) {
+	conn, err := s.dialBootstrap(ctx, dialer, src)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions(src.Topic)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to dial leader: %w", err)
+		return nil, fmt.Errorf("failed to read partitions: %w", err)
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("топик '%s' не найден", src.Topic)
+	}
+
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].ID < partitions[j].ID })
+	return partitions, nil
+}
+
+// keyPartition повторяет выбор партиции хеш-балансировщиком продюсера (FNV-1a, как kafka-go и Sarama)
+func keyPartition(key string, partitions []kafka.Partition) kafka.Partition {
+	ids := make([]int, len(partitions))
+	for i, p := range partitions {
+		ids[i] = p.ID
+	}
+
+	balancer := &kafka.Hash{}
+	id := balancer.Balance(kafka.Message{Key: []byte(key)}, ids...)
+	for _, p := range partitions {
+		if p.ID == id {
+			return p
+		}
+	}
+	return partitions[0]
+}
+
+// dialPartition connects to the leader of the partition, wherever it lives in the cluster
+func (s *kafkaService) dialPartition(ctx context.Context, dialer *kafka.Dialer, p kafka.Partition) (*kafka.Conn, error) {
+	if p.Leader.Host == "" {
+		return nil, fmt.Errorf("у партиции %d нет доступного лидера", p.ID)
+	}
+
+	conn, err := dialer.DialPartition(ctx, "tcp", "", p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial leader of partition %d: %w", p.ID, err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+// readPartitionTail reads the newest message of the partition.
+// If keyed is set, the last kafkaKeyScanDepth records are scanned for the newest one accepted by match.
+func (s *kafkaService) readPartitionTail(ctx context.Context, dialer *kafka.Dialer, p kafka.Partition, match func(models.KafkaMessage) bool, keyed bool) partitionTail {
+	partition := p.ID
+	conn, err := s.dialPartition(ctx, dialer, p)
+	if err != nil {
+		return partitionTail{err: err}
 	}
 	defer conn.Close()
 
-	// 2. Get last offset
-	lastOffset, err := conn.ReadLastOffset()
+	firstOffset, lastOffset, err := conn.ReadOffsets()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to read last offset: %w", err)
+		return partitionTail{err: fmt.Errorf("failed to read offsets of partition %d: %w", partition, err)}
 	}
 
-	if lastOffset == 0 {
-		return "", "⚠️ Топик пуст", nil
+	if lastOffset <= firstOffset {
+		return partitionTail{empty: true}
 	}
 
-	// 3. Determine scan range
-	// If key is present, we scan last 1000 messages to find it.
+	// If key is present, we scan last messages to find it.
 	// If key is empty, we just take the last message.
 	scanDepth := int64(1)
-	if keyFilter != "" {
-		scanDepth = 1000
+	if keyed {
+		scanDepth = kafkaKeyScanDepth
 	}
 
 	startOffset := lastOffset - scanDepth
-	if startOffset < 0 {
-		startOffset = 0
+	if startOffset < firstOffset {
+		startOffset = firstOffset
 	}
 
-	if _, err := conn.Seek(startOffset, kafka.SeekAbsolute); err != nil {
-		return "", "", fmt.Errorf("failed to seek: %w", err)
+	var foundMsg *models.KafkaMessage
+	err = scanRange(conn, partition, startOffset, lastOffset, func(m kafka.Message) {
+		if msg := toKafkaMessage(m); match(msg) {
+			foundMsg = &msg
+		}
+	})
+	if err != nil && foundMsg == nil {
+		return partitionTail{err: err}
 	}
 
-	// 4. Read batch
-	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
-	batch := conn.ReadBatch(10e3, 1e6) // min 10KB, max 1MB
-	defer batch.Close()
+	return partitionTail{msg: foundMsg}
+}
 
-	var foundMsg *kafka.Message
+// scanRange reads records [start, end) of the partition from an open leader connection
+func scanRange(conn *kafka.Conn, partition int, start, end int64, visit func(kafka.Message)) error {
+	if _, err := conn.Seek(start, kafka.SeekAbsolute); err != nil {
+		return fmt.Errorf("failed to seek partition %d: %w", partition, err)
+	}
 
-	// Scan through messages from startOffset to lastOffset
-	for {
-		m, err := batch.ReadMessage()
+	offset := start
+
+	// Read batches until we reach the end of the range
+	for offset < end {
+		conn.SetReadDeadline(time.Now().Add(kafkaReadTimeout))
+		batch := conn.ReadBatch(1, 1e6) // max 1MB per batch
+
+		read := 0
+		for {
+			m, err := batch.ReadMessage()
+			if err != nil {
+				break // Batch finished or error
+			}
+			read++
+			offset = m.Offset + 1
+
+			visit(m)
+
+			if offset >= end {
+				break
+			}
+		}
+
+		closeErr := batch.Close()
+		if read == 0 {
+			if closeErr != nil {
+				return fmt.Errorf("failed to read partition %d: %w", partition, closeErr)
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *kafkaService) GetPartitionOffsets(ctx context.Context, src models.KafkaSource) ([]models.PartitionOffsets, error) {
+	var mu sync.Mutex
+	var result []models.PartitionOffsets
+
+	err := s.forEachPartition(ctx, src, func(conn *kafka.Conn, p kafka.Partition) error {
+		first, last, err := conn.ReadOffsets()
+		if err != nil {
+			return fmt.Errorf("failed to read offsets of partition %d: %w", p.ID, err)
+		}
+
+		mu.Lock()
+		result = append(result, models.PartitionOffsets{Partition: p.ID, First: first, Last: last})
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Partition < result[j].Partition })
+	return result, nil
+}
+
+func (s *kafkaService) GetOffsetsForTime(ctx context.Context, src models.KafkaSource, t time.Time) (map[int]int64, error) {
+	var mu sync.Mutex
+	result := make(map[int]int64)
+
+	err := s.forEachPartition(ctx, src, func(conn *kafka.Conn, p kafka.Partition) error {
+		offset, err := conn.ReadOffset(t)
+		if err != nil {
+			return fmt.Errorf("failed to read offset for time of partition %d: %w", p.ID, err)
+		}
+		// -1: no record at or after t, the position is the end of the partition
+		if offset < 0 {
+			if offset, err = conn.ReadLastOffset(); err != nil {
+				return fmt.Errorf("failed to read last offset of partition %d: %w", p.ID, err)
+			}
+		}
+
+		mu.Lock()
+		result[p.ID] = offset
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *kafkaService) GetPartitionStats(ctx context.Context, src models.KafkaSource, since time.Time) ([]models.PartitionStats, error) {
+	var mu sync.Mutex
+	var result []models.PartitionStats
+
+	err := s.forEachPartition(ctx, src, func(conn *kafka.Conn, p kafka.Partition) error {
+		first, last, err := conn.ReadOffsets()
 		if err != nil {
-			break // Batch finished or error
+			return fmt.Errorf("failed to read offsets of partition %d: %w", p.ID, err)
 		}
+		stats := models.PartitionStats{Partition: p.ID, First: first, Last: last}
+
+		if last > first {
+			// -1: nothing was written after since
+			sinceOffset, err := conn.ReadOffset(since)
+			if err != nil {
+				return fmt.Errorf("failed to read offset for time of partition %d: %w", p.ID, err)
+			}
+			if sinceOffset >= 0 {
+				stats.RecentCount = last - sinceOffset
+			}
 
-		if keyFilter != "" {
-			// If looking for a key, update foundMsg only if key matches
-			if string(m.Key) == keyFilter {
-				// We create a copy because m is reused in loop
-				msgCopy := m
-				foundMsg = &msgCopy
+			err = scanRange(conn, p.ID, last-1, last, func(m kafka.Message) {
+				stats.NewestTime = m.Time
+			})
+			if err != nil {
+				return err
 			}
-		} else {
-			// If no key, just take the last one seen
-			msgCopy := m
-			foundMsg = &msgCopy
 		}
 
-		// If we reached the end
-		if m.Offset >= lastOffset-1 {
+		mu.Lock()
+		result = append(result, stats)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Partition < result[j].Partition })
+	return result, nil
+}
+
+func (s *kafkaService) GetCommittedOffsets(ctx context.Context, src models.KafkaSource, groupID string) (map[int]int64, error) {
+	dialer, err := newDialer(src.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, kafkaDialTimeout)
+	defer cancel()
+
+	partitions, err := s.lookupPartitions(dialCtx, dialer, src)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(partitions))
+	for i, p := range partitions {
+		ids[i] = p.ID
+	}
+
+	// Client finds the group coordinator itself.
+	// The transport keeps a connection pool, it is closed once the offsets are read.
+	transport := &kafka.Transport{
+		DialTimeout: kafkaDialTimeout,
+		SASL:        dialer.SASLMechanism,
+		TLS:         dialer.TLS,
+	}
+	defer transport.CloseIdleConnections()
+
+	client := &kafka.Client{
+		Addr:      kafka.TCP(src.Brokers...),
+		Timeout:   kafkaDialTimeout,
+		Transport: transport,
+	}
+	resp, err := client.OffsetFetch(dialCtx, &kafka.OffsetFetchRequest{
+		GroupID: groupID,
+		Topics:  map[string][]int{src.Topic: ids},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch group offsets: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("failed to fetch group offsets: %w", resp.Error)
+	}
+
+	result := make(map[int]int64)
+	for _, p := range resp.Topics[src.Topic] {
+		// -1: the group has not committed this partition
+		if p.Error == nil && p.CommittedOffset >= 0 {
+			result[p.Partition] = p.CommittedOffset
+		}
+	}
+	return result, nil
+}
+
+// forEachPartition concurrently connects to the leader of every partition and calls fn
+func (s *kafkaService) forEachPartition(ctx context.Context, src models.KafkaSource, fn func(conn *kafka.Conn, p kafka.Partition) error) error {
+	if len(src.Brokers) == 0 || src.Topic == "" {
+		return fmt.Errorf("broker или topic пусты")
+	}
+
+	dialer, err := newDialer(src.Auth)
+	if err != nil {
+		return err
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, kafkaDialTimeout)
+	defer cancel()
+
+	partitions, err := s.lookupPartitions(dialCtx, dialer, src)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(partitions))
+	var wg sync.WaitGroup
+	for i, p := range partitions {
+		wg.Add(1)
+		go func(i int, p kafka.Partition) {
+			defer wg.Done()
+
+			conn, err := s.dialPartition(dialCtx, dialer, p)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer conn.Close()
+
+			errs[i] = fn(conn, p)
+		}(i, p)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (s *kafkaService) ReadRange(ctx context.Context, src models.KafkaSource, partition int, startOffset, endOffset int64) ([]models.KafkaMessage, error) {
+	if len(src.Brokers) == 0 || src.Topic == "" {
+		return nil, fmt.Errorf("broker или topic пусты")
+	}
+	if endOffset <= startOffset {
+		return nil, nil
+	}
+	if endOffset-startOffset > kafkaMaxRange {
+		return nil, fmt.Errorf("диапазон слишком большой: максимум %d записей", kafkaMaxRange)
+	}
+
+	dialer, err := newDialer(src.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, kafkaDialTimeout)
+	defer cancel()
+
+	partitions, err := s.lookupPartitions(dialCtx, dialer, src)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta *kafka.Partition
+	for i := range partitions {
+		if partitions[i].ID == partition {
+			meta = &partitions[i]
 			break
 		}
 	}
+	if meta == nil {
+		return nil, fmt.Errorf("партиция %d не найдена", partition)
+	}
 
-	if foundMsg == nil {
-		if keyFilter != "" {
-			return "", fmt.Sprintf("⚠️ Сообщение с ключом '%s' не найдено в последних %d записях", keyFilter, scanDepth), nil
+	conn, err := s.dialPartition(dialCtx, dialer, *meta)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Clamp the range to what the partition still retains
+	first, last, err := conn.ReadOffsets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offsets of partition %d: %w", partition, err)
+	}
+	if startOffset < first {
+		startOffset = first
+	}
+	if endOffset > last {
+		endOffset = last
+	}
+	if endOffset <= startOffset {
+		return nil, nil
+	}
+
+	var messages []models.KafkaMessage
+	err = scanRange(conn, partition, startOffset, endOffset, func(m kafka.Message) {
+		messages = append(messages, toKafkaMessage(m))
+	})
+	if err != nil && len(messages) == 0 {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *kafkaService) Consume(ctx context.Context, src models.KafkaSource, groupID string, handle func(models.KafkaMessage)) error {
+	if len(src.Brokers) == 0 || src.Topic == "" {
+		return fmt.Errorf("broker или topic пусты")
+	}
+
+	dialer, err := newDialer(src.Auth)
+	if err != nil {
+		return err
+	}
+
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: src.Brokers,
+		Topic:   src.Topic,
+		GroupID: groupID,
+		Dialer:  dialer,
+		// New group starts from the end: we only need fresh data
+		StartOffset:    kafka.LastOffset,
+		MinBytes:       1,
+		MaxBytes:       10e6,
+		MaxWait:        time.Second,
+		CommitInterval: time.Second,
+	})
+	defer r.Close()
+
+	for {
+		m, err := r.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+		handle(toKafkaMessage(m))
+	}
+}
+
+func (s *kafkaService) ListTopics(ctx context.Context, src models.KafkaSource) ([]models.TopicInfo, error) {
+	if len(src.Brokers) == 0 {
+		return nil, fmt.Errorf("broker пуст")
+	}
+
+	dialer, err := newDialer(src.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, kafkaDialTimeout)
+	defer cancel()
+
+	conn, err := s.dialBootstrap(dialCtx, dialer, src)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Without arguments metadata of every topic is returned
+	partitions, err := conn.ReadPartitions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topics: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, p := range partitions {
+		// Internal topics (__consumer_offsets, __transaction_state) are not interesting
+		if strings.HasPrefix(p.Topic, "__") {
+			continue
 		}
-		return "", "⚠️ Не удалось прочитать сообщение", nil
+		counts[p.Topic]++
 	}
 
-	return string(foundMsg.Key), string(foundMsg.Value), nil
+	topics := make([]models.TopicInfo, 0, len(counts))
+	for name, n := range counts {
+		topics = append(topics, models.TopicInfo{Name: name, Partitions: n})
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+	return topics, nil
+}
+
+func (s *kafkaService) GetClusterInfo(ctx context.Context, src models.KafkaSource) (*models.ClusterInfo, error) {
+	if len(src.Brokers) == 0 || src.Topic == "" {
+		return nil, fmt.Errorf("broker или topic пусты")
+	}
+
+	dialer, err := newDialer(src.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, kafkaDialTimeout)
+	defer cancel()
+
+	conn, err := s.dialBootstrap(dialCtx, dialer, src)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	brokers, err := conn.Brokers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read brokers: %w", err)
+	}
+	controller, err := conn.Controller()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read controller: %w", err)
+	}
+	partitions, err := conn.ReadPartitions(src.Topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partitions: %w", err)
+	}
+
+	info := &models.ClusterInfo{Controller: toBrokerInfo(controller)}
+	for _, b := range brokers {
+		info.Brokers = append(info.Brokers, toBrokerInfo(b))
+	}
+	sort.Slice(info.Brokers, func(i, j int) bool { return info.Brokers[i].ID < info.Brokers[j].ID })
+
+	for _, p := range partitions {
+		leader := toBrokerInfo(p.Leader)
+		if p.Leader.Host == "" {
+			leader = models.BrokerInfo{ID: -1}
+		}
+		info.Partitions = append(info.Partitions, models.PartitionInfo{
+			ID:       p.ID,
+			Leader:   leader,
+			Replicas: len(p.Replicas),
+			ISR:      len(p.Isr),
+		})
+	}
+	sort.Slice(info.Partitions, func(i, j int) bool { return info.Partitions[i].ID < info.Partitions[j].ID })
+
+	return info, nil
+}
+
+func toBrokerInfo(b kafka.Broker) models.BrokerInfo {
+	return models.BrokerInfo{
+		ID:   b.ID,
+		Addr: net.JoinHostPort(b.Host, strconv.Itoa(b.Port)),
+		Rack: b.Rack,
+	}
+}
+
+func toKafkaMessage(m kafka.Message) models.KafkaMessage {
+	msg := models.KafkaMessage{
+		Key:       string(m.Key),
+		Value:     string(m.Value),
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Time:      m.Time,
+	}
+	for _, h := range m.Headers {
+		msg.Headers = append(msg.Headers, models.Header{Key: h.Key, Value: string(h.Value)})
+	}
+	return msg
 }
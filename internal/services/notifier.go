@@ -1 +1,292 @@
 package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/fanucClient"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+	"go.uber.org/fx"
+	tele "gopkg.in/telebot.v3"
+)
+
+const (
+	// Лимиты Telegram Bot API: 30 сообщений в секунду всего и 1 сообщение в секунду в один чат
+	notifyGlobalInterval = time.Second / 30
+	notifyChatInterval   = time.Second
+
+	// Очередь неотправленных уведомлений
+	notifyQueueSize = 1000
+	// Попыток отправки до отказа (ошибки сети и 5xx; 429 не расходует попытки)
+	notifyMaxAttempts = 5
+	notifyRetryDelay  = 2 * time.Second
+
+	// Одинаковые уведомления (ChatID + DedupKey) в пределах окна отправляются один раз
+	notifyDedupWindow = 10 * time.Minute
+)
+
+// outgoing - уведомление в очереди отправки
+type outgoing struct {
+	n         models.Notification
+	attempts  int
+	notBefore time.Time // retry_after или пауза перед повтором
+}
+
+type telegramNotifier struct {
+	bot *tele.Bot
+
+	incoming chan *outgoing
+
+	mu   sync.Mutex
+	sent map[string]time.Time // dedup key -> время постановки в очередь
+
+	// Состояние dispatcher-а (только его горутина)
+	pending    []*outgoing
+	chatNext   map[int64]time.Time
+	globalNext time.Time
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewTelegramNotifier creates a send-only bot client: updates are handled by handlers/telegram.
+// Notifications are queued and delivered by a background dispatcher within Telegram rate limits.
+func NewTelegramNotifier(lifecycle fx.Lifecycle, cfg *fanucClient.Config) (interfaces.NotificationSender, error) {
+	bot, err := tele.NewBot(tele.Settings{
+		Token:     cfg.TgToken,
+		ParseMode: tele.ModeHTML,
+		Offline:   true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("notifier: %w", err)
+	}
+
+	n := &telegramNotifier{
+		bot:      bot,
+		incoming: make(chan *outgoing, notifyQueueSize),
+		sent:     make(map[string]time.Time),
+		chatNext: make(map[int64]time.Time),
+	}
+
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			n.start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			n.stop()
+			return nil
+		},
+	})
+	return n, nil
+}
+
+// Send queues the notification. Duplicates within notifyDedupWindow are dropped silently.
+func (n *telegramNotifier) Send(ctx context.Context, msg models.Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var key string
+	var now time.Time
+	if msg.DedupKey != "" {
+		key = fmt.Sprintf("%d:%s", msg.ChatID, msg.DedupKey)
+		now = time.Now()
+
+		n.mu.Lock()
+		if at, ok := n.sent[key]; ok && now.Sub(at) < notifyDedupWindow {
+			n.mu.Unlock()
+			return nil
+		}
+		n.sent[key] = now
+		n.mu.Unlock()
+	}
+
+	select {
+	case n.incoming <- &outgoing{n: msg}:
+		return nil
+	default:
+		// Not queued: a retry of the same notification must not be taken for a duplicate
+		if key != "" {
+			n.mu.Lock()
+			if n.sent[key] == now {
+				delete(n.sent, key)
+			}
+			n.mu.Unlock()
+		}
+		return fmt.Errorf("очередь уведомлений переполнена")
+	}
+}
+
+func (n *telegramNotifier) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		n.dispatch(ctx)
+	}()
+}
+
+func (n *telegramNotifier) stop() {
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.wg.Wait()
+	if len(n.pending) > 0 {
+		log.Printf("⚠️ Notifier: %d уведомлений не отправлено при остановке", len(n.pending))
+	}
+}
+
+// dispatch sends queued notifications one by one respecting global and per-chat limits
+func (n *telegramNotifier) dispatch(ctx context.Context) {
+	cleanup := time.NewTicker(notifyDedupWindow)
+	defer cleanup.Stop()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		next, wait := n.nextReady(time.Now())
+		if next != nil {
+			n.deliver(ctx, next)
+			continue
+		}
+
+		if wait > 0 {
+			timer.Reset(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-n.incoming:
+			n.pending = append(n.pending, item)
+		case <-timer.C:
+		case <-cleanup.C:
+			n.forgetSent(time.Now())
+		}
+	}
+}
+
+// nextReady removes and returns the oldest notification that may be sent now;
+// otherwise returns how long to wait (0 = until a new notification arrives)
+func (n *telegramNotifier) nextReady(now time.Time) (*outgoing, time.Duration) {
+	// Move everything that was queued meanwhile
+	for drained := false; !drained; {
+		select {
+		case item := <-n.incoming:
+			n.pending = append(n.pending, item)
+		default:
+			drained = true
+		}
+	}
+	if len(n.pending) == 0 {
+		return nil, 0
+	}
+
+	if now.Before(n.globalNext) {
+		return nil, n.globalNext.Sub(now)
+	}
+
+	var wait time.Duration
+	for i, item := range n.pending {
+		ready := item.notBefore
+		if chatReady := n.chatNext[item.n.ChatID]; chatReady.After(ready) {
+			ready = chatReady
+		}
+		if !now.Before(ready) {
+			n.pending = append(n.pending[:i], n.pending[i+1:]...)
+			return item, 0
+		}
+		if d := ready.Sub(now); wait == 0 || d < wait {
+			wait = d
+		}
+	}
+	return nil, wait
+}
+
+func (n *telegramNotifier) deliver(ctx context.Context, item *outgoing) {
+	now := time.Now()
+	n.globalNext = now.Add(notifyGlobalInterval)
+	n.chatNext[item.n.ChatID] = now.Add(notifyChatInterval)
+
+	_, err := n.bot.Send(tele.ChatID(item.n.ChatID), notificationContent(item.n), notificationMarkup(item.n.Buttons))
+	if err == nil {
+		return
+	}
+
+	// 429: wait as long as Telegram asks, the chat is blocked for the same time
+	var flood tele.FloodError
+	if errors.As(err, &flood) {
+		retryAt := time.Now().Add(time.Duration(flood.RetryAfter) * time.Second)
+		item.notBefore = retryAt
+		n.chatNext[item.n.ChatID] = retryAt
+		n.pending = append(n.pending, item)
+		return
+	}
+
+	// Other 4xx (chat not found, bot blocked, bad markup) will not succeed on retry
+	var apiErr *tele.Error
+	if errors.As(err, &apiErr) && apiErr.Code >= 400 && apiErr.Code < 500 && apiErr.Code != http.StatusTooManyRequests {
+		log.Printf("⚠️ Notifier: уведомление в чат %d отклонено: %v", item.n.ChatID, err)
+		return
+	}
+
+	item.attempts++
+	if item.attempts >= notifyMaxAttempts || ctx.Err() != nil {
+		log.Printf("⚠️ Notifier: уведомление в чат %d не отправлено после %d попыток: %v", item.n.ChatID, item.attempts, err)
+		return
+	}
+	item.notBefore = time.Now().Add(notifyRetryDelay << (item.attempts - 1))
+	n.pending = append(n.pending, item)
+}
+
+// notificationContent returns the text or, for notifications with a file, a document captioned with the text.
+// The document is rebuilt on every attempt: a reader cannot be sent twice.
+func notificationContent(msg models.Notification) interface{} {
+	if msg.Document == nil {
+		return msg.Text
+	}
+	return &tele.Document{
+		File:     tele.FromReader(bytes.NewReader(msg.Document.Data)),
+		FileName: msg.Document.Name,
+		MIME:     msg.Document.MIME,
+		Caption:  msg.Text,
+	}
+}
+
+// notificationMarkup puts consecutive buttons of the same Row into one inline row (nil markup if there are none)
+func notificationMarkup(buttons []models.NotificationButton) *tele.ReplyMarkup {
+	if len(buttons) == 0 {
+		return nil
+	}
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for i, b := range buttons {
+		if i == 0 || b.Row != buttons[i-1].Row {
+			rows = append(rows, tele.Row{})
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], markup.Data(b.Text, b.Data))
+	}
+	markup.Inline(rows...)
+	return markup
+}
+
+// forgetSent drops dedup entries older than the window
+func (n *telegramNotifier) forgetSent(now time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for key, at := range n.sent {
+		if now.Sub(at) >= notifyDedupWindow {
+			delete(n.sent, key)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+)
+
+func newTestNotifier() *telegramNotifier {
+	return &telegramNotifier{
+		incoming: make(chan *outgoing, notifyQueueSize),
+		sent:     make(map[string]time.Time),
+		chatNext: make(map[int64]time.Time),
+	}
+}
+
+func TestNextReady(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		pending    []*outgoing
+		globalNext time.Time
+		chatNext   map[int64]time.Time
+		wantChat   int64 // 0 = nothing ready
+		wantWait   time.Duration
+	}{
+		{
+			name: "empty queue waits for new notifications",
+		},
+		{
+			name:     "oldest ready notification first",
+			pending:  []*outgoing{{n: models.Notification{ChatID: 1}}, {n: models.Notification{ChatID: 2}}},
+			wantChat: 1,
+		},
+		{
+			name:       "global limit",
+			pending:    []*outgoing{{n: models.Notification{ChatID: 1}}},
+			globalNext: now.Add(20 * time.Millisecond),
+			wantWait:   20 * time.Millisecond,
+		},
+		{
+			name:     "busy chat is skipped",
+			pending:  []*outgoing{{n: models.Notification{ChatID: 1}}, {n: models.Notification{ChatID: 2}}},
+			chatNext: map[int64]time.Time{1: now.Add(time.Second)},
+			wantChat: 2,
+		},
+		{
+			name:     "shortest wait of chat limit and retry delay",
+			pending:  []*outgoing{{n: models.Notification{ChatID: 1}}, {n: models.Notification{ChatID: 2}, notBefore: now.Add(300 * time.Millisecond)}},
+			chatNext: map[int64]time.Time{1: now.Add(time.Second)},
+			wantWait: 300 * time.Millisecond,
+		},
+		{
+			name:     "retry_after outweighs a free chat",
+			pending:  []*outgoing{{n: models.Notification{ChatID: 1}, notBefore: now.Add(2 * time.Second)}},
+			chatNext: map[int64]time.Time{1: now.Add(-time.Second)},
+			wantWait: 2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNotifier()
+			n.pending = tt.pending
+			n.globalNext = tt.globalNext
+			for chat, at := range tt.chatNext {
+				n.chatNext[chat] = at
+			}
+
+			item, wait := n.nextReady(now)
+			switch {
+			case tt.wantChat == 0 && item != nil:
+				t.Fatalf("nextReady() = chat %d, want nothing", item.n.ChatID)
+			case tt.wantChat != 0 && (item == nil || item.n.ChatID != tt.wantChat):
+				t.Fatalf("nextReady() = %v, want chat %d", item, tt.wantChat)
+			}
+			if wait != tt.wantWait {
+				t.Errorf("nextReady() wait = %v, want %v", wait, tt.wantWait)
+			}
+			if item != nil && len(n.pending) != len(tt.pending)-1 {
+				t.Errorf("sent notification should leave the queue")
+			}
+		})
+	}
+}
+
+func TestNextReadyDrainsIncoming(t *testing.T) {
+	n := newTestNotifier()
+	n.incoming <- &outgoing{n: models.Notification{ChatID: 7}}
+
+	item, _ := n.nextReady(time.Now())
+	if item == nil || item.n.ChatID != 7 {
+		t.Fatalf("nextReady() = %v, want queued notification", item)
+	}
+}
+
+func TestSendDedup(t *testing.T) {
+	n := newTestNotifier()
+	ctx := context.Background()
+
+	send := func(chatID int64, key string) {
+		if err := n.Send(ctx, models.Notification{ChatID: chatID, DedupKey: key}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	send(1, "rule:5")
+	send(1, "rule:5") // duplicate
+	send(2, "rule:5") // another chat
+	send(1, "")       // no key: never deduplicated
+	send(1, "")
+	if got := len(n.incoming); got != 4 {
+		t.Errorf("queued %d notifications, want 4", got)
+	}
+
+	// The window has passed
+	for key := range n.sent {
+		n.sent[key] = time.Now().Add(-notifyDedupWindow)
+	}
+	n.forgetSent(time.Now())
+	send(1, "rule:5")
+	if got := len(n.incoming); got != 5 {
+		t.Errorf("queued %d notifications after the window, want 5", got)
+	}
+}
+
+func TestSendQueueFullKeepsNoDedupKey(t *testing.T) {
+	n := newTestNotifier()
+	n.incoming = make(chan *outgoing, 1)
+	ctx := context.Background()
+
+	if err := n.Send(ctx, models.Notification{ChatID: 1, DedupKey: "a"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := n.Send(ctx, models.Notification{ChatID: 1, DedupKey: "b"}); err == nil {
+		t.Fatal("Send() to a full queue should fail")
+	}
+
+	<-n.incoming
+	if err := n.Send(ctx, models.Notification{ChatID: 1, DedupKey: "b"}); err != nil {
+		t.Fatalf("retry Send() error = %v", err)
+	}
+	if got := len(n.incoming); got != 1 {
+		t.Errorf("retry after a full queue was dropped as a duplicate")
+	}
+}
@@ -0,0 +1,623 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+)
+
+const (
+	// Максимальная длительность "for": дольше условие не удерживается без перерывов в данных
+	maxRuleDuration = 24 * time.Hour
+
+	// Границы интервала повтора и задержки эскалации
+	minAlertRepeat = time.Minute
+	maxAlertRepeat = 7 * 24 * time.Hour
+	// Максимум получателей эскалации
+	maxEscalationRecipients = 10
+	// Повторное срабатывание правила с теми же значениями в течение окна не создает нового оповещения
+	alertFlapWindow = 10 * time.Minute
+)
+
+// ruleFire - последнее срабатывание правила
+type ruleFire struct {
+	snapshot string
+	at       time.Time
+}
+
+// ruleState - скомпилированное правило и состояние его проверки
+type ruleState struct {
+	rule   entities.AlertRule
+	cond   *predicate
+	since  time.Time           // условие выполняется непрерывно с этого момента (zero = не выполняется)
+	firing bool                // уведомление уже отправлено, ждем пока условие перестанет выполняться
+	last   models.KafkaMessage // последнее сообщение, на котором условие выполнялось
+}
+
+type alertUsecase struct {
+	repo     interfaces.UserRepository
+	notifyUC interfaces.NotificationUsecase
+
+	mu sync.Mutex
+	// Enabled rules by (target, key), rebuilt by ReloadRules
+	rules map[cacheKey][]*ruleState
+	// После перезапуска consumer group дочитывает сообщения с последнего commit:
+	// записи старше запуска уже проверялись и не должны срабатывать заново
+	startedAt time.Time
+	// Last fire per rule ID, guards against flapping rules
+	lastFired map[uint]ruleFire
+}
+
+func NewAlertUsecase(repo interfaces.UserRepository, notifyUC interfaces.NotificationUsecase) interfaces.AlertUsecase {
+	return &alertUsecase{
+		repo:      repo,
+		notifyUC:  notifyUC,
+		rules:     make(map[cacheKey][]*ruleState),
+		startedAt: time.Now(),
+		lastFired: make(map[uint]ruleFire),
+	}
+}
+
+// --- Rules Management ---
+
+func (u *alertUsecase) CreateRule(userID int64, targetID, keyID uint, expr string) (*entities.AlertRule, error) {
+	condition, duration, err := parseAlertRule(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := u.repo.GetTargetByID(targetID)
+	if err != nil || target.UserID != userID {
+		return nil, fmt.Errorf("kafka target не найден")
+	}
+	if keyID > 0 {
+		key, err := u.repo.GetKeyByID(keyID)
+		if err != nil || key.TargetID != targetID {
+			return nil, fmt.Errorf("ключ не найден")
+		}
+	}
+
+	rule := &entities.AlertRule{
+		UserID:      userID,
+		TargetID:    targetID,
+		KeyID:       keyID,
+		Condition:   condition,
+		DurationSec: int(duration / time.Second),
+		Enabled:     true,
+		Severity:    models.SeverityWarning,
+	}
+	if err := u.repo.AddAlertRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, u.ReloadRules()
+}
+
+func (u *alertUsecase) GetRules(targetID, keyID uint) ([]entities.AlertRule, error) {
+	return u.repo.GetAlertRules(targetID, keyID)
+}
+
+func (u *alertUsecase) GetRule(ruleID uint) (*entities.AlertRule, error) {
+	return u.repo.GetAlertRuleByID(ruleID)
+}
+
+func (u *alertUsecase) ToggleRule(userID int64, ruleID uint) error {
+	rule, err := u.repo.GetAlertRuleByID(ruleID)
+	if err != nil || rule.UserID != userID {
+		return fmt.Errorf("правило не найдено")
+	}
+	if err := u.repo.UpdateAlertRule(ruleID, map[string]interface{}{"enabled": !rule.Enabled}); err != nil {
+		return err
+	}
+	return u.ReloadRules()
+}
+
+func (u *alertUsecase) DeleteRule(userID int64, ruleID uint) error {
+	if err := u.repo.DeleteAlertRule(ruleID, userID); err != nil {
+		return err
+	}
+	return u.ReloadRules()
+}
+
+func (u *alertUsecase) SetRuleSeverity(userID int64, ruleID uint, severity string) error {
+	rule, err := u.repo.GetAlertRuleByID(ruleID)
+	if err != nil || rule.UserID != userID {
+		return fmt.Errorf("правило не найдено")
+	}
+	if models.Severities[models.SeverityLevel(severity)] != severity {
+		return fmt.Errorf("неизвестная важность '%s'", severity)
+	}
+	return u.repo.UpdateAlertRule(ruleID, map[string]interface{}{"severity": severity})
+}
+
+func (u *alertUsecase) SetRenotify(userID int64, ruleID uint, interval time.Duration) error {
+	rule, err := u.repo.GetAlertRuleByID(ruleID)
+	if err != nil || rule.UserID != userID {
+		return fmt.Errorf("правило не найдено")
+	}
+	if interval != 0 && (interval < minAlertRepeat || interval > maxAlertRepeat) {
+		return fmt.Errorf("допустимый интервал: от %s до %s", models.HumanDuration(minAlertRepeat), models.HumanDuration(maxAlertRepeat))
+	}
+	return u.repo.UpdateAlertRule(ruleID, map[string]interface{}{"renotify_sec": int(interval / time.Second)})
+}
+
+func (u *alertUsecase) SetEscalation(userID int64, ruleID uint, expr string) error {
+	rule, err := u.repo.GetAlertRuleByID(ruleID)
+	if err != nil || rule.UserID != userID {
+		return fmt.Errorf("правило не найдено")
+	}
+	delay, recipients, err := parseEscalation(expr)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(recipients))
+	for _, id := range recipients {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	return u.repo.UpdateAlertRule(ruleID, map[string]interface{}{
+		"escalate_sec": int(delay / time.Second),
+		"escalate_to":  strings.Join(ids, ","),
+	})
+}
+
+// --- Alerts Workflow ---
+
+func (u *alertUsecase) GetAlert(userID int64, alertID uint) (*entities.Alert, error) {
+	alert, err := u.repo.GetAlertByID(alertID)
+	if err != nil || !alertRecipient(alert, userID) {
+		return nil, fmt.Errorf("оповещение не найдено")
+	}
+	return alert, nil
+}
+
+func (u *alertUsecase) ListAlerts(filter models.AlertFilter, offset, limit int) ([]entities.Alert, int64, error) {
+	return u.repo.GetAlerts(filter, offset, limit)
+}
+
+func (u *alertUsecase) GetAlertMachines(userID int64) ([]string, error) {
+	return u.repo.GetAlertMachines(userID)
+}
+
+func (u *alertUsecase) GetAlertEvents(alertID uint) ([]entities.AlertEvent, error) {
+	return u.repo.GetAlertEvents(alertID)
+}
+
+func (u *alertUsecase) Acknowledge(userID int64, userName string, alertID uint) (*entities.Alert, error) {
+	now := time.Now()
+	return u.transition(userID, userName, alertID, entities.AlertActionAcked,
+		[]string{entities.AlertStatusOpen},
+		map[string]interface{}{
+			"status":   entities.AlertStatusAcked,
+			"acked_by": userID,
+			"acked_at": now,
+		})
+}
+
+func (u *alertUsecase) Resolve(userID int64, userName string, alertID uint) (*entities.Alert, error) {
+	now := time.Now()
+	return u.transition(userID, userName, alertID, entities.AlertActionResolved,
+		[]string{entities.AlertStatusOpen, entities.AlertStatusAcked},
+		map[string]interface{}{
+			"status":      entities.AlertStatusResolved,
+			"resolved_by": userID,
+			"resolved_at": now,
+		})
+}
+
+// transition moves the alert to the next status and records who did it
+func (u *alertUsecase) transition(userID int64, userName string, alertID uint, action string, from []string, updates map[string]interface{}) (*entities.Alert, error) {
+	alert, err := u.repo.GetAlertByID(alertID)
+	if err != nil || !alertRecipient(alert, userID) {
+		return nil, fmt.Errorf("оповещение не найдено")
+	}
+
+	ok, err := u.repo.TransitionAlert(alertID, from, updates)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("оповещение уже обработано")
+	}
+	u.addEvent(alertID, action, userID, userName)
+
+	return u.repo.GetAlertByID(alertID)
+}
+
+func (u *alertUsecase) addEvent(alertID uint, action string, userID int64, userName string) {
+	event := &entities.AlertEvent{AlertID: alertID, Action: action, UserID: userID, UserName: userName}
+	if err := u.repo.AddAlertEvent(event); err != nil {
+		log.Printf("⚠️ Не удалось сохранить событие %s оповещения %d: %v", action, alertID, err)
+	}
+}
+
+func (u *alertUsecase) CheckEscalations(ctx context.Context, now time.Time) {
+	alerts, err := u.repo.GetOpenAlerts()
+	if err != nil {
+		log.Printf("⚠️ Не удалось загрузить открытые оповещения: %v", err)
+		return
+	}
+
+	rules := make(map[uint]*entities.AlertRule)
+	for _, a := range alerts {
+		rule, ok := rules[a.RuleID]
+		if !ok {
+			// Deleted rule leaves its alerts in history without repeats
+			rule, _ = u.repo.GetAlertRuleByID(a.RuleID)
+			rules[a.RuleID] = rule
+		}
+		if rule == nil {
+			continue
+		}
+		u.escalate(ctx, a, rule, now)
+	}
+}
+
+// escalate sends the alert to the escalation list once its delay has passed,
+// otherwise repeats it to everyone who has received it so far
+func (u *alertUsecase) escalate(ctx context.Context, a entities.Alert, rule *entities.AlertRule, now time.Time) {
+	escalateAfter := time.Duration(rule.EscalateSec) * time.Second
+	if a.EscalatedAt == nil && escalateAfter > 0 && rule.EscalateTo != "" && now.Sub(a.FiredAt) >= escalateAfter {
+		header := fmt.Sprintf("📣 <b>Эскалация</b>: не подтверждено за %s\n\n", models.HumanDuration(now.Sub(a.FiredAt)))
+		for _, chatID := range parseUserIDs(rule.EscalateTo) {
+			u.send(ctx, a, chatID, header, fmt.Sprintf("alert:%d:esc", a.ID))
+		}
+		err := u.repo.UpdateAlert(a.ID, map[string]interface{}{
+			"escalated_at":     now,
+			"escalated_to":     rule.EscalateTo,
+			"last_notified_at": now,
+		})
+		if err != nil {
+			log.Printf("⚠️ Не удалось сохранить эскалацию оповещения %d: %v", a.ID, err)
+		}
+		u.addEvent(a.ID, entities.AlertActionEscalated, 0, "")
+		return
+	}
+
+	renotifyEvery := time.Duration(rule.RenotifySec) * time.Second
+	if renotifyEvery <= 0 || now.Sub(a.LastNotifiedAt) < renotifyEvery {
+		return
+	}
+	header := fmt.Sprintf("🔁 <b>Повтор #%d</b>: оповещение не подтверждено\n\n", a.NotifyCount)
+	dedupKey := fmt.Sprintf("alert:%d:repeat:%d", a.ID, a.NotifyCount)
+	u.send(ctx, a, a.UserID, header, dedupKey)
+	for _, chatID := range parseUserIDs(a.EscalatedTo) {
+		if chatID != a.UserID {
+			u.send(ctx, a, chatID, header, dedupKey)
+		}
+	}
+	err := u.repo.UpdateAlert(a.ID, map[string]interface{}{
+		"last_notified_at": now,
+		"notify_count":     a.NotifyCount + 1,
+	})
+	if err != nil {
+		log.Printf("⚠️ Не удалось сохранить повтор оповещения %d: %v", a.ID, err)
+	}
+	u.addEvent(a.ID, entities.AlertActionRenotified, 0, "")
+}
+
+func (u *alertUsecase) send(ctx context.Context, a entities.Alert, chatID int64, header, dedupKey string) {
+	n := models.Notification{
+		ChatID:    chatID,
+		Text:      header + a.Text,
+		Severity:  a.Severity,
+		MachineID: a.MachineID,
+		DedupKey:  dedupKey,
+		Buttons:   alertButtons(a),
+	}
+	if err := u.notifyUC.Notify(ctx, n); err != nil {
+		log.Printf("⚠️ Не удалось отправить оповещение %d в чат %d: %v", a.ID, chatID, err)
+	}
+}
+
+// alertButtons - действия, доступные в текущем статусе оповещения
+func alertButtons(a entities.Alert) []models.NotificationButton {
+	switch a.Status {
+	case entities.AlertStatusOpen:
+		return []models.NotificationButton{
+			{Text: "👀 Принять", Data: fmt.Sprintf("alert_ack:%d", a.ID)},
+			{Text: "✅ Решено", Data: fmt.Sprintf("alert_res:%d", a.ID)},
+		}
+	case entities.AlertStatusAcked:
+		return []models.NotificationButton{{Text: "✅ Решено", Data: fmt.Sprintf("alert_res:%d", a.ID)}}
+	}
+	return nil
+}
+
+// alertRecipient reports whether the user has received the alert and may handle it
+func alertRecipient(a *entities.Alert, userID int64) bool {
+	if a.UserID == userID {
+		return true
+	}
+	for _, id := range parseUserIDs(a.EscalatedTo) {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// --- Background Evaluator ---
+
+func (u *alertUsecase) ReloadRules() error {
+	rules, err := u.repo.GetEnabledAlertRules()
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	previous := make(map[uint]*ruleState)
+	for _, states := range u.rules {
+		for _, rs := range states {
+			previous[rs.rule.ID] = rs
+		}
+	}
+
+	index := make(map[cacheKey][]*ruleState)
+	for _, r := range rules {
+		ck := cacheKey{TargetID: r.TargetID, KeyID: r.KeyID}
+
+		// Unchanged rule keeps its pending/firing state
+		if prev, ok := previous[r.ID]; ok && prev.rule.Condition == r.Condition && prev.rule.DurationSec == r.DurationSec {
+			prev.rule = r
+			index[ck] = append(index[ck], prev)
+			continue
+		}
+
+		cond, err := parsePredicate(r.Condition)
+		if err != nil || cond == nil {
+			log.Printf("⚠️ Правило %d пропущено: %v", r.ID, err)
+			continue
+		}
+		index[ck] = append(index[ck], &ruleState{rule: r, cond: cond})
+	}
+	u.rules = index
+	return nil
+}
+
+func (u *alertUsecase) Evaluate(ctx context.Context, ev models.TelemetryEvent) {
+	if !ev.Message.Time.IsZero() && ev.Message.Time.Before(u.startedAt) {
+		return
+	}
+	now := time.Now()
+	var fired []ruleState
+
+	u.mu.Lock()
+	for _, rs := range u.rules[cacheKey{TargetID: ev.TargetID, KeyID: ev.KeyID}] {
+		if !rs.cond.match(ev.Message.Value) {
+			rs.since, rs.firing = time.Time{}, false
+			continue
+		}
+
+		if rs.since.IsZero() {
+			rs.since = now
+		}
+		rs.last = ev.Message
+		if !rs.firing && now.Sub(rs.since) >= rs.duration() {
+			rs.firing = true
+			fired = append(fired, *rs)
+		}
+	}
+	u.mu.Unlock()
+
+	for _, rs := range fired {
+		u.notify(ctx, rs)
+	}
+}
+
+func (u *alertUsecase) CheckPending(ctx context.Context, now time.Time) {
+	var fired []ruleState
+
+	u.mu.Lock()
+	for _, states := range u.rules {
+		for _, rs := range states {
+			if rs.since.IsZero() || rs.firing || now.Sub(rs.since) < rs.duration() {
+				continue
+			}
+			rs.firing = true
+			fired = append(fired, *rs)
+		}
+	}
+	u.mu.Unlock()
+
+	for _, rs := range fired {
+		u.notify(ctx, rs)
+	}
+}
+
+func (rs *ruleState) duration() time.Duration {
+	return time.Duration(rs.rule.DurationSec) * time.Second
+}
+
+// notify stores the triggered rule as an alert and pushes it to the owner
+func (u *alertUsecase) notify(ctx context.Context, rs ruleState) {
+	snapshot := rs.cond.snapshot(rs.last.Value)
+	// Cut on rune boundaries: a cut multibyte character is rejected by Postgres and Telegram
+	if runes := []rune(snapshot); len(runes) > 500 {
+		snapshot = string(runes[:500]) + "…"
+	}
+	// A flapping rule with the same values is stored and reported once per window:
+	// an alert nobody was notified about would only be escalated later
+	if u.flapping(rs.rule.ID, snapshot, time.Now()) {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("🚨 <b>Правило сработало</b>\n")
+
+	var targetName, keyTitle string
+	if target, err := u.repo.GetTargetByID(rs.rule.TargetID); err == nil {
+		targetName, keyTitle = target.Name, ruleKeyTitle(target, rs.rule.KeyID)
+		b.WriteString(fmt.Sprintf("📋 %s", html.EscapeString(targetName)))
+		b.WriteString(" · " + html.EscapeString(keyTitle) + "\n")
+	}
+	if machineID := messageMachineID(rs.last.Value); machineID != "" {
+		b.WriteString(fmt.Sprintf("🏭 Станок: <code>%s</code>\n", html.EscapeString(machineID)))
+	}
+	b.WriteString(fmt.Sprintf("📏 <code>%s</code>\n", html.EscapeString(rs.rule.String())))
+	if snapshot != "" {
+		b.WriteString(fmt.Sprintf("📈 <code>%s</code>\n", html.EscapeString(snapshot)))
+	}
+	b.WriteString(fmt.Sprintf("🕒 %s", rs.since.Local().Format("2006-01-02 15:04:05")))
+
+	now := time.Now()
+	targetID := rs.rule.TargetID
+	alert := entities.Alert{
+		RuleID:         rs.rule.ID,
+		UserID:         rs.rule.UserID,
+		TargetID:       &targetID,
+		KeyID:          rs.rule.KeyID,
+		TargetName:     targetName,
+		KeyTitle:       keyTitle,
+		RuleText:       rs.rule.String(),
+		Severity:       rs.rule.Severity,
+		MachineID:      messageMachineID(rs.last.Value),
+		Snapshot:       snapshot,
+		Text:           b.String(),
+		Status:         entities.AlertStatusOpen,
+		FiredAt:        now,
+		LastNotifiedAt: now,
+		NotifyCount:    1,
+	}
+	if err := u.repo.AddAlert(&alert); err != nil {
+		// Without a stored alert there is nothing to acknowledge, the owner still gets the message
+		log.Printf("⚠️ Не удалось сохранить оповещение правила %d: %v", rs.rule.ID, err)
+		alert.Status = ""
+	} else {
+		u.addEvent(alert.ID, entities.AlertActionFired, 0, "")
+	}
+
+	// Flapping is handled above, every stored alert reaches its owner
+	u.send(ctx, alert, rs.rule.UserID, "", "")
+}
+
+// flapping reports that the rule already fired with the same values within alertFlapWindow
+// and otherwise remembers this fire
+func (u *alertUsecase) flapping(ruleID uint, snapshot string, now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if last, ok := u.lastFired[ruleID]; ok && last.snapshot == snapshot && now.Sub(last.at) < alertFlapWindow {
+		return true
+	}
+	u.lastFired[ruleID] = ruleFire{snapshot: snapshot, at: now}
+	return false
+}
+
+// ruleKeyTitle names the entry point of the rule inside the target
+func ruleKeyTitle(target *entities.MonitoringTarget, keyID uint) string {
+	if keyID == 0 {
+		return "📂 По умолчанию"
+	}
+	for _, k := range target.Keys {
+		if k.ID == keyID {
+			return "🔑 " + keyFilter(&k).String()
+		}
+	}
+	return fmt.Sprintf("🔑 #%d", keyID)
+}
+
+// messageMachineID extracts machine_id of a FanucMessage (empty for other payloads)
+func messageMachineID(value string) string {
+	var msg models.FanucMessage
+	if err := json.Unmarshal([]byte(value), &msg); err != nil {
+		return ""
+	}
+	return msg.MachineID
+}
+
+// parseUserIDs splits the stored "id,id" list of Telegram IDs
+func parseUserIDs(s string) []int64 {
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// --- Rule Parsing ---
+
+var ruleDurationSuffix = regexp.MustCompile(`(?i)^(.*\S)\s+for\s+(\S+)$`)
+
+// parseAlertRule splits "data.spindle.load > 120 for 30s" into the condition and its duration
+func parseAlertRule(expr string) (string, time.Duration, error) {
+	expr = strings.TrimSpace(expr)
+	condition := expr
+	var duration time.Duration
+
+	if m := ruleDurationSuffix.FindStringSubmatch(expr); m != nil {
+		condition = m[1]
+		d, err := time.ParseDuration(m[2])
+		if err != nil {
+			// Bare number means seconds: "for 30"
+			secs, errNum := strconv.Atoi(m[2])
+			if errNum != nil {
+				return "", 0, fmt.Errorf("некорректная длительность '%s' (примеры: 30s, 5m, 1h)", m[2])
+			}
+			d = time.Duration(secs) * time.Second
+		}
+		if d < 0 || d > maxRuleDuration {
+			return "", 0, fmt.Errorf("длительность должна быть от 0 до %s", maxRuleDuration)
+		}
+		duration = d.Truncate(time.Second)
+	}
+
+	cond, err := parsePredicate(condition)
+	if err != nil {
+		return "", 0, err
+	}
+	if cond == nil {
+		return "", 0, fmt.Errorf("пустое условие")
+	}
+	return condition, duration, nil
+}
+
+// parseEscalation splits "30m 123456789, 987654321" into the delay and Telegram IDs; "-" disables escalation
+func parseEscalation(expr string) (time.Duration, []int64, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "-" {
+		return 0, nil, nil
+	}
+
+	fields := strings.Fields(strings.ReplaceAll(expr, ",", " "))
+	if len(fields) < 2 {
+		return 0, nil, fmt.Errorf("укажите задержку и хотя бы один Telegram ID")
+	}
+	delay, err := time.ParseDuration(fields[0])
+	if err != nil {
+		return 0, nil, fmt.Errorf("некорректная задержка '%s' (примеры: 15m, 1h)", fields[0])
+	}
+	if delay < minAlertRepeat || delay > maxAlertRepeat {
+		return 0, nil, fmt.Errorf("допустимая задержка: от %s до %s", models.HumanDuration(minAlertRepeat), models.HumanDuration(maxAlertRepeat))
+	}
+
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, f := range fields[1:] {
+		id, err := strconv.ParseInt(f, 10, 64)
+		if err != nil || id == 0 {
+			return 0, nil, fmt.Errorf("некорректный Telegram ID '%s'", f)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > maxEscalationRecipients {
+		return 0, nil, fmt.Errorf("не больше %d получателей эскалации", maxEscalationRecipients)
+	}
+	return delay, ids, nil
+}
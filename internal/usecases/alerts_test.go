@@ -0,0 +1,141 @@
+package usecases
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	tests := []struct {
+		expr      string
+		condition string
+		duration  time.Duration
+		wantErr   bool
+	}{
+		{expr: "a > 1", condition: "a > 1"},
+		{expr: "a > 1 for 5s", condition: "a > 1", duration: 5 * time.Second},
+		{expr: "x.y==2 for 30", condition: "x.y==2", duration: 30 * time.Second},
+		{expr: "data.spindle.load > 120 FOR 2m", condition: "data.spindle.load > 120", duration: 2 * time.Minute},
+		{expr: "data.alarms not empty for 1500ms", condition: "data.alarms not empty", duration: time.Second},
+		{expr: "a > 1 for 25h", wantErr: true},
+		{expr: "a > 1 for soon", wantErr: true},
+		{expr: "a for 5s", wantErr: true},
+		{expr: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			condition, duration, err := parseAlertRule(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAlertRule(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if condition != tt.condition || duration != tt.duration {
+				t.Errorf("parseAlertRule(%q) = %q, %v; want %q, %v", tt.expr, condition, duration, tt.condition, tt.duration)
+			}
+		})
+	}
+}
+
+func TestParseEscalation(t *testing.T) {
+	tests := []struct {
+		expr    string
+		delay   time.Duration
+		ids     []int64
+		wantErr bool
+	}{
+		{expr: "-"},
+		{expr: " - "},
+		{expr: "15m 123", delay: 15 * time.Minute, ids: []int64{123}},
+		{expr: "1h 123, 456", delay: time.Hour, ids: []int64{123, 456}},
+		{expr: "1h 123,456,123", delay: time.Hour, ids: []int64{123, 456}},
+		{expr: "1h -100200", delay: time.Hour, ids: []int64{-100200}},
+		{expr: "15m", wantErr: true},
+		{expr: "soon 123", wantErr: true},
+		{expr: "30s 123", wantErr: true},
+		{expr: "200h 123", wantErr: true},
+		{expr: "1h abc", wantErr: true},
+		{expr: "1h 0", wantErr: true},
+		{expr: "1h 1 2 3 4 5 6 7 8 9 10 11", wantErr: true},
+		{expr: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			delay, ids, err := parseEscalation(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseEscalation(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if delay != tt.delay || !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("parseEscalation(%q) = %v, %v; want %v, %v", tt.expr, delay, ids, tt.delay, tt.ids)
+			}
+		})
+	}
+}
+
+func TestParseUserIDs(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []int64
+	}{
+		{"", nil},
+		{"123", []int64{123}},
+		{"123, 456", []int64{123, 456}},
+		{"123,,x,456", []int64{123, 456}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if got := parseUserIDs(tt.raw); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseUserIDs(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateSkipsReplayedMessages(t *testing.T) {
+	pred, err := parsePredicate("data.load > 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	rs := &ruleState{rule: entities.AlertRule{ID: 1, DurationSec: 30}, cond: pred}
+	u := &alertUsecase{
+		rules:     map[cacheKey][]*ruleState{{TargetID: 1}: {rs}},
+		startedAt: start,
+	}
+
+	value := `{"data":{"load":150}}`
+	u.Evaluate(context.Background(), models.TelemetryEvent{TargetID: 1, Message: models.KafkaMessage{Value: value, Time: start.Add(-time.Hour)}})
+	if !rs.since.IsZero() {
+		t.Fatal("message from before the start should not be evaluated")
+	}
+	u.Evaluate(context.Background(), models.TelemetryEvent{TargetID: 1, Message: models.KafkaMessage{Value: value, Time: start.Add(time.Second)}})
+	if rs.since.IsZero() {
+		t.Fatal("new message should start the rule duration")
+	}
+}
+
+func TestFlapping(t *testing.T) {
+	u := &alertUsecase{lastFired: make(map[uint]ruleFire)}
+	now := time.Now()
+
+	steps := []struct {
+		ruleID   uint
+		snapshot string
+		at       time.Duration
+		want     bool
+	}{
+		{1, "load=150", 0, false},
+		{1, "load=150", time.Minute, true},
+		{2, "load=150", time.Minute, false},
+		{1, "load=160", 2 * time.Minute, false},
+		{1, "load=160", 2*time.Minute + alertFlapWindow, false},
+	}
+	for i, s := range steps {
+		if got := u.flapping(s.ruleID, s.snapshot, now.Add(s.at)); got != s.want {
+			t.Errorf("step %d: flapping() = %v, want %v", i, got, s.want)
+		}
+	}
+}
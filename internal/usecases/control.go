@@ -3,11 +3,17 @@ package usecases
 import (
 	"context"
 	"fmt"
+	"sync"
 
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
 	"github.com/iwtcode/fanucClient/internal/interfaces"
 	"github.com/iwtcode/fanucService"
 )
 
+// Сколько станков одного сервиса обрабатывается одновременно при массовых действиях
+const bulkParallel = 8
+
 type controlUsecase struct {
 	repo   interfaces.UserRepository
 	apiSvc interfaces.FanucApiService
@@ -95,3 +101,88 @@ func (u *controlUsecase) GetProgram(ctx context.Context, svcID uint, machineID s
 	}
 	return u.apiSvc.GetControlProgram(ctx, baseURL, apiKey, machineID)
 }
+
+func (u *controlUsecase) RunBulk(ctx context.Context, svcID uint, action string, intervalMs int, progress func([]models.BulkResult)) ([]models.BulkResult, error) {
+	switch action {
+	case models.BulkStartPolling, models.BulkStopPolling, models.BulkRecheck:
+	default:
+		return nil, fmt.Errorf("unknown bulk action: %s", action)
+	}
+
+	baseURL, apiKey, err := u.getServiceConfig(svcID)
+	if err != nil {
+		return nil, err
+	}
+	machines, err := u.apiSvc.GetConnections(ctx, baseURL, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BulkResult, len(machines))
+	for i, m := range machines {
+		results[i] = models.BulkResult{MachineID: m.ID, Endpoint: m.Endpoint}
+	}
+
+	// mu guards results and seq; progress is called outside of it, one worker at a time
+	var mu, reportMu sync.Mutex
+	seq, reported := 0, 0
+	snapshot := func() ([]models.BulkResult, int) {
+		seq++
+		return append([]models.BulkResult(nil), results...), seq
+	}
+	report := func(snap []models.BulkResult, n int) {
+		if progress == nil {
+			return
+		}
+		reportMu.Lock()
+		defer reportMu.Unlock()
+		// A newer snapshot has already been reported by another worker
+		if n <= reported {
+			return
+		}
+		reported = n
+		progress(snap)
+	}
+	report(snapshot())
+
+	sem := make(chan struct{}, bulkParallel)
+	var wg sync.WaitGroup
+	for i := range machines {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			machineID := machines[i].ID
+			var machine *fanucService.MachineDTO
+			var err error
+			switch action {
+			case models.BulkStartPolling:
+				err = u.apiSvc.StartPolling(ctx, baseURL, apiKey, machineID, intervalMs)
+			case models.BulkStopPolling:
+				err = u.apiSvc.StopPolling(ctx, baseURL, apiKey, machineID)
+			case models.BulkRecheck:
+				machine, err = u.apiSvc.CheckConnection(ctx, baseURL, apiKey, machineID)
+			}
+
+			mu.Lock()
+			results[i].Done = true
+			results[i].Err = err
+			if machine != nil {
+				results[i].Status = machine.Status
+				results[i].Mode = machine.Mode
+			}
+			snap, n := snapshot()
+			mu.Unlock()
+			report(snap, n)
+		}(i)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+func (u *controlUsecase) IsUnreachable(svc entities.FanucService) bool {
+	return u.apiSvc.IsUnreachable(svc.BaseURL)
+}
@@ -0,0 +1,315 @@
+package usecases
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+)
+
+const (
+	// Максимум сообщений в одном файле экспорта
+	exportMaxMessages = 20000
+	// Файлы больше этого размера сжимаются gzip
+	exportGzipThreshold = 512 * 1024
+)
+
+func (u *monitoringUsecase) ExportMessages(ctx context.Context, targetID uint, keyID uint, rng models.ExportRange, format string) (*models.ExportFile, error) {
+	if format != models.ExportJSONL && format != models.ExportCSV {
+		return nil, fmt.Errorf("неизвестный формат экспорта: %s", format)
+	}
+
+	target, key, err := u.resolveTargetKey(targetID, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []models.KafkaMessage
+	truncated := false
+	if rng.Last > 0 {
+		limit := rng.Last
+		if limit > exportMaxMessages {
+			limit, truncated = exportMaxMessages, true
+		}
+		messages, err = u.FetchHistory(ctx, targetID, keyID, limit)
+	} else {
+		messages, truncated, err = u.fetchWindow(ctx, targetID, keyID, rng.From, rng.To)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// File is chronological, unlike the history browser
+	sortNewestFirst(messages)
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	var data []byte
+	mime := "application/x-ndjson"
+	if format == models.ExportCSV {
+		data, err = encodeCSV(messages)
+		mime = "text/csv"
+	} else {
+		data, err = encodeJSONL(messages)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	name := exportFileName(target.Topic, key, format)
+	file := &models.ExportFile{Name: name, MIME: mime, Data: data, Count: len(messages), Truncated: truncated}
+
+	if len(data) > exportGzipThreshold {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Name = name
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		file.Name += ".gz"
+		file.MIME = "application/gzip"
+		file.Data = buf.Bytes()
+		file.Compressed = true
+	}
+
+	return file, nil
+}
+
+// fetchWindow reads every accepted message with timestamp in [from, to)
+func (u *monitoringUsecase) fetchWindow(ctx context.Context, targetID uint, keyID uint, from, to time.Time) ([]models.KafkaMessage, bool, error) {
+	if !from.Before(to) {
+		return nil, false, fmt.Errorf("начало интервала должно быть раньше конца")
+	}
+
+	target, key, err := u.resolveTargetKey(targetID, keyID)
+	if err != nil {
+		return nil, false, err
+	}
+	src := targetSource(target)
+	filter, err := compileFilter(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	starts, err := u.kafkaSvc.GetOffsetsForTime(ctx, src, from)
+	if err != nil {
+		return nil, false, fmt.Errorf("kafka error: %w", err)
+	}
+	ends, err := u.kafkaSvc.GetOffsetsForTime(ctx, src, to)
+	if err != nil {
+		return nil, false, fmt.Errorf("kafka error: %w", err)
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		messages  []models.KafkaMessage
+		truncated bool
+		firstErr  error
+	)
+	for partition, start := range starts {
+		end := ends[partition]
+		if end <= start {
+			continue
+		}
+		if end-start > exportMaxMessages {
+			end = start + exportMaxMessages
+			truncated = true
+		}
+
+		wg.Add(1)
+		go func(partition int, start, end int64) {
+			defer wg.Done()
+			batch, err := u.readRange(ctx, src, partition, start, end)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for _, m := range batch {
+				// Producer timestamps are not strictly monotonic inside a partition
+				if m.Time.Before(from) || !m.Time.Before(to) {
+					continue
+				}
+				if filter.accept(m) {
+					messages = append(messages, filter.apply(m))
+				}
+			}
+		}(partition, start, end)
+	}
+	wg.Wait()
+
+	if len(messages) == 0 && firstErr != nil {
+		return nil, false, fmt.Errorf("kafka error: %w", firstErr)
+	}
+
+	if len(messages) > exportMaxMessages {
+		// Keep the beginning of the window
+		sortNewestFirst(messages)
+		messages = messages[len(messages)-exportMaxMessages:]
+		truncated = true
+	}
+	return messages, truncated, nil
+}
+
+// --- Encoders ---
+
+type exportRecord struct {
+	Timestamp string            `json:"timestamp"`
+	Partition int               `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Key       string            `json:"key,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Value     json.RawMessage   `json:"value"`
+}
+
+func encodeJSONL(messages []models.KafkaMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	for _, m := range messages {
+		rec := exportRecord{
+			Timestamp: m.Time.UTC().Format(time.RFC3339Nano),
+			Partition: m.Partition,
+			Offset:    m.Offset,
+			Key:       m.Key,
+		}
+		if len(m.Headers) > 0 {
+			rec.Headers = make(map[string]string, len(m.Headers))
+			for _, h := range m.Headers {
+				rec.Headers[h.Key] = h.Value
+			}
+		}
+
+		// JSON payload is embedded as is, anything else as a string
+		if json.Valid([]byte(m.Value)) {
+			rec.Value = json.RawMessage(m.Value)
+		} else {
+			raw, _ := json.Marshal(m.Value)
+			rec.Value = raw
+		}
+
+		if err := enc.Encode(rec); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeCSV writes one row per message: fixed columns, then headers (header.<name>)
+// and the flattened JSON value (data.spindle.speed, data.alarms[0].code, ...)
+func encodeCSV(messages []models.KafkaMessage) ([]byte, error) {
+	rows := make([]map[string]string, len(messages))
+	columnSet := make(map[string]bool)
+
+	for i, m := range messages {
+		row := make(map[string]string)
+		for _, h := range m.Headers {
+			row["header."+h.Key] = h.Value
+		}
+
+		var doc interface{}
+		if err := json.Unmarshal([]byte(m.Value), &doc); err == nil {
+			flattenJSON("", doc, row)
+		} else {
+			row["value"] = m.Value
+		}
+
+		for col := range row {
+			columnSet[col] = true
+		}
+		rows[i] = row
+	}
+
+	columns := make([]string, 0, len(columnSet))
+	for col := range columnSet {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(append([]string{"timestamp", "partition", "offset", "key"}, columns...)); err != nil {
+		return nil, err
+	}
+	for i, m := range messages {
+		record := []string{
+			m.Time.UTC().Format(time.RFC3339Nano),
+			strconv.Itoa(m.Partition),
+			strconv.FormatInt(m.Offset, 10),
+			m.Key,
+		}
+		for _, col := range columns {
+			record = append(record, rows[i][col])
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func flattenJSON(prefix string, v interface{}, out map[string]string) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for k, child := range node {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			flattenJSON(path, child, out)
+		}
+	case []interface{}:
+		for i, child := range node {
+			flattenJSON(fmt.Sprintf("%s[%d]", prefix, i), child, out)
+		}
+	case nil:
+		out[columnName(prefix)] = ""
+	case string:
+		out[columnName(prefix)] = node
+	case float64:
+		out[columnName(prefix)] = strconv.FormatFloat(node, 'f', -1, 64)
+	case bool:
+		out[columnName(prefix)] = strconv.FormatBool(node)
+	}
+}
+
+// columnName names the top-level scalar payload
+func columnName(path string) string {
+	if path == "" {
+		return "value"
+	}
+	return path
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// exportFileName builds "<topic>_<key>_<time>.<format>" with file-system safe characters
+func exportFileName(topic string, key *entities.MonitoringKey, format string) string {
+	name := topic
+	if key != nil {
+		name += "_" + key.Key
+	}
+	name = unsafeFileChars.ReplaceAllString(name, "_")
+	return fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102-150405"), format)
+}
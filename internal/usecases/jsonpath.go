@@ -0,0 +1,428 @@
+package usecases
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --- JSON Paths ---
+// Упрощенный JSONPath: data.spindle.speed, data.alarms[*], data.axes[0].load, $.data.mode
+
+type pathStep struct {
+	field    string // имя поля объекта
+	index    int    // индекс массива, если isIndex
+	isIndex  bool
+	wildcard bool // [*] или * - все элементы массива / значения объекта
+}
+
+type jsonPath struct {
+	raw      string
+	steps    []pathStep
+	wildcard bool // путь может вернуть несколько значений
+}
+
+func parsePath(raw string) (jsonPath, error) {
+	raw = strings.TrimSpace(raw)
+	p := jsonPath{raw: raw}
+
+	rest := strings.TrimPrefix(strings.TrimPrefix(raw, "$"), ".")
+	if rest == "" {
+		return p, fmt.Errorf("пустой путь")
+	}
+
+	for _, part := range strings.Split(rest, ".") {
+		if part == "" {
+			return p, fmt.Errorf("путь '%s': пустой сегмент", raw)
+		}
+
+		name, brackets := part, ""
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			name, brackets = part[:i], part[i:]
+		}
+		switch name {
+		case "":
+		case "*":
+			p.steps = append(p.steps, pathStep{wildcard: true})
+			p.wildcard = true
+		default:
+			p.steps = append(p.steps, pathStep{field: name})
+		}
+
+		for brackets != "" {
+			end := strings.IndexByte(brackets, ']')
+			if brackets[0] != '[' || end < 0 {
+				return p, fmt.Errorf("путь '%s': незакрытая скобка", raw)
+			}
+			idx := strings.TrimSpace(brackets[1:end])
+			if idx == "*" {
+				p.steps = append(p.steps, pathStep{wildcard: true})
+				p.wildcard = true
+			} else {
+				n, err := strconv.Atoi(idx)
+				if err != nil || n < 0 {
+					return p, fmt.Errorf("путь '%s': некорректный индекс '%s'", raw, idx)
+				}
+				p.steps = append(p.steps, pathStep{index: n, isIndex: true})
+			}
+			brackets = brackets[end+1:]
+		}
+	}
+
+	return p, nil
+}
+
+// eval returns every value the path points to (empty if the path does not exist)
+func (p jsonPath) eval(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+	for _, step := range p.steps {
+		var next []interface{}
+		for _, v := range current {
+			switch node := v.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					keys := make([]string, 0, len(node))
+					for k := range node {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, node[k])
+					}
+				} else if !step.isIndex {
+					if val, ok := node[step.field]; ok {
+						next = append(next, val)
+					}
+				}
+			case []interface{}:
+				if step.wildcard {
+					next = append(next, node...)
+				} else if step.isIndex && step.index < len(node) {
+					next = append(next, node[step.index])
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// --- Projections ---
+
+// parseProjection разбирает список путей через запятую или перевод строки
+func parseProjection(raw string) ([]jsonPath, error) {
+	var paths []jsonPath
+	for _, item := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		p, err := parsePath(item)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+// project keeps only the selected fields: {"data.spindle.speed": 1200, "data.alarms[*]": [...]}.
+// Non-JSON values are returned unchanged.
+func project(paths []jsonPath, value string) string {
+	if len(paths) == 0 {
+		return value
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(value), &doc); err != nil {
+		return value
+	}
+
+	result := make(map[string]interface{}, len(paths))
+	for _, p := range paths {
+		values := p.eval(doc)
+		switch {
+		case p.wildcard:
+			if values == nil {
+				values = []interface{}{}
+			}
+			result[p.raw] = values
+		case len(values) > 0:
+			result[p.raw] = values[0]
+		default:
+			result[p.raw] = nil
+		}
+	}
+
+	out, err := json.Marshal(result)
+	if err != nil {
+		return value
+	}
+	return string(out)
+}
+
+// --- Predicates ---
+// data.mode == "AUTO" && data.spindle.speed > 0, data.alarms not empty
+
+// Операторы сравнения; двухсимвольные проверяются первыми
+var predicateOps = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+// Унарные проверки, записываются после пути; "not ..." проверяются первыми
+var unaryOps = []string{"not empty", "not exists", "empty", "exists"}
+
+type condition struct {
+	path  jsonPath
+	op    string
+	value interface{} // JSON литерал: число, строка, true/false/null
+	unary bool        // op из unaryOps, value не используется
+}
+
+type predicate struct {
+	raw   string
+	conds []condition
+}
+
+func parsePredicate(raw string) (*predicate, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	pred := &predicate{raw: raw}
+	for _, part := range strings.Split(raw, "&&") {
+		cond, err := parseCondition(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		pred.conds = append(pred.conds, cond)
+	}
+	return pred, nil
+}
+
+func parseCondition(expr string) (condition, error) {
+	for _, op := range unaryOps {
+		// Check the suffix before slicing: the expression may be shorter than the operator.
+		// Compared on expr itself, lowercasing may change the byte length of non-ASCII text.
+		suffix := " " + op
+		if len(expr) <= len(suffix) || !strings.EqualFold(expr[len(expr)-len(suffix):], suffix) {
+			continue
+		}
+		head := expr[:len(expr)-len(op)]
+		// data.mode == empty compares with the bare string "empty"
+		if strings.ContainsAny(head, "=<>!") {
+			continue
+		}
+		path, err := parsePath(head)
+		if err != nil {
+			return condition{}, err
+		}
+		return condition{path: path, op: op, unary: true}, nil
+	}
+
+	pos, op := -1, ""
+	for _, candidate := range predicateOps {
+		if i := strings.Index(expr, candidate); i > 0 && (pos < 0 || i < pos) {
+			pos, op = i, candidate
+		}
+	}
+	if pos < 0 {
+		return condition{}, fmt.Errorf("условие '%s': нет оператора сравнения (%s, %s)",
+			expr, strings.Join(predicateOps, " "), strings.Join(unaryOps, ", "))
+	}
+
+	path, err := parsePath(expr[:pos])
+	if err != nil {
+		return condition{}, err
+	}
+
+	literal := strings.TrimSpace(expr[pos+len(op):])
+	if literal == "" {
+		return condition{}, fmt.Errorf("условие '%s': нет значения", expr)
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(literal), &value); err != nil {
+		// Bare word is treated as a string: data.mode == AUTO
+		value = literal
+	}
+
+	return condition{path: path, op: op, value: value}, nil
+}
+
+// match reports whether the JSON value satisfies every condition.
+// A condition on a wildcard path holds if any of the values satisfies it.
+func (p *predicate) match(value string) bool {
+	if p == nil {
+		return true
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(value), &doc); err != nil {
+		return false
+	}
+	return p.matchDoc(doc)
+}
+
+func (p *predicate) matchDoc(doc interface{}) bool {
+	for _, c := range p.conds {
+		if c.unary {
+			if !matchUnary(c.op, c.path.eval(doc)) {
+				return false
+			}
+			continue
+		}
+
+		matched := false
+		for _, v := range c.path.eval(doc) {
+			if compareValues(v, c.op, c.value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchUnary checks every value of the path: missing path, null, "", [] and {} are empty
+func matchUnary(op string, values []interface{}) bool {
+	exists, nonEmpty := false, false
+	for _, v := range values {
+		if v != nil {
+			exists = true
+		}
+		if !isEmptyValue(v) {
+			nonEmpty = true
+		}
+	}
+
+	switch op {
+	case "exists":
+		return exists
+	case "not exists":
+		return !exists
+	case "empty":
+		return !nonEmpty
+	case "not empty":
+		return nonEmpty
+	}
+	return false
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch node := v.(type) {
+	case nil:
+		return true
+	case string:
+		return node == ""
+	case []interface{}:
+		return len(node) == 0
+	case map[string]interface{}:
+		return len(node) == 0
+	}
+	return false
+}
+
+// snapshot describes the values the conditions looked at: data.spindle.load = 135
+func (p *predicate) snapshot(value string) string {
+	if p == nil {
+		return ""
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(value), &doc); err != nil {
+		return value
+	}
+
+	seen := make(map[string]bool)
+	var parts []string
+	for _, c := range p.conds {
+		if seen[c.path.raw] {
+			continue
+		}
+		seen[c.path.raw] = true
+
+		values := c.path.eval(doc)
+		var out []byte
+		switch {
+		case len(values) == 0:
+			out = []byte("—")
+		case c.path.wildcard:
+			out, _ = json.Marshal(values)
+		default:
+			out, _ = json.Marshal(values[0])
+		}
+		parts = append(parts, fmt.Sprintf("%s = %s", c.path.raw, out))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func compareValues(actual interface{}, op string, expected interface{}) bool {
+	// Numbers (numeric strings from producers are accepted too)
+	if a, ok := toFloat(actual); ok {
+		if e, ok := toFloat(expected); ok {
+			switch op {
+			case "==":
+				return a == e
+			case "!=":
+				return a != e
+			case ">":
+				return a > e
+			case ">=":
+				return a >= e
+			case "<":
+				return a < e
+			case "<=":
+				return a <= e
+			}
+		}
+	}
+
+	// Strings
+	if a, ok := actual.(string); ok {
+		if e, ok := expected.(string); ok {
+			switch op {
+			case "==":
+				return a == e
+			case "!=":
+				return a != e
+			case ">":
+				return a > e
+			case ">=":
+				return a >= e
+			case "<":
+				return a < e
+			case "<=":
+				return a <= e
+			}
+		}
+	}
+
+	// Booleans, null, objects: equality only
+	switch op {
+	case "==":
+		return jsonEqual(actual, expected)
+	case "!=":
+		return !jsonEqual(actual, expected)
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
@@ -0,0 +1,170 @@
+package usecases
+
+import "testing"
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		raw      string
+		steps    int
+		wildcard bool
+		wantErr  bool
+	}{
+		{raw: "data.spindle.speed", steps: 3},
+		{raw: "$.data.mode", steps: 2},
+		{raw: "data.axes[0].load", steps: 4},
+		{raw: "data.alarms[*]", steps: 3, wildcard: true},
+		{raw: "data.*", steps: 2, wildcard: true},
+		{raw: "", wantErr: true},
+		{raw: "$", wantErr: true},
+		{raw: "data..mode", wantErr: true},
+		{raw: "data.axes[0", wantErr: true},
+		{raw: "data.axes[-1]", wantErr: true},
+		{raw: "data.axes[x]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			p, err := parsePath(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePath(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(p.steps) != tt.steps || p.wildcard != tt.wildcard {
+				t.Errorf("parsePath(%q) = %d steps, wildcard %v; want %d, %v", tt.raw, len(p.steps), p.wildcard, tt.steps, tt.wildcard)
+			}
+		})
+	}
+}
+
+func TestProject(t *testing.T) {
+	const doc = `{"data":{"mode":"AUTO","spindle":{"speed":1200},"alarms":[{"id":1},{"id":2}]}}`
+	tests := []struct {
+		name       string
+		projection string
+		value      string
+		want       string
+	}{
+		{"single field", "data.mode", doc, `{"data.mode":"AUTO"}`},
+		{"several fields", "data.mode, data.spindle.speed", doc, `{"data.mode":"AUTO","data.spindle.speed":1200}`},
+		{"wildcard", "data.alarms[*].id", doc, `{"data.alarms[*].id":[1,2]}`},
+		{"missing field", "data.feed", doc, `{"data.feed":null}`},
+		{"missing wildcard", "data.axes[*]", doc, `{"data.axes[*]":[]}`},
+		{"no projection", "", doc, doc},
+		{"not json", "data.mode", "plain text", "plain text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := parseProjection(tt.projection)
+			if err != nil {
+				t.Fatalf("parseProjection(%q) error = %v", tt.projection, err)
+			}
+			if got := project(paths, tt.value); got != tt.want {
+				t.Errorf("project() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPredicateMatch(t *testing.T) {
+	const doc = `{"data":{"mode":"AUTO","spindle":{"speed":1200,"load":"135"},"axes":[{"load":10},{"load":95}]}}`
+	tests := []struct {
+		predicate string
+		want      bool
+	}{
+		{`data.mode == "AUTO"`, true},
+		{`data.mode == AUTO`, true},
+		{`data.mode != "AUTO"`, false},
+		{`data.spindle.speed > 1000`, true},
+		{`data.spindle.speed >= 1200`, true},
+		{`data.spindle.speed < 1200`, false},
+		{`data.spindle.load > 120`, true},
+		{`data.axes[*].load > 90`, true},
+		{`data.axes[0].load > 90`, false},
+		{`data.mode == "AUTO" && data.spindle.speed > 0`, true},
+		{`data.mode == "AUTO" && data.spindle.speed > 5000`, false},
+		{`data.feed == 1`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.predicate, func(t *testing.T) {
+			pred, err := parsePredicate(tt.predicate)
+			if err != nil {
+				t.Fatalf("parsePredicate(%q) error = %v", tt.predicate, err)
+			}
+			if got := pred.match(doc); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePredicateErrors(t *testing.T) {
+	for _, raw := range []string{"data.spindle.speed", "data.spindle.speed ==", "data..mode == 1"} {
+		t.Run(raw, func(t *testing.T) {
+			if _, err := parsePredicate(raw); err == nil {
+				t.Errorf("parsePredicate(%q) expected error", raw)
+			}
+		})
+	}
+
+	pred, err := parsePredicate("  ")
+	if err != nil || pred != nil {
+		t.Errorf("empty predicate = %v, %v; want nil, nil", pred, err)
+	}
+	if !pred.match("not json") {
+		t.Error("nil predicate should match everything")
+	}
+}
+
+func TestPredicateUnary(t *testing.T) {
+	const doc = `{"data":{"mode":"AUTO","alarms":[],"axes":[1],"note":null}}`
+	tests := []struct {
+		predicate string
+		want      bool
+	}{
+		{"data.alarms empty", true},
+		{"data.alarms not empty", false},
+		{"data.axes NOT EMPTY", true},
+		{"data.mode exists", true},
+		{"data.note exists", false},
+		{"data.feed not exists", true},
+		{"data.mode == empty", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.predicate, func(t *testing.T) {
+			pred, err := parsePredicate(tt.predicate)
+			if err != nil {
+				t.Fatalf("parsePredicate(%q) error = %v", tt.predicate, err)
+			}
+			if got := pred.match(doc); got != tt.want {
+				t.Errorf("match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Expressions shorter than the unary operators must not panic
+func TestParsePredicateShort(t *testing.T) {
+	tests := []struct {
+		raw     string
+		wantErr bool
+	}{
+		{"a > 1", false},
+		{"x.y==2", false},
+		{"a<1", false},
+		{"a empty", false},
+		{"a", true},
+		{"==", true},
+		{"== 1", true},
+		{"empty", true},
+		{" exists", true},
+		{"ё > 1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if _, err := parsePredicate(tt.raw); (err != nil) != tt.wantErr {
+				t.Errorf("parsePredicate(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+		})
+	}
+}
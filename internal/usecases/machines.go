@@ -0,0 +1,259 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+	"github.com/iwtcode/fanucService"
+)
+
+const (
+	// Таймаут опроса одного сервиса фоновым наблюдателем
+	machinePollTimeout = 15 * time.Second
+	// Сколько сервисов опрашивается одновременно
+	machinePollParallel = 8
+
+	// Псевдо-статус станка, пропавшего из списка сервиса
+	machineStatusRemoved = "removed"
+)
+
+// machineKey - станок на конкретном сервисе
+type machineKey struct {
+	SvcID     uint
+	MachineID string
+}
+
+type machineState struct {
+	Status string
+	Mode   string
+}
+
+type machineWatchUsecase struct {
+	repo      interfaces.UserRepository
+	controlUC interfaces.ControlUsecase
+	notifyUC  interfaces.NotificationUsecase
+
+	mu sync.Mutex
+	// Last seen state per machine, seeded from the status log on first sight
+	known map[machineKey]machineState
+}
+
+func NewMachineWatchUsecase(
+	repo interfaces.UserRepository,
+	controlUC interfaces.ControlUsecase,
+	notifyUC interfaces.NotificationUsecase,
+) interfaces.MachineWatchUsecase {
+	return &machineWatchUsecase{
+		repo:      repo,
+		controlUC: controlUC,
+		notifyUC:  notifyUC,
+		known:     make(map[machineKey]machineState),
+	}
+}
+
+// --- Subscriptions ---
+
+func (u *machineWatchUsecase) SetSubscription(userID int64, svcID uint, machineID string, subscribed bool) error {
+	svc, err := u.repo.GetServiceByID(svcID)
+	if err != nil || svc.UserID != userID {
+		return fmt.Errorf("сервис не найден")
+	}
+	if !subscribed {
+		return u.repo.DeleteMachineSubscription(userID, svcID, machineID)
+	}
+	return u.repo.AddMachineSubscription(&entities.MachineSubscription{
+		UserID:    userID,
+		ServiceID: svcID,
+		MachineID: machineID,
+	})
+}
+
+func (u *machineWatchUsecase) IsSubscribed(userID int64, svcID uint, machineID string) (bool, error) {
+	sub, err := u.repo.GetMachineSubscription(userID, svcID, machineID)
+	return sub != nil, err
+}
+
+// --- Background Watcher ---
+
+func (u *machineWatchUsecase) PollServices(ctx context.Context) {
+	services, err := u.repo.GetAllServices()
+	if err != nil {
+		log.Printf("⚠️ Наблюдатель станков: не удалось загрузить сервисы: %v", err)
+		return
+	}
+
+	// The watcher calls PollServices from its ticker and waits for it, so polls never overlap:
+	// a slow service only delays the next tick, each poll is bounded by machinePollTimeout
+	sem := make(chan struct{}, machinePollParallel)
+	var wg sync.WaitGroup
+	for _, svc := range services {
+		wg.Add(1)
+		go func(svc entities.FanucService) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			u.pollService(ctx, svc)
+		}(svc)
+	}
+	wg.Wait()
+}
+
+func (u *machineWatchUsecase) pollService(ctx context.Context, svc entities.FanucService) {
+	pollCtx, cancel := context.WithTimeout(ctx, machinePollTimeout)
+	machines, err := u.controlUC.ListMachines(pollCtx, svc.ID)
+	cancel()
+	if err != nil {
+		// Unreachable service says nothing about the machines themselves
+		if ctx.Err() == nil {
+			log.Printf("⚠️ Наблюдатель станков: сервис %d недоступен: %v", svc.ID, err)
+		}
+		return
+	}
+
+	seen := make(map[string]bool, len(machines))
+	for _, m := range machines {
+		seen[m.ID] = true
+		u.observe(ctx, svc, m.ID, machineTitle(m), machineState{Status: m.Status, Mode: m.Mode})
+	}
+
+	// Machines that disappeared from the service since the last poll
+	u.mu.Lock()
+	var removed []string
+	for key, state := range u.known {
+		if key.SvcID == svc.ID && !seen[key.MachineID] && state.Status != machineStatusRemoved {
+			removed = append(removed, key.MachineID)
+		}
+	}
+	u.mu.Unlock()
+	for _, machineID := range removed {
+		u.observe(ctx, svc, machineID, machineID, machineState{Status: machineStatusRemoved})
+	}
+}
+
+// observe records the state and notifies subscribers if it differs from the previous one
+func (u *machineWatchUsecase) observe(ctx context.Context, svc entities.FanucService, machineID, title string, state machineState) {
+	key := machineKey{SvcID: svc.ID, MachineID: machineID}
+
+	u.mu.Lock()
+	prev, ok := u.known[key]
+	u.mu.Unlock()
+
+	if !ok {
+		last, err := u.repo.GetLastMachineStatus(svc.ID, machineID)
+		if err != nil {
+			log.Printf("⚠️ Наблюдатель станков: %v", err)
+			return
+		}
+		if last == nil {
+			// First sight: remember the baseline without notifying
+			u.remember(key, state)
+			u.record(svc.ID, machineID, machineState{}, state)
+			return
+		}
+		prev = machineState{Status: last.Status, Mode: last.Mode}
+	}
+
+	u.remember(key, state)
+	if prev == state {
+		return
+	}
+	u.record(svc.ID, machineID, prev, state)
+	u.notifySubscribers(ctx, svc, machineID, title, prev, state)
+}
+
+func (u *machineWatchUsecase) remember(key machineKey, state machineState) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if state.Status == machineStatusRemoved {
+		delete(u.known, key)
+		return
+	}
+	u.known[key] = state
+}
+
+func (u *machineWatchUsecase) record(svcID uint, machineID string, prev, state machineState) {
+	entry := &entities.MachineStatusLog{
+		ServiceID:  svcID,
+		MachineID:  machineID,
+		Status:     state.Status,
+		Mode:       state.Mode,
+		PrevStatus: prev.Status,
+		PrevMode:   prev.Mode,
+	}
+	if err := u.repo.AddMachineStatus(entry); err != nil {
+		log.Printf("⚠️ Наблюдатель станков: не удалось сохранить статус %s: %v", machineID, err)
+	}
+}
+
+func (u *machineWatchUsecase) notifySubscribers(ctx context.Context, svc entities.FanucService, machineID, title string, prev, state machineState) {
+	subs, err := u.repo.GetMachineSubscribers(svc.ID, machineID)
+	if err != nil || len(subs) == 0 {
+		return
+	}
+
+	text := fmt.Sprintf("🔔 <b>Станок изменил состояние</b>\n"+
+		"🌐 %s\n"+
+		"📟 %s\n",
+		html.EscapeString(svc.Name), html.EscapeString(title))
+	if state.Status == machineStatusRemoved {
+		text += "🗑 Станок удален из сервиса"
+	} else {
+		if prev.Status != state.Status {
+			text += fmt.Sprintf("Status: %s %s → %s <b>%s</b>\n",
+				machineStatusIcon(prev.Status), html.EscapeString(prev.Status), machineStatusIcon(state.Status), html.EscapeString(state.Status))
+		}
+		if prev.Mode != state.Mode {
+			text += fmt.Sprintf("Mode: %s %s → %s <b>%s</b>\n",
+				machineModeIcon(prev.Mode), html.EscapeString(prev.Mode), machineModeIcon(state.Mode), html.EscapeString(state.Mode))
+		}
+	}
+
+	// Lost connection needs attention, the rest is informational
+	severity := models.SeverityInfo
+	if state.Status != prev.Status && state.Status != "connected" {
+		severity = models.SeverityWarning
+	}
+
+	// No DedupKey: transitions are already deduplicated against u.known,
+	// a machine flapping back to the same state is a new event
+	for _, sub := range subs {
+		n := models.Notification{
+			ChatID:    sub.UserID,
+			Text:      text,
+			Severity:  severity,
+			MachineID: machineID,
+		}
+		if err := u.notifyUC.Notify(ctx, n); err != nil {
+			log.Printf("⚠️ Не удалось отправить уведомление о станке %s: %v", machineID, err)
+		}
+	}
+}
+
+func machineTitle(m fanucService.MachineDTO) string {
+	return fmt.Sprintf("%s (%s)", m.Model, m.Endpoint)
+}
+
+func machineStatusIcon(status string) string {
+	if status == "connected" {
+		return "🟢"
+	}
+	return "🔴"
+}
+
+func machineModeIcon(mode string) string {
+	if mode == "polling" {
+		return "🔄"
+	}
+	return "⏸️"
+}
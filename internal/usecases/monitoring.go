@@ -3,43 +3,637 @@ package usecases
 import (
 	"context"
 	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
 	"github.com/iwtcode/fanucClient/internal/interfaces"
 )
 
+const (
+	// Сколько последних записей каждой партиции просматривается при поиске истории по ключу
+	historyScanDepth = 1000
+
+	// Сколько записей каждой партиции читается в обе стороны от offset, найденного по времени
+	seekWindow    = 20
+	seekKeyWindow = 500
+
+	// Размер порции чтения: не больше лимита KafkaReader.ReadRange
+	readChunkSize = 5000
+
+	// Окно расчета скорости записи в статистике топика
+	statsWindow = 5 * time.Minute
+
+	// Буфер событий для проверки правил оповещений
+	eventsBuffer = 1024
+)
+
+// cacheKey - пара (target, key); KeyID == 0 означает "без ключа"
+type cacheKey struct {
+	TargetID uint
+	KeyID    uint
+}
+
 type monitoringUsecase struct {
 	repo     interfaces.UserRepository
 	kafkaSvc interfaces.KafkaReader
+
+	mu sync.RWMutex
+	// Targets grouped by source ID, rebuilt by ReloadSources
+	targets map[string][]entities.MonitoringTarget
+	// Compiled key filters by key ID and their definitions, rebuilt by ReloadSources
+	filters    map[uint]*messageFilter
+	filterDefs map[uint]string
+	// Latest message per (target, key), filled by the background consumer
+	latest map[cacheKey]models.KafkaMessage
+
+	// Live Mode subscribers per (target, key)
+	subscribers map[cacheKey]map[chan models.KafkaMessage]struct{}
+	// Notifies the consumer about a target it does not read yet
+	sourcesChanged chan struct{}
+	// Accepted messages for the alert evaluator
+	events chan models.TelemetryEvent
 }
 
 func NewMonitoringUsecase(repo interfaces.UserRepository, kafkaSvc interfaces.KafkaReader) interfaces.MonitoringUsecase {
 	return &monitoringUsecase{
 		repo:     repo,
 		kafkaSvc: kafkaSvc,
+		targets:  make(map[string][]entities.MonitoringTarget),
+		latest:   make(map[cacheKey]models.KafkaMessage),
+
+		filters:    make(map[uint]*messageFilter),
+		filterDefs: make(map[uint]string),
+
+		subscribers:    make(map[cacheKey]map[chan models.KafkaMessage]struct{}),
+		sourcesChanged: make(chan struct{}, 1),
+		events:         make(chan models.TelemetryEvent, eventsBuffer),
 	}
 }
 
-func (u *monitoringUsecase) FetchLastKafkaMessage(ctx context.Context, targetID uint, keyID uint) (string, string, error) {
+func (u *monitoringUsecase) FetchLastKafkaMessage(ctx context.Context, targetID uint, keyID uint) (models.KafkaMessage, error) {
+	// Answer from the consumer cache if the message was already seen
+	u.mu.RLock()
+	cached, ok := u.latest[cacheKey{TargetID: targetID, KeyID: keyID}]
+	u.mu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	target, key, err := u.resolveTargetKey(targetID, keyID)
+	if err != nil {
+		return models.KafkaMessage{}, err
+	}
+	filter, err := compileFilter(key)
+	if err != nil {
+		return models.KafkaMessage{}, err
+	}
+
+	// Predicate needs the message body: look through the recent history
+	if filter.predicate != nil {
+		history, err := u.FetchHistory(ctx, targetID, keyID, 1)
+		if err != nil {
+			return models.KafkaMessage{}, err
+		}
+		if len(history) == 0 {
+			return models.KafkaMessage{Value: fmt.Sprintf("⚠️ Нет сообщений, удовлетворяющих условию: %s", filter.predicate.raw)}, nil
+		}
+		return history[0], nil
+	}
+
+	// Empty filter if keyID == 0 (default/no key)
+	msg, err := u.kafkaSvc.GetLastMessage(ctx, targetSource(target), keyFilter(key))
+	if err != nil {
+		return models.KafkaMessage{}, fmt.Errorf("kafka error: %w", err)
+	}
+
+	return filter.apply(msg), nil
+}
+
+func (u *monitoringUsecase) GetClusterInfo(ctx context.Context, targetID uint) (*models.ClusterInfo, error) {
+	target, err := u.repo.GetTargetByID(targetID)
+	if err != nil {
+		return nil, fmt.Errorf("target not found: %w", err)
+	}
+
+	info, err := u.kafkaSvc.GetClusterInfo(ctx, targetSource(target))
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: %w", err)
+	}
+	return info, nil
+}
+
+func (u *monitoringUsecase) GetTopicStats(ctx context.Context, targetID uint) (*models.TopicStats, error) {
 	target, err := u.repo.GetTargetByID(targetID)
 	if err != nil {
-		return "", "", fmt.Errorf("target not found: %w", err)
+		return nil, fmt.Errorf("target not found: %w", err)
 	}
+	src := targetSource(target)
+
+	partitions, err := u.kafkaSvc.GetPartitionStats(ctx, src, time.Now().Add(-statsWindow))
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: %w", err)
+	}
+
+	stats := &models.TopicStats{Partitions: partitions, Window: statsWindow}
+	var recent int64
+	for _, p := range partitions {
+		stats.Total += p.Last - p.First
+		recent += p.RecentCount
+		if p.NewestTime.After(stats.NewestTime) {
+			stats.NewestTime = p.NewestTime
+		}
+	}
+	stats.PerMinute = float64(recent) / statsWindow.Minutes()
+
+	if target.ConsumerGroup != "" {
+		stats.Group = target.ConsumerGroup
+		committed, err := u.kafkaSvc.GetCommittedOffsets(ctx, src, target.ConsumerGroup)
+		if err != nil {
+			stats.GroupError = err.Error()
+		} else {
+			stats.Lag = make(map[int]int64)
+			for _, p := range partitions {
+				offset, ok := committed[p.Partition]
+				if !ok {
+					continue
+				}
+				lag := max(p.Last-offset, 0)
+				stats.Lag[p.Partition] = lag
+				stats.TotalLag += lag
+			}
+		}
+	}
+
+	return stats, nil
+}
 
-	var keyString string
-	// If keyID is provided (> 0), fetch the actual key string
+func (u *monitoringUsecase) FetchHistory(ctx context.Context, targetID uint, keyID uint, limit int) ([]models.KafkaMessage, error) {
+	target, key, err := u.resolveTargetKey(targetID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	src := targetSource(target)
+	filter, err := compileFilter(key)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets, err := u.kafkaSvc.GetPartitionOffsets(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: %w", err)
+	}
+
+	// Without a key the newest `limit` records of each partition are enough
+	depth := int64(limit)
+	if key != nil && depth < historyScanDepth {
+		depth = historyScanDepth
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		messages []models.KafkaMessage
+		firstErr error
+	)
+	for _, po := range offsets {
+		if po.Last <= po.First {
+			continue
+		}
+		start := po.Last - depth
+		if start < po.First {
+			start = po.First
+		}
+
+		wg.Add(1)
+		go func(po models.PartitionOffsets, start int64) {
+			defer wg.Done()
+			batch, err := u.readRange(ctx, src, po.Partition, start, po.Last)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for _, m := range batch {
+				if filter.accept(m) {
+					messages = append(messages, filter.apply(m))
+				}
+			}
+		}(po, start)
+	}
+	wg.Wait()
+
+	if len(messages) == 0 && firstErr != nil {
+		return nil, fmt.Errorf("kafka error: %w", firstErr)
+	}
+
+	sortNewestFirst(messages)
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+func (u *monitoringUsecase) LastMessageTime(ctx context.Context, targetID uint, keyID uint) (time.Time, error) {
 	if keyID > 0 {
-		k, err := u.repo.GetKeyByID(keyID)
+		// Keyed messages are only known by reading them: consumer cache or a scan of the partition tails
+		msg, err := u.FetchLastKafkaMessage(ctx, targetID, keyID)
 		if err != nil {
-			return "", "", fmt.Errorf("key not found: %w", err)
+			return time.Time{}, err
+		}
+		return msg.Time, nil
+	}
+
+	target, err := u.repo.GetTargetByID(targetID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("target not found: %w", err)
+	}
+	// Newest record of every partition, regardless of the consumer
+	partitions, err := u.kafkaSvc.GetPartitionStats(ctx, targetSource(target), time.Now())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("kafka error: %w", err)
+	}
+	var newest time.Time
+	for _, p := range partitions {
+		if p.NewestTime.After(newest) {
+			newest = p.NewestTime
+		}
+	}
+	return newest, nil
+}
+
+// --- Seek by Timestamp ---
+
+func (u *monitoringUsecase) FetchMessageAt(ctx context.Context, targetID uint, keyID uint, at time.Time) (*models.KafkaMessage, error) {
+	candidates, err := u.collectAround(ctx, targetID, keyID, at)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *models.KafkaMessage
+	var bestDiff time.Duration
+	for i := range candidates {
+		diff := candidates[i].Time.Sub(at)
+		if diff < 0 {
+			diff = -diff
+		}
+		if best == nil || diff < bestDiff {
+			best = &candidates[i]
+			bestDiff = diff
+		}
+	}
+	return best, nil
+}
+
+func (u *monitoringUsecase) FetchNeighbour(ctx context.Context, targetID uint, keyID uint, pos models.MessagePosition, newer bool) (*models.KafkaMessage, error) {
+	candidates, err := u.collectAround(ctx, targetID, keyID, pos.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *models.KafkaMessage
+	for i := range candidates {
+		cur := candidates[i].Position()
+		if newer {
+			// Oldest among the newer ones
+			if pos.Before(cur) && (best == nil || cur.Before(best.Position())) {
+				best = &candidates[i]
+			}
+		} else {
+			// Newest among the older ones
+			if cur.Before(pos) && (best == nil || best.Position().Before(cur)) {
+				best = &candidates[i]
+			}
 		}
-		keyString = k.Key
 	}
+	return best, nil
+}
 
-	// Use empty string for keyString if keyID == 0 (default/no key)
-	foundKey, foundVal, err := u.kafkaSvc.GetLastMessage(ctx, target.Broker, target.Topic, keyString)
+// collectAround reads records of every partition around the offset resolved for the time
+func (u *monitoringUsecase) collectAround(ctx context.Context, targetID uint, keyID uint, at time.Time) ([]models.KafkaMessage, error) {
+	target, key, err := u.resolveTargetKey(targetID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	src := targetSource(target)
+	filter, err := compileFilter(key)
 	if err != nil {
-		return "", "", fmt.Errorf("kafka error: %w", err)
+		return nil, err
 	}
 
-	return foundKey, foundVal, nil
+	offsets, err := u.kafkaSvc.GetOffsetsForTime(ctx, src, at)
+	if err != nil {
+		return nil, fmt.Errorf("kafka error: %w", err)
+	}
+
+	window := int64(seekWindow)
+	if key != nil {
+		window = seekKeyWindow
+	}
+
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		candidates []models.KafkaMessage
+		firstErr   error
+	)
+	for partition, offset := range offsets {
+		wg.Add(1)
+		go func(partition int, offset int64) {
+			defer wg.Done()
+			batch, err := u.kafkaSvc.ReadRange(ctx, src, partition, offset-window, offset+window)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for _, m := range batch {
+				if filter.accept(m) {
+					candidates = append(candidates, filter.apply(m))
+				}
+			}
+		}(partition, offset)
+	}
+	wg.Wait()
+
+	if len(candidates) == 0 && firstErr != nil {
+		return nil, fmt.Errorf("kafka error: %w", firstErr)
+	}
+	return candidates, nil
+}
+
+// readRange reads [start, end) of the partition in chunks accepted by KafkaReader.ReadRange
+func (u *monitoringUsecase) readRange(ctx context.Context, src models.KafkaSource, partition int, start, end int64) ([]models.KafkaMessage, error) {
+	var messages []models.KafkaMessage
+	for start < end {
+		chunkEnd := min(start+readChunkSize, end)
+		batch, err := u.kafkaSvc.ReadRange(ctx, src, partition, start, chunkEnd)
+		if err != nil {
+			if len(messages) > 0 {
+				return messages, nil
+			}
+			return nil, err
+		}
+		messages = append(messages, batch...)
+		start = chunkEnd
+	}
+	return messages, nil
+}
+
+// resolveTargetKey loads the target and the key (nil for keyID == 0)
+func (u *monitoringUsecase) resolveTargetKey(targetID uint, keyID uint) (*entities.MonitoringTarget, *entities.MonitoringKey, error) {
+	target, err := u.repo.GetTargetByID(targetID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("target not found: %w", err)
+	}
+	if keyID == 0 {
+		return target, nil, nil
+	}
+	key, err := u.repo.GetKeyByID(keyID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("key not found: %w", err)
+	}
+	return target, key, nil
+}
+
+// sortNewestFirst orders messages from different partitions by timestamp
+func sortNewestFirst(messages []models.KafkaMessage) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		if !messages[i].Time.Equal(messages[j].Time) {
+			return messages[i].Time.After(messages[j].Time)
+		}
+		return messages[i].Offset > messages[j].Offset
+	})
+}
+
+// --- Background Consumer ---
+
+func (u *monitoringUsecase) ReloadSources() ([]models.KafkaSource, error) {
+	targets, err := u.repo.GetAllTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string][]entities.MonitoringTarget)
+	filters := make(map[uint]*messageFilter)
+	filterDefs := make(map[uint]string)
+	var sources []models.KafkaSource
+	for _, t := range targets {
+		for _, k := range t.Keys {
+			filter, err := compileFilter(&k)
+			if err != nil {
+				log.Printf("⚠️ Ключ %d пропущен: %v", k.ID, err)
+				continue
+			}
+			filters[k.ID] = filter
+			filterDefs[k.ID] = filterDefinition(&k)
+		}
+
+		src := targetSource(&t)
+		if len(src.Brokers) == 0 || src.Topic == "" {
+			continue
+		}
+		if _, ok := index[src.ID()]; !ok {
+			sources = append(sources, src)
+		}
+		index[src.ID()] = append(index[src.ID()], t)
+	}
+
+	u.mu.Lock()
+	u.targets = index
+	// Drop cache entries of deleted targets and keys, and of keys whose filters were edited
+	for ck := range u.latest {
+		if !indexHas(index, ck) || (ck.KeyID > 0 && filterDefs[ck.KeyID] != u.filterDefs[ck.KeyID]) {
+			delete(u.latest, ck)
+		}
+	}
+	u.filters = filters
+	u.filterDefs = filterDefs
+	u.mu.Unlock()
+
+	return sources, nil
+}
+
+func (u *monitoringUsecase) HandleMessage(src models.KafkaSource, msg models.KafkaMessage) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, t := range u.targets[src.ID()] {
+		// Default entry point receives every message of the topic
+		u.storeLatest(cacheKey{TargetID: t.ID}, msg)
+		u.emit(models.TelemetryEvent{TargetID: t.ID, Message: msg})
+
+		for _, k := range t.Keys {
+			if filter := u.filters[k.ID]; filter != nil && filter.accept(msg) {
+				// Rules look at the whole value, the projection is only for display
+				u.storeLatest(cacheKey{TargetID: t.ID, KeyID: k.ID}, filter.apply(msg))
+				u.emit(models.TelemetryEvent{TargetID: t.ID, KeyID: k.ID, Message: msg})
+			}
+		}
+	}
+}
+
+func (u *monitoringUsecase) SourcesChanged() <-chan struct{} {
+	return u.sourcesChanged
+}
+
+func (u *monitoringUsecase) Events() <-chan models.TelemetryEvent {
+	return u.events
+}
+
+// emit never blocks the consumer: events are dropped if the evaluator falls behind
+func (u *monitoringUsecase) emit(ev models.TelemetryEvent) {
+	select {
+	case u.events <- ev:
+	default:
+		log.Printf("⚠️ Очередь проверки правил переполнена, сообщение %d/%d пропущено", ev.Message.Partition, ev.Message.Offset)
+	}
+}
+
+// storeLatest keeps the newest message by timestamp (partitions are consumed independently)
+// and pushes it to subscribers of the (target, key)
+func (u *monitoringUsecase) storeLatest(ck cacheKey, msg models.KafkaMessage) {
+	if prev, ok := u.latest[ck]; ok && prev.Time.After(msg.Time) {
+		return
+	}
+	u.latest[ck] = msg
+
+	for ch := range u.subscribers[ck] {
+		// Subscriber is only interested in the newest message: replace the unread one
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- msg
+	}
+}
+
+// --- Live Mode ---
+
+func (u *monitoringUsecase) Subscribe(targetID uint, keyID uint) (<-chan models.KafkaMessage, func()) {
+	ck := cacheKey{TargetID: targetID, KeyID: keyID}
+	ch := make(chan models.KafkaMessage, 1)
+
+	u.mu.Lock()
+	if u.subscribers[ck] == nil {
+		u.subscribers[ck] = make(map[chan models.KafkaMessage]struct{})
+	}
+	u.subscribers[ck][ch] = struct{}{}
+	known := indexHas(u.targets, ck)
+	u.mu.Unlock()
+
+	// Target or key was added after the last sync: ask the consumer to reload now
+	if !known {
+		select {
+		case u.sourcesChanged <- struct{}{}:
+		default:
+		}
+	}
+
+	unsubscribe := func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		delete(u.subscribers[ck], ch)
+		if len(u.subscribers[ck]) == 0 {
+			delete(u.subscribers, ck)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// keyFilter converts the stored key into a filter (no filter for nil / default key)
+func keyFilter(k *entities.MonitoringKey) models.KeyFilter {
+	if k == nil {
+		return models.KeyFilter{}
+	}
+	return models.KeyFilter{Mode: k.MatchMode, Pattern: k.Key, Header: k.Header}
+}
+
+// messageFilter - скомпилированные фильтры ключа: шаблон ключа, условие по значению и проекция полей
+type messageFilter struct {
+	matchKey   func(models.KafkaMessage) bool
+	predicate  *predicate
+	projection []jsonPath
+}
+
+// compileFilter prepares filters of the key (nil key = default entry point, accepts everything)
+func compileFilter(k *entities.MonitoringKey) (*messageFilter, error) {
+	match, err := keyFilter(k).Compile()
+	if err != nil {
+		return nil, err
+	}
+	filter := &messageFilter{matchKey: match}
+	if k == nil {
+		return filter, nil
+	}
+
+	if filter.predicate, err = parsePredicate(k.Predicate); err != nil {
+		return nil, err
+	}
+	if filter.projection, err = parseProjection(k.Projection); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func (f *messageFilter) accept(m models.KafkaMessage) bool {
+	return f.matchKey(m) && f.predicate.match(m.Value)
+}
+
+// apply keeps only the projected fields of the value
+func (f *messageFilter) apply(m models.KafkaMessage) models.KafkaMessage {
+	m.Value = project(f.projection, m.Value)
+	return m
+}
+
+// filterDefinition identifies the filter settings of the key to detect edits
+func filterDefinition(k *entities.MonitoringKey) string {
+	return strings.Join([]string{k.MatchMode, k.Key, k.Header, k.Predicate, k.Projection}, "\x00")
+}
+
+// targetSource converts the stored target into connection parameters for KafkaReader
+func targetSource(t *entities.MonitoringTarget) models.KafkaSource {
+	return models.KafkaSource{
+		Brokers: models.ParseBrokers(t.Broker),
+		Topic:   t.Topic,
+		Auth: models.KafkaAuth{
+			Mechanism:          t.SASLMechanism,
+			Username:           t.SASLUsername,
+			Password:           t.SASLPassword,
+			TLS:                t.TLSEnabled,
+			CACert:             t.TLSCACert,
+			InsecureSkipVerify: t.TLSInsecure,
+		},
+	}
+}
+
+func indexHas(index map[string][]entities.MonitoringTarget, ck cacheKey) bool {
+	for _, targets := range index {
+		for _, t := range targets {
+			if t.ID != ck.TargetID {
+				continue
+			}
+			if ck.KeyID == 0 {
+				return true
+			}
+			for _, k := range t.Keys {
+				if k.ID == ck.KeyID {
+					return true
+				}
+			}
+		}
+	}
+	return false
 }
@@ -0,0 +1,388 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	// Часовые пояса пользователей не зависят от tzdata в образе
+	_ "time/tzdata"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+)
+
+const (
+	// Границы заглушения станка
+	minMachineMute = time.Minute
+	maxMachineMute = 30 * 24 * time.Hour
+
+	// Допустимые интервалы сводки
+	minDigestInterval = 15 * time.Minute
+	maxDigestInterval = 24 * time.Hour
+
+	// Размер одного сообщения сводки (лимит Telegram 4096 символов)
+	maxDigestText = 3500
+	// Кнопок под одним сообщением сводки (лимит Telegram 100)
+	maxDigestButtons = 60
+)
+
+type notificationUsecase struct {
+	repo   interfaces.UserRepository
+	sender interfaces.NotificationSender
+}
+
+func NewNotificationUsecase(repo interfaces.UserRepository, sender interfaces.NotificationSender) interfaces.NotificationUsecase {
+	return &notificationUsecase{
+		repo:   repo,
+		sender: sender,
+	}
+}
+
+// --- Notification Path ---
+
+func (u *notificationUsecase) Notify(ctx context.Context, n models.Notification) error {
+	now := time.Now()
+
+	if n.MachineID != "" {
+		muted, err := u.IsMuted(n.ChatID, n.MachineID)
+		if err != nil {
+			return err
+		}
+		if muted {
+			return nil
+		}
+	}
+
+	settings, err := u.GetSettings(n.ChatID)
+	if err != nil {
+		return err
+	}
+	if models.SeverityLevel(n.Severity) < models.SeverityLevel(settings.MinSeverity) {
+		return nil
+	}
+
+	// Critical notifications wake up even at night
+	if n.Severity != models.SeverityCritical && (settings.DigestMinutes > 0 || inQuietHours(settings, now)) {
+		held := &entities.HeldNotification{
+			UserID:   n.ChatID,
+			Severity: n.Severity,
+			Text:     n.Text,
+			DedupKey: n.DedupKey,
+		}
+		if len(n.Buttons) > 0 {
+			buttons, err := json.Marshal(n.Buttons)
+			if err != nil {
+				return err
+			}
+			held.Buttons = string(buttons)
+		}
+		return u.repo.AddHeldNotification(held)
+	}
+	return u.sender.Send(ctx, n)
+}
+
+func (u *notificationUsecase) FlushHeld(ctx context.Context, now time.Time) {
+	if err := u.repo.DeleteExpiredMachineMutes(now); err != nil {
+		log.Printf("⚠️ Не удалось удалить истекшие заглушения: %v", err)
+	}
+
+	held, err := u.repo.GetHeldNotifications()
+	if err != nil {
+		log.Printf("⚠️ Не удалось загрузить отложенные уведомления: %v", err)
+		return
+	}
+
+	byUser := make(map[int64][]entities.HeldNotification)
+	var users []int64
+	for _, h := range held {
+		if _, ok := byUser[h.UserID]; !ok {
+			users = append(users, h.UserID)
+		}
+		byUser[h.UserID] = append(byUser[h.UserID], h)
+	}
+
+	for _, userID := range users {
+		settings, err := u.GetSettings(userID)
+		if err != nil {
+			log.Printf("⚠️ Не удалось загрузить настройки уведомлений %d: %v", userID, err)
+			continue
+		}
+		if inQuietHours(settings, now) {
+			continue
+		}
+		digestEvery := time.Duration(settings.DigestMinutes) * time.Minute
+		if digestEvery > 0 && now.Sub(settings.LastDigestAt) < digestEvery {
+			continue
+		}
+
+		items := byUser[userID]
+		for _, msg := range buildDigest(items, userLocation(settings)) {
+			msg.ChatID = userID
+			if err := u.sender.Send(ctx, msg); err != nil {
+				log.Printf("⚠️ Не удалось отправить сводку в чат %d: %v", userID, err)
+			}
+		}
+
+		ids := make([]uint, 0, len(items))
+		for _, h := range items {
+			ids = append(ids, h.ID)
+		}
+		if err := u.repo.DeleteHeldNotifications(ids); err != nil {
+			log.Printf("⚠️ Не удалось удалить отправленные уведомления %d: %v", userID, err)
+		}
+		if err := u.repo.UpdateNotificationSettings(userID, map[string]interface{}{"last_digest_at": now}); err != nil {
+			log.Printf("⚠️ Не удалось сохранить время сводки %d: %v", userID, err)
+		}
+	}
+}
+
+type digestBlock struct {
+	text    string
+	buttons []models.NotificationButton
+}
+
+// buildDigest joins held notifications into messages that fit the Telegram limits.
+// Buttons of a held notification are kept in its own row, labelled with the number of its block.
+func buildDigest(items []entities.HeldNotification, loc *time.Location) []models.Notification {
+	seen := make(map[string]bool)
+	var blocks []digestBlock
+	for _, h := range items {
+		if h.DedupKey != "" {
+			if seen[h.DedupKey] {
+				continue
+			}
+			seen[h.DedupKey] = true
+		}
+
+		header := "🕒 <b>" + h.CreatedAt.In(loc).Format("01-02 15:04") + "</b>"
+		var buttons []models.NotificationButton
+		if h.Buttons != "" {
+			if err := json.Unmarshal([]byte(h.Buttons), &buttons); err != nil {
+				log.Printf("⚠️ Некорректные кнопки отложенного уведомления %d: %v", h.ID, err)
+			}
+		}
+		num := len(blocks) + 1
+		if len(buttons) > 0 {
+			header += fmt.Sprintf(" · #%d", num)
+		}
+		for i := range buttons {
+			buttons[i].Text = fmt.Sprintf("#%d %s", num, buttons[i].Text)
+			buttons[i].Data += models.DigestCallbackSuffix
+			buttons[i].Row = num
+		}
+		blocks = append(blocks, digestBlock{text: header + "\n" + h.Text, buttons: buttons})
+	}
+
+	var messages []models.Notification
+	current := models.Notification{Text: fmt.Sprintf("🗂 <b>Сводка уведомлений (%d)</b>", len(blocks))}
+	for _, block := range blocks {
+		if current.Text != "" && (len(current.Text)+len(block.text) > maxDigestText || len(current.Buttons)+len(block.buttons) > maxDigestButtons) {
+			messages = append(messages, current)
+			current = models.Notification{}
+		}
+		if current.Text != "" {
+			current.Text += "\n\n"
+		}
+		current.Text += block.text
+		current.Buttons = append(current.Buttons, block.buttons...)
+	}
+	if current.Text != "" {
+		messages = append(messages, current)
+	}
+	return messages
+}
+
+// --- Preferences ---
+
+func (u *notificationUsecase) GetSettings(userID int64) (*entities.NotificationSettings, error) {
+	settings, err := u.repo.GetNotificationSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = &entities.NotificationSettings{UserID: userID, MinSeverity: models.SeverityInfo}
+	}
+	return settings, nil
+}
+
+func (u *notificationUsecase) SetTimeZone(userID int64, name string) error {
+	if _, err := parseTimeZone(name); err != nil {
+		return err
+	}
+	return u.update(userID, func(s *entities.NotificationSettings) { s.TimeZone = strings.TrimSpace(name) })
+}
+
+func (u *notificationUsecase) SetQuietHours(userID int64, expr string) error {
+	from, to, err := parseQuietHours(expr)
+	if err != nil {
+		return err
+	}
+	return u.update(userID, func(s *entities.NotificationSettings) { s.QuietFrom, s.QuietTo = from, to })
+}
+
+func (u *notificationUsecase) SetMinSeverity(userID int64, severity string) error {
+	if models.Severities[models.SeverityLevel(severity)] != severity {
+		return fmt.Errorf("неизвестная важность '%s'", severity)
+	}
+	return u.update(userID, func(s *entities.NotificationSettings) { s.MinSeverity = severity })
+}
+
+func (u *notificationUsecase) SetDigestInterval(userID int64, interval time.Duration) error {
+	if interval != 0 && (interval < minDigestInterval || interval > maxDigestInterval) {
+		return fmt.Errorf("допустимый интервал сводки: от %s до %s", models.HumanDuration(minDigestInterval), models.HumanDuration(maxDigestInterval))
+	}
+	return u.update(userID, func(s *entities.NotificationSettings) { s.DigestMinutes = int(interval / time.Minute) })
+}
+
+func (u *notificationUsecase) update(userID int64, apply func(s *entities.NotificationSettings)) error {
+	settings, err := u.GetSettings(userID)
+	if err != nil {
+		return err
+	}
+	apply(settings)
+	return u.repo.SaveNotificationSettings(settings)
+}
+
+// --- Machine Mutes ---
+
+func (u *notificationUsecase) MuteMachine(userID int64, machineID string, d time.Duration) error {
+	machineID = strings.TrimSpace(machineID)
+	if machineID == "" {
+		return fmt.Errorf("не указан ID станка")
+	}
+	if d < minMachineMute || d > maxMachineMute {
+		return fmt.Errorf("допустимое время: от %s до %s", models.HumanDuration(minMachineMute), models.HumanDuration(maxMachineMute))
+	}
+	return u.repo.SaveMachineMute(&entities.MachineMute{
+		UserID:    userID,
+		MachineID: machineID,
+		Until:     time.Now().Add(d),
+	})
+}
+
+func (u *notificationUsecase) UnmuteMachine(userID int64, machineID string) error {
+	return u.repo.DeleteMachineMute(userID, machineID)
+}
+
+func (u *notificationUsecase) GetMutes(userID int64) ([]entities.MachineMute, error) {
+	return u.repo.GetActiveMachineMutes(userID, time.Now())
+}
+
+func (u *notificationUsecase) IsMuted(userID int64, machineID string) (bool, error) {
+	mutes, err := u.GetMutes(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range mutes {
+		if m.MachineID == machineID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// --- Time Zone & Quiet Hours ---
+
+var utcOffset = regexp.MustCompile(`^(?i:UTC|GMT)?\s*([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+// parseTimeZone accepts an IANA name (Europe/Moscow) or an offset (UTC+3, +05:30); empty = UTC
+func parseTimeZone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.UTC, nil
+	}
+	if m := utcOffset.FindStringSubmatch(name); m != nil {
+		hours, _ := strconv.Atoi(m[2])
+		minutes, _ := strconv.Atoi(m[3])
+		if hours > 14 || minutes > 59 {
+			return nil, fmt.Errorf("некорректное смещение '%s'", name)
+		}
+		offset := (hours*60 + minutes) * 60
+		if m[1] == "-" {
+			offset = -offset
+		}
+		zoneName := "UTC" + m[1] + m[2]
+		if m[3] != "" {
+			zoneName += ":" + m[3]
+		}
+		return time.FixedZone(zoneName, offset), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс '%s' (примеры: Europe/Moscow, UTC+3)", name)
+	}
+	return loc, nil
+}
+
+// userLocation returns the user's time zone, UTC if it is not set or broken
+func userLocation(s *entities.NotificationSettings) *time.Location {
+	loc, err := parseTimeZone(s.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// parseQuietHours parses "22:00-07:00" (or "22-7") into normalized bounds; "-" disables quiet hours
+func parseQuietHours(expr string) (string, string, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "-" {
+		return "", "", nil
+	}
+	bounds := strings.Split(expr, "-")
+	if len(bounds) != 2 {
+		return "", "", fmt.Errorf("укажите начало и конец через \"-\"")
+	}
+	from, errFrom := parseClock(bounds[0])
+	to, errTo := parseClock(bounds[1])
+	if errFrom != nil || errTo != nil {
+		return "", "", fmt.Errorf("некорректное время")
+	}
+	if from == to {
+		return "", "", fmt.Errorf("начало и конец тихих часов совпадают")
+	}
+	return formatClock(from), formatClock(to), nil
+}
+
+// parseClock converts "7", "07:30" into minutes since midnight
+func parseClock(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	layout := "15:04"
+	if !strings.Contains(s, ":") {
+		layout = "15"
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// inQuietHours reports whether now falls into the user's quiet hours (may cross midnight)
+func inQuietHours(s *entities.NotificationSettings, now time.Time) bool {
+	if s.QuietFrom == "" || s.QuietTo == "" {
+		return false
+	}
+	from, errFrom := parseClock(s.QuietFrom)
+	to, errTo := parseClock(s.QuietTo)
+	if errFrom != nil || errTo != nil {
+		return false
+	}
+
+	local := now.In(userLocation(s))
+	m := local.Hour()*60 + local.Minute()
+	if from < to {
+		return m >= from && m < to
+	}
+	return m >= from || m < to
+}
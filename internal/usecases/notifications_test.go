@@ -0,0 +1,177 @@
+package usecases
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		expr     string
+		from, to string
+		wantErr  bool
+	}{
+		{expr: "22:00-07:00", from: "22:00", to: "07:00"},
+		{expr: " 22-7 ", from: "22:00", to: "07:00"},
+		{expr: "9:30 - 18:00", from: "09:30", to: "18:00"},
+		{expr: "-"},
+		{expr: "22:00", wantErr: true},
+		{expr: "22:00-07:00-08:00", wantErr: true},
+		{expr: "25:00-07:00", wantErr: true},
+		{expr: "night-day", wantErr: true},
+		{expr: "07:00-7", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			from, to, err := parseQuietHours(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseQuietHours(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if from != tt.from || to != tt.to {
+				t.Errorf("parseQuietHours(%q) = %q, %q; want %q, %q", tt.expr, from, to, tt.from, tt.to)
+			}
+			if err != nil && strings.ContainsAny(err.Error(), "<>") {
+				t.Errorf("error should be plain text: %q", err)
+			}
+		})
+	}
+}
+
+func TestParseTimeZone(t *testing.T) {
+	tests := []struct {
+		name    string
+		offset  int // seconds east of UTC in January
+		wantErr bool
+	}{
+		{name: "", offset: 0},
+		{name: "Europe/Moscow", offset: 3 * 3600},
+		{name: "UTC+3", offset: 3 * 3600},
+		{name: "gmt-5", offset: -5 * 3600},
+		{name: "+05:30", offset: 5*3600 + 30*60},
+		{name: "UTC+0530", offset: 5*3600 + 30*60},
+		{name: "UTC+15", wantErr: true},
+		{name: "+03:75", wantErr: true},
+		{name: "Mars/Olympus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := parseTimeZone(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimeZone(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			_, offset := time.Date(2026, 1, 1, 0, 0, 0, 0, loc).Zone()
+			if offset != tt.offset {
+				t.Errorf("parseTimeZone(%q) offset = %d, want %d", tt.name, offset, tt.offset)
+			}
+		})
+	}
+}
+
+func TestInQuietHours(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	night := &entities.NotificationSettings{QuietFrom: "22:00", QuietTo: "07:00"}
+	day := &entities.NotificationSettings{QuietFrom: "09:00", QuietTo: "18:00"}
+	moscow := &entities.NotificationSettings{QuietFrom: "22:00", QuietTo: "07:00", TimeZone: "UTC+3"}
+
+	tests := []struct {
+		name     string
+		settings *entities.NotificationSettings
+		now      time.Time
+		want     bool
+	}{
+		{"off", &entities.NotificationSettings{}, at(23, 0), false},
+		{"crossing midnight, late evening", night, at(23, 30), true},
+		{"crossing midnight, early morning", night, at(6, 59), true},
+		{"crossing midnight, end is exclusive", night, at(7, 0), false},
+		{"crossing midnight, start is inclusive", night, at(22, 0), true},
+		{"crossing midnight, daytime", night, at(12, 0), false},
+		{"same day", day, at(12, 0), true},
+		{"same day, evening", day, at(19, 0), false},
+		{"user time zone", moscow, at(19, 30), true},
+		{"user time zone, morning", moscow, at(4, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inQuietHours(tt.settings, tt.now); got != tt.want {
+				t.Errorf("inQuietHours() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	created := time.Date(2026, 1, 1, 3, 15, 0, 0, time.UTC)
+	items := []entities.HeldNotification{
+		{ID: 1, Text: "watchdog", CreatedAt: created},
+		{ID: 2, Text: "alert", DedupKey: "rule:1", CreatedAt: created,
+			Buttons: `[{"Text":"👀 Принять","Data":"alert_ack:7"},{"Text":"✅ Решено","Data":"alert_res:7"}]`},
+		{ID: 3, Text: "alert again", DedupKey: "rule:1", CreatedAt: created},
+		{ID: 4, Text: "broken buttons", CreatedAt: created, Buttons: "{"},
+	}
+
+	messages := buildDigest(items, time.UTC)
+	if len(messages) != 1 {
+		t.Fatalf("buildDigest() = %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if !strings.HasPrefix(msg.Text, "🗂 <b>Сводка уведомлений (3)</b>") {
+		t.Errorf("unexpected header: %q", msg.Text)
+	}
+	if strings.Contains(msg.Text, "alert again") {
+		t.Error("duplicate notification should be dropped")
+	}
+	if !strings.Contains(msg.Text, "03:15</b> · #2\nalert") {
+		t.Errorf("block with buttons should be numbered: %q", msg.Text)
+	}
+
+	want := []models.NotificationButton{
+		{Text: "#2 👀 Принять", Data: "alert_ack:7" + models.DigestCallbackSuffix, Row: 2},
+		{Text: "#2 ✅ Решено", Data: "alert_res:7" + models.DigestCallbackSuffix, Row: 2},
+	}
+	if len(msg.Buttons) != len(want) {
+		t.Fatalf("buttons = %v, want %v", msg.Buttons, want)
+	}
+	for i := range want {
+		if msg.Buttons[i] != want[i] {
+			t.Errorf("button %d = %v, want %v", i, msg.Buttons[i], want[i])
+		}
+	}
+}
+
+func TestBuildDigestSplitsMessages(t *testing.T) {
+	var items []entities.HeldNotification
+	for i := 0; i < 40; i++ {
+		items = append(items, entities.HeldNotification{
+			ID:      uint(i + 1),
+			Text:    strings.Repeat("x", 50),
+			Buttons: `[{"Text":"a","Data":"alert_ack:1"},{"Text":"b","Data":"alert_res:1"}]`,
+		})
+	}
+
+	messages := buildDigest(items, time.UTC)
+	if len(messages) < 2 {
+		t.Fatalf("buildDigest() = %d messages, want the buttons split", len(messages))
+	}
+	total := 0
+	for _, msg := range messages {
+		if len(msg.Buttons) > maxDigestButtons {
+			t.Errorf("message has %d buttons, limit %d", len(msg.Buttons), maxDigestButtons)
+		}
+		if len(msg.Text) > maxDigestText {
+			t.Errorf("message is %d bytes, limit %d", len(msg.Text), maxDigestText)
+		}
+		total += len(msg.Buttons)
+	}
+	if total != 80 {
+		t.Errorf("buttons in all messages = %d, want 80", total)
+	}
+}
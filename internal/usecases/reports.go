@@ -0,0 +1,450 @@
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+)
+
+const (
+	// Максимум времен отправки отчета в сутки
+	maxReportTimes = 6
+	// Период отчета "сейчас", если расписание не задано
+	defaultReportPeriod = 24 * time.Hour
+	// Размер текста отчета (лимит Telegram 4096 символов)
+	maxReportText = 3800
+)
+
+type reportUsecase struct {
+	repo interfaces.UserRepository
+	// Отчеты отправляются мимо предпочтений (тихие часы, сводка):
+	// время доставки пользователь выбирает сам
+	sender interfaces.NotificationSender
+}
+
+func NewReportUsecase(repo interfaces.UserRepository, sender interfaces.NotificationSender) interfaces.ReportUsecase {
+	return &reportUsecase{
+		repo:   repo,
+		sender: sender,
+	}
+}
+
+// --- Schedule ---
+
+func (u *reportUsecase) SetReportTimes(userID int64, expr string) error {
+	times, err := parseReportTimes(expr)
+	if err != nil {
+		return err
+	}
+	return u.update(userID, map[string]interface{}{
+		"report_times": strings.Join(times, ","),
+		// Only shifts ending after the change are reported
+		"last_report_at": time.Now(),
+	})
+}
+
+func (u *reportUsecase) SetReportCSV(userID int64, enabled bool) error {
+	return u.update(userID, map[string]interface{}{"report_csv": enabled})
+}
+
+// update creates the settings row on first change and applies the updates
+func (u *reportUsecase) update(userID int64, updates map[string]interface{}) error {
+	settings, err := u.repo.GetNotificationSettings(userID)
+	if err != nil {
+		return err
+	}
+	if settings == nil {
+		settings = &entities.NotificationSettings{UserID: userID, MinSeverity: models.SeverityInfo}
+		if err := u.repo.SaveNotificationSettings(settings); err != nil {
+			return err
+		}
+	}
+	return u.repo.UpdateNotificationSettings(userID, updates)
+}
+
+func (u *reportUsecase) CheckSchedules(ctx context.Context, now time.Time) {
+	schedules, err := u.repo.GetReportSchedules()
+	if err != nil {
+		log.Printf("⚠️ Не удалось загрузить расписание отчетов: %v", err)
+		return
+	}
+
+	for _, s := range schedules {
+		from, to, ok := lastShift(&s, now)
+		if !ok || !to.After(s.LastReportAt) {
+			continue
+		}
+		// Remember first: a failing report must not be resent every minute
+		if err := u.repo.UpdateNotificationSettings(s.UserID, map[string]interface{}{"last_report_at": to}); err != nil {
+			log.Printf("⚠️ Не удалось сохранить время отчета %d: %v", s.UserID, err)
+			continue
+		}
+		if err := u.send(ctx, &s, from, to); err != nil {
+			log.Printf("⚠️ Не удалось отправить отчет %d: %v", s.UserID, err)
+		}
+	}
+}
+
+func (u *reportUsecase) SendReportNow(ctx context.Context, userID int64) error {
+	settings, err := u.repo.GetNotificationSettings(userID)
+	if err != nil {
+		return err
+	}
+	if settings == nil {
+		settings = &entities.NotificationSettings{UserID: userID}
+	}
+
+	// Current shift since its start, or the last day without a schedule
+	now := time.Now()
+	from := now.Add(-defaultReportPeriod)
+	if _, shiftStart, ok := lastShift(settings, now); ok {
+		from = shiftStart
+	}
+	return u.send(ctx, settings, from, now)
+}
+
+func (u *reportUsecase) send(ctx context.Context, settings *entities.NotificationSettings, from, to time.Time) error {
+	report, err := u.BuildReport(settings.UserID, from, to)
+	if err != nil {
+		return err
+	}
+
+	loc := userLocation(settings)
+	if err := u.sender.Send(ctx, models.Notification{ChatID: settings.UserID, Text: formatReport(report, loc)}); err != nil {
+		return err
+	}
+	if !settings.ReportCSV || len(report.Machines) == 0 {
+		return nil
+	}
+
+	file, err := reportCSV(report, loc)
+	if err != nil {
+		return err
+	}
+	return u.sender.Send(ctx, models.Notification{
+		ChatID:   settings.UserID,
+		Text:     "📎 Станки за смену",
+		Document: file,
+	})
+}
+
+// lastShift returns the last finished shift: between the two latest report times not after now
+func lastShift(s *entities.NotificationSettings, now time.Time) (time.Time, time.Time, bool) {
+	times, err := parseReportTimes(s.ReportTimes)
+	if err != nil || len(times) == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+
+	loc := userLocation(s)
+	local := now.In(loc)
+	var moments []time.Time
+	// Two days back are enough for a single report time a day
+	for day := -2; day <= 0; day++ {
+		for _, t := range times {
+			minutes, _ := parseClock(t)
+			at := time.Date(local.Year(), local.Month(), local.Day()+day, minutes/60, minutes%60, 0, 0, loc)
+			if !at.After(now) {
+				moments = append(moments, at)
+			}
+		}
+	}
+	sort.Slice(moments, func(i, j int) bool { return moments[i].Before(moments[j]) })
+	if len(moments) < 2 {
+		return time.Time{}, time.Time{}, false
+	}
+	return moments[len(moments)-2], moments[len(moments)-1], true
+}
+
+// parseReportTimes normalizes "8:00, 20:00" into sorted unique "08:00","20:00"; "-" disables reports
+func parseReportTimes(expr string) ([]string, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" || expr == "-" {
+		return nil, nil
+	}
+
+	seen := make(map[int]bool)
+	var minutes []int
+	for _, f := range strings.FieldsFunc(expr, func(r rune) bool { return r == ',' || r == ' ' || r == ';' }) {
+		m, err := parseClock(f)
+		if err != nil {
+			return nil, fmt.Errorf("некорректное время '%s' (пример: 08:00, 20:00)", f)
+		}
+		if !seen[m] {
+			seen[m] = true
+			minutes = append(minutes, m)
+		}
+	}
+	if len(minutes) > maxReportTimes {
+		return nil, fmt.Errorf("не больше %d отчетов в сутки", maxReportTimes)
+	}
+
+	sort.Ints(minutes)
+	times := make([]string, 0, len(minutes))
+	for _, m := range minutes {
+		times = append(times, formatClock(m))
+	}
+	return times, nil
+}
+
+// --- Report ---
+
+func (u *reportUsecase) BuildReport(userID int64, from, to time.Time) (*models.ShiftReport, error) {
+	report := &models.ShiftReport{UserID: userID, From: from, To: to}
+
+	alerts, err := u.repo.GetAlertsFiredBetween(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	alertsByMachine := make(map[string]int)
+	for _, a := range alerts {
+		report.Alerts.Fired++
+		if a.AckedAt != nil {
+			report.Alerts.Acked++
+		}
+		switch a.Status {
+		case entities.AlertStatusResolved:
+			report.Alerts.Resolved++
+		case entities.AlertStatusOpen:
+			report.Alerts.Open++
+		}
+		if a.MachineID != "" {
+			alertsByMachine[a.MachineID]++
+		}
+	}
+
+	services, err := u.repo.GetServices(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services {
+		machines, err := u.machineReports(svc, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for i := range machines {
+			machines[i].Alerts = alertsByMachine[machines[i].MachineID]
+		}
+		report.Machines = append(report.Machines, machines...)
+	}
+
+	targets, err := u.repo.GetTargets(userID)
+	if err != nil {
+		return nil, err
+	}
+	report.Targets = len(targets)
+
+	watches, err := u.repo.GetUserSilenceWatches(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range watches {
+		if !w.Silent {
+			continue
+		}
+		topic := models.SilentTopic{Target: fmt.Sprintf("#%d", w.TargetID), Key: fmt.Sprintf("#%d", w.KeyID)}
+		if target, err := u.repo.GetTargetByID(w.TargetID); err == nil {
+			topic.Target = target.Name
+			topic.Key = ruleKeyTitle(target, w.KeyID)
+		}
+		if w.SilentSince != nil {
+			topic.Since = *w.SilentSince
+		}
+		report.SilentTopics = append(report.SilentTopics, topic)
+	}
+
+	return report, nil
+}
+
+// machineReports replays the status log of the service machines over [from, to)
+func (u *reportUsecase) machineReports(svc entities.FanucService, from, to time.Time) ([]models.MachineReport, error) {
+	initial, err := u.repo.GetMachineStatusesAt(svc.ID, from)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := u.repo.GetMachineStatusLog(svc.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	type replay struct {
+		report models.MachineReport
+		state  machineState
+		since  time.Time
+	}
+	machines := make(map[string]*replay)
+	var order []string
+	get := func(machineID string) *replay {
+		r, ok := machines[machineID]
+		if !ok {
+			r = &replay{report: models.MachineReport{ServiceID: svc.ID, Service: svc.Name, MachineID: machineID}}
+			machines[machineID] = r
+			order = append(order, machineID)
+		}
+		return r
+	}
+	// advance accounts the time spent in the current state up to the moment
+	advance := func(r *replay, until time.Time) {
+		d := until.Sub(r.since)
+		if d <= 0 || r.state.Status == "" || r.state.Status == machineStatusRemoved {
+			return
+		}
+		r.report.Observed += d
+		if r.state.Status == "connected" {
+			r.report.Connected += d
+		}
+		if r.state.Mode == "polling" {
+			r.report.Polling += d
+		}
+	}
+
+	for _, e := range initial {
+		if e.Status == machineStatusRemoved {
+			continue
+		}
+		r := get(e.MachineID)
+		r.state = machineState{Status: e.Status, Mode: e.Mode}
+		r.since = from
+	}
+	for _, e := range changes {
+		r := get(e.MachineID)
+		advance(r, e.CreatedAt)
+		if e.PrevStatus != "" {
+			r.report.Transitions++
+		}
+		r.state = machineState{Status: e.Status, Mode: e.Mode}
+		r.since = e.CreatedAt
+	}
+
+	end := to
+	if now := time.Now(); now.Before(end) {
+		end = now
+	}
+	reports := make([]models.MachineReport, 0, len(order))
+	sort.Strings(order)
+	for _, id := range order {
+		r := machines[id]
+		advance(r, end)
+		r.report.Status = r.state.Status
+		reports = append(reports, r.report)
+	}
+	return reports, nil
+}
+
+// formatReport renders the report as a Telegram message
+func formatReport(r *models.ShiftReport, loc *time.Location) string {
+	var b strings.Builder
+	b.WriteString("📊 <b>Отчет за смену</b>\n")
+	b.WriteString(fmt.Sprintf("🕒 %s – %s (%s)\n",
+		r.From.In(loc).Format("01-02 15:04"), r.To.In(loc).Format("01-02 15:04"), loc.String()))
+
+	// Machines grouped by service
+	b.WriteString("\n🏭 <b>Станки</b>\n")
+	if len(r.Machines) == 0 {
+		b.WriteString("Нет данных о станках\n")
+	}
+	service := ""
+	for i, m := range r.Machines {
+		if b.Len() > maxReportText {
+			b.WriteString(fmt.Sprintf("… и еще %d станков\n", len(r.Machines)-i))
+			break
+		}
+		if m.Service != service {
+			service = m.Service
+			b.WriteString(fmt.Sprintf("🌐 %s\n", html.EscapeString(service)))
+		}
+		b.WriteString(formatMachineReport(m))
+	}
+
+	b.WriteString(fmt.Sprintf("\n🚨 <b>Оповещения</b>: сработало %d, принято %d, решено %d, открыто %d\n",
+		r.Alerts.Fired, r.Alerts.Acked, r.Alerts.Resolved, r.Alerts.Open))
+
+	b.WriteString(fmt.Sprintf("\n📋 <b>Топики</b> (Kafka Targets: %d)\n", r.Targets))
+	if len(r.SilentTopics) == 0 {
+		b.WriteString("✅ Тишины нет")
+	}
+	for _, t := range r.SilentTopics {
+		line := fmt.Sprintf("🔇 %s · %s", html.EscapeString(t.Target), html.EscapeString(t.Key))
+		if !t.Since.IsZero() {
+			line += fmt.Sprintf(" — нет данных %s", models.HumanDuration(r.To.Sub(t.Since)))
+		}
+		b.WriteString(line + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func formatMachineReport(m models.MachineReport) string {
+	icon := machineStatusIcon(m.Status)
+	if m.Status == "" || m.Status == machineStatusRemoved {
+		icon = "⚪"
+	}
+	line := fmt.Sprintf("%s <code>%s</code>", icon, html.EscapeString(m.MachineID))
+	if m.Availability() < 0 {
+		return line + " — нет данных\n"
+	}
+	line += fmt.Sprintf(" — доступность %s · опрос %s", formatShare(m.Availability()), formatShare(m.PollingUptime()))
+	if m.Transitions > 0 {
+		line += fmt.Sprintf(" · смен: %d", m.Transitions)
+	}
+	if m.Alerts > 0 {
+		line += fmt.Sprintf(" · 🚨 %d", m.Alerts)
+	}
+	return line + "\n"
+}
+
+func formatShare(v float64) string {
+	return strconv.Itoa(int(v*100+0.5)) + "%"
+}
+
+// reportCSV builds one row per machine
+func reportCSV(r *models.ShiftReport, loc *time.Location) (*models.ExportFile, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"service", "machine_id", "last_status", "availability_pct", "polling_uptime_pct", "observed_min", "transitions", "alerts"}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	share := func(v float64) string {
+		if v < 0 {
+			return ""
+		}
+		return strconv.FormatFloat(v*100, 'f', 1, 64)
+	}
+	for _, m := range r.Machines {
+		row := []string{
+			m.Service,
+			m.MachineID,
+			m.Status,
+			share(m.Availability()),
+			share(m.PollingUptime()),
+			strconv.Itoa(int(m.Observed.Minutes())),
+			strconv.Itoa(m.Transitions),
+			strconv.Itoa(m.Alerts),
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+
+	return &models.ExportFile{
+		Name:  fmt.Sprintf("shift_report_%s.csv", r.To.In(loc).Format("20060102_1504")),
+		MIME:  "text/csv",
+		Data:  buf.Bytes(),
+		Count: len(r.Machines),
+	}, nil
+}
@@ -1,18 +1,23 @@
 package usecases
 
 import (
+	"context"
+	"crypto/x509"
+	"fmt"
 	"strings"
 
 	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
 	"github.com/iwtcode/fanucClient/internal/interfaces"
 )
 
 type settingsUsecase struct {
-	repo interfaces.UserRepository
+	repo     interfaces.UserRepository
+	kafkaSvc interfaces.KafkaReader
 }
 
-func NewSettingsUsecase(repo interfaces.UserRepository) interfaces.SettingsUsecase {
-	return &settingsUsecase{repo: repo}
+func NewSettingsUsecase(repo interfaces.UserRepository, kafkaSvc interfaces.KafkaReader) interfaces.SettingsUsecase {
+	return &settingsUsecase{repo: repo, kafkaSvc: kafkaSvc}
 }
 
 // --- Common ---
@@ -29,6 +34,24 @@ func (u *settingsUsecase) SetState(id int64, state string) error {
 	return u.repo.UpdateState(id, state)
 }
 
+func (u *settingsUsecase) CancelWizard(id int64) error {
+	return u.repo.UpdateDraft(id, finishWizard())
+}
+
+// finishWizard returns the user to idle and wipes credentials from the drafts:
+// the password and CA must not stay in the users table after the wizard
+func finishWizard() map[string]interface{} {
+	return map[string]interface{}{
+		"state":                entities.StateIdle,
+		"draft_sasl_mechanism": "",
+		"draft_sasl_username":  "",
+		"draft_sasl_password":  "",
+		"draft_tls_enabled":    false,
+		"draft_tls_ca_cert":    "",
+		"draft_tls_insecure":   false,
+	}
+}
+
 // --- Context Helpers ---
 
 func (u *settingsUsecase) SetContextSvcID(userID int64, svcID uint) error {
@@ -43,6 +66,24 @@ func (u *settingsUsecase) SetContextTargetID(userID int64, targetID uint) error
 	return u.repo.UpdateDraft(userID, map[string]interface{}{"context_target_id": targetID})
 }
 
+func (u *settingsUsecase) SetContextKeyID(userID int64, keyID uint) error {
+	return u.repo.UpdateDraft(userID, map[string]interface{}{"context_key_id": keyID})
+}
+
+func (u *settingsUsecase) SetContextRuleID(userID int64, ruleID uint) error {
+	return u.repo.UpdateDraft(userID, map[string]interface{}{"context_rule_id": ruleID})
+}
+
+// --- Alert History Filters ---
+
+func (u *settingsUsecase) SetAlertFilterStatus(userID int64, status string) error {
+	return u.repo.UpdateDraft(userID, map[string]interface{}{"alert_filter_status": status})
+}
+
+func (u *settingsUsecase) SetAlertFilterMachine(userID int64, machineID string) error {
+	return u.repo.UpdateDraft(userID, map[string]interface{}{"alert_filter_machine": machineID})
+}
+
 // --- Connection Wizard Steps ---
 
 func (u *settingsUsecase) SetDraftConnEndpoint(userID int64, endpoint string) error {
@@ -76,30 +117,144 @@ func (u *settingsUsecase) SetDraftName(id int64, name string) error {
 }
 
 func (u *settingsUsecase) SetDraftBroker(id int64, broker string) error {
+	// Bootstrap list is stored normalized: "host1:9092,host2:9092"
+	brokers := models.ParseBrokers(broker)
+	if len(brokers) == 0 {
+		return fmt.Errorf("укажите хотя бы один брокер")
+	}
+
 	return u.repo.UpdateDraft(id, map[string]interface{}{
-		"draft_broker": broker,
-		"state":        entities.StateWaitingTopic,
+		"draft_broker": strings.Join(brokers, ","),
+		"state":        entities.StateWaitingAuthMechanism,
 	})
 }
 
-func (u *settingsUsecase) SetDraftTopicAndSave(id int64, topic string) error {
+func (u *settingsUsecase) SetDraftAuthMechanism(id int64, mechanism string) error {
+	switch mechanism {
+	case models.SASLNone:
+		// No credentials needed, go straight to TLS
+		return u.repo.UpdateDraft(id, map[string]interface{}{
+			"draft_sasl_mechanism": mechanism,
+			"draft_sasl_username":  "",
+			"draft_sasl_password":  "",
+			"state":                entities.StateWaitingTLS,
+		})
+	case models.SASLPlain, models.SASLScramSHA256, models.SASLScramSHA512:
+		return u.repo.UpdateDraft(id, map[string]interface{}{
+			"draft_sasl_mechanism": mechanism,
+			"state":                entities.StateWaitingAuthUser,
+		})
+	default:
+		return fmt.Errorf("неподдерживаемый SASL механизм: %s", mechanism)
+	}
+}
+
+func (u *settingsUsecase) SetDraftAuthUser(id int64, username string) error {
+	return u.repo.UpdateDraft(id, map[string]interface{}{
+		"draft_sasl_username": username,
+		"state":               entities.StateWaitingAuthPassword,
+	})
+}
+
+func (u *settingsUsecase) SetDraftAuthPassword(id int64, password string) error {
+	return u.repo.UpdateDraft(id, map[string]interface{}{
+		"draft_sasl_password": password,
+		"state":               entities.StateWaitingTLS,
+	})
+}
+
+func (u *settingsUsecase) SetDraftTLS(id int64, enabled, insecure bool, caCert string) error {
+	if caCert != "" {
+		if !x509.NewCertPool().AppendCertsFromPEM([]byte(caCert)) {
+			return fmt.Errorf("не удалось разобрать CA сертификат (ожидается PEM)")
+		}
+		enabled = true
+	}
+
+	return u.repo.UpdateDraft(id, map[string]interface{}{
+		"draft_tls_enabled":  enabled,
+		"draft_tls_insecure": enabled && insecure,
+		"draft_tls_ca_cert":  caCert,
+		"state":              entities.StateWaitingTopic,
+	})
+}
+
+func (u *settingsUsecase) ListDraftTopics(ctx context.Context, id int64) ([]models.TopicInfo, error) {
+	user, err := u.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	topics, err := u.kafkaSvc.ListTopics(ctx, draftSource(user))
+	if err != nil {
+		return nil, fmt.Errorf("кластер недоступен: %w", err)
+	}
+	return topics, nil
+}
+
+func (u *settingsUsecase) SetDraftTopicAndSave(ctx context.Context, id int64, topic string) error {
 	user, err := u.repo.GetByID(id)
 	if err != nil {
 		return err
 	}
 
+	// Nothing is saved until the cluster answers and the topic exists
+	topic = strings.TrimSpace(topic)
+	topics, err := u.kafkaSvc.ListTopics(ctx, draftSource(user))
+	if err != nil {
+		return fmt.Errorf("кластер недоступен: %w", err)
+	}
+	found := false
+	for _, t := range topics {
+		if t.Name == topic {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("топик '%s' не найден в кластере", topic)
+	}
+
 	target := &entities.MonitoringTarget{
 		UserID: user.ID,
 		Name:   user.DraftName,
 		Broker: user.DraftBroker,
 		Topic:  topic,
+
+		SASLMechanism: user.DraftSASLMechanism,
+		SASLUsername:  user.DraftSASLUsername,
+		SASLPassword:  user.DraftSASLPassword,
+		TLSEnabled:    user.DraftTLSEnabled,
+		TLSCACert:     user.DraftTLSCACert,
+		TLSInsecure:   user.DraftTLSInsecure,
 		// No keys initially
 	}
 
 	if err := u.repo.AddTarget(target); err != nil {
 		return err
 	}
-	return u.repo.UpdateState(id, entities.StateIdle)
+	return u.repo.UpdateDraft(id, finishWizard())
+}
+
+func (u *settingsUsecase) SetTargetConsumerGroup(userID int64, targetID uint, group string) error {
+	if err := u.repo.UpdateTarget(targetID, map[string]interface{}{"consumer_group": strings.TrimSpace(group)}); err != nil {
+		return err
+	}
+	return u.repo.UpdateState(userID, entities.StateIdle)
+}
+
+// draftSource builds connection parameters from the wizard drafts
+func draftSource(user *entities.User) models.KafkaSource {
+	return targetSource(&entities.MonitoringTarget{
+		Broker:        user.DraftBroker,
+		Topic:         user.DraftTopic,
+		SASLMechanism: user.DraftSASLMechanism,
+		SASLUsername:  user.DraftSASLUsername,
+		SASLPassword:  user.DraftSASLPassword,
+		TLSEnabled:    user.DraftTLSEnabled,
+		TLSCACert:     user.DraftTLSCACert,
+		TLSInsecure:   user.DraftTLSInsecure,
+	})
 }
 
 func (u *settingsUsecase) GetTargets(userID int64) ([]entities.MonitoringTarget, error) {
@@ -116,15 +271,43 @@ func (u *settingsUsecase) GetTargetByID(targetID uint) (*entities.MonitoringTarg
 
 // --- Kafka Key Management ---
 
+func (u *settingsUsecase) SetDraftKeyMode(userID int64, mode string) error {
+	if _, err := (models.KeyFilter{Mode: mode}).Compile(); err != nil {
+		return err
+	}
+	return u.repo.UpdateDraft(userID, map[string]interface{}{"draft_key_mode": mode})
+}
+
+// SetDraftKeyHeader switches the new key to a header value (empty = record key)
+func (u *settingsUsecase) SetDraftKeyHeader(userID int64, header string) error {
+	return u.repo.UpdateDraft(userID, map[string]interface{}{
+		"draft_key_header": strings.TrimSpace(header),
+		"state":            entities.StateWaitingNewKey,
+	})
+}
+
 func (u *settingsUsecase) AddKeyToTarget(userID int64, key string) error {
 	user, err := u.repo.GetByID(userID)
 	if err != nil {
 		return err
 	}
 
+	mode := user.DraftKeyMode
+	if mode == "" {
+		mode = models.KeyMatchExact
+	}
+	if key == "" {
+		return fmt.Errorf("ключ не может быть пустым")
+	}
+	if err := (models.KeyFilter{Mode: mode, Pattern: key, Header: user.DraftKeyHeader}).Validate(); err != nil {
+		return err
+	}
+
 	newKey := &entities.MonitoringKey{
-		TargetID: user.ContextTargetID,
-		Key:      key,
+		TargetID:  user.ContextTargetID,
+		Key:       key,
+		MatchMode: mode,
+		Header:    user.DraftKeyHeader,
 	}
 
 	if err := u.repo.AddKey(newKey); err != nil {
@@ -133,6 +316,33 @@ func (u *settingsUsecase) AddKeyToTarget(userID int64, key string) error {
 	return u.repo.UpdateState(userID, entities.StateIdle)
 }
 
+func (u *settingsUsecase) SetKeyProjection(userID int64, keyID uint, projection string) error {
+	paths, err := parseProjection(projection)
+	if err != nil {
+		return err
+	}
+	// Normalized form: one path per comma
+	raw := make([]string, len(paths))
+	for i, p := range paths {
+		raw[i] = p.raw
+	}
+
+	if err := u.repo.UpdateKey(keyID, map[string]interface{}{"projection": strings.Join(raw, ", ")}); err != nil {
+		return err
+	}
+	return u.repo.UpdateState(userID, entities.StateIdle)
+}
+
+func (u *settingsUsecase) SetKeyPredicate(userID int64, keyID uint, predicate string) error {
+	if _, err := parsePredicate(predicate); err != nil {
+		return err
+	}
+	if err := u.repo.UpdateKey(keyID, map[string]interface{}{"predicate": strings.TrimSpace(predicate)}); err != nil {
+		return err
+	}
+	return u.repo.UpdateState(userID, entities.StateIdle)
+}
+
 func (u *settingsUsecase) DeleteKey(keyID uint) error {
 	return u.repo.DeleteKey(keyID)
 }
@@ -0,0 +1,181 @@
+package usecases
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+)
+
+// Пути к полям внутри FanucMessage.Data. Схема Data в fanucService не зафиксирована контрактом,
+// поэтому для каждого поля перечислены принимаемые имена в порядке приоритета
+// (поддерживаемые формы сообщений - в telemetry_test.go).
+var (
+	runModePaths       = mustPaths("mode", "run_mode", "status.mode", "aut")
+	runStatePaths      = mustPaths("run_status", "status.run", "run", "state")
+	programNumberPaths = mustPaths("program.number", "program_number", "prog_num", "o_number")
+	programNamePaths   = mustPaths("program.name", "program_name")
+	spindleSpeedPaths  = mustPaths("spindle.speed", "spindle_speed", "spindles[0].speed")
+	spindleLoadPaths   = mustPaths("spindle.load", "spindle_load", "spindles[0].load")
+	feedRatePaths      = mustPaths("feed.rate", "feed_rate", "feedrate", "feed.actual")
+	feedOverridePaths  = mustPaths("feed.override", "feed_override")
+	partCountPaths     = mustPaths("part_count", "parts.count", "counters.parts")
+	alarmsPaths        = mustPaths("alarms", "alarm.list")
+)
+
+func mustPaths(raw ...string) []jsonPath {
+	paths := make([]jsonPath, len(raw))
+	for i, r := range raw {
+		p, err := parsePath(r)
+		if err != nil {
+			panic(err)
+		}
+		paths[i] = p
+	}
+	return paths
+}
+
+func (u *monitoringUsecase) DecodeStatus(value string) (*models.MachineStatus, bool) {
+	return decodeMachineStatus(value)
+}
+
+// decodeMachineStatus recognizes a fanucService payload (FanucMessage with a data object
+// containing at least one known field); anything else is reported as unknown shape
+func decodeMachineStatus(value string) (*models.MachineStatus, bool) {
+	var msg models.FanucMessage
+	if err := json.Unmarshal([]byte(value), &msg); err != nil || msg.MachineID == "" || len(msg.Data) == 0 {
+		return nil, false
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		return nil, false
+	}
+	if _, ok := data.(map[string]interface{}); !ok {
+		return nil, false
+	}
+
+	st := &models.MachineStatus{MachineID: msg.MachineID}
+	if msg.Timestamp > 0 {
+		st.Time = time.UnixMilli(msg.Timestamp)
+	}
+
+	known := false
+	if v, ok := firstString(data, runModePaths); ok {
+		st.RunMode, known = v, true
+	}
+	if v, ok := firstString(data, runStatePaths); ok {
+		st.RunState, known = v, true
+	}
+	if v, ok := firstString(data, programNumberPaths); ok {
+		st.ProgramNumber, known = v, true
+	}
+	if v, ok := firstString(data, programNamePaths); ok {
+		st.ProgramName, known = v, true
+	}
+	if v, ok := firstNumber(data, spindleSpeedPaths); ok {
+		st.SpindleSpeed, known = &v, true
+	}
+	if v, ok := firstNumber(data, spindleLoadPaths); ok {
+		st.SpindleLoad, known = &v, true
+	}
+	if v, ok := firstNumber(data, feedRatePaths); ok {
+		st.FeedRate, known = &v, true
+	}
+	if v, ok := firstNumber(data, feedOverridePaths); ok {
+		st.FeedOverride, known = &v, true
+	}
+	if v, ok := firstNumber(data, partCountPaths); ok {
+		count := int64(v)
+		st.PartCount, known = &count, true
+	}
+	if alarms, ok := decodeAlarms(data); ok {
+		st.Alarms, st.HasAlarms, known = alarms, true, true
+	}
+
+	if !known {
+		return nil, false
+	}
+	return st, true
+}
+
+func firstValue(doc interface{}, paths []jsonPath) (interface{}, bool) {
+	for _, p := range paths {
+		if values := p.eval(doc); len(values) > 0 && values[0] != nil {
+			return values[0], true
+		}
+	}
+	return nil, false
+}
+
+func firstString(doc interface{}, paths []jsonPath) (string, bool) {
+	v, ok := firstValue(doc, paths)
+	if !ok {
+		return "", false
+	}
+	switch s := v.(type) {
+	case string:
+		return s, s != ""
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(s), true
+	}
+	return "", false
+}
+
+func firstNumber(doc interface{}, paths []jsonPath) (float64, bool) {
+	v, ok := firstValue(doc, paths)
+	if !ok {
+		return 0, false
+	}
+	return toFloat(v)
+}
+
+// decodeAlarms accepts a list of strings or objects with code/number and message/text
+func decodeAlarms(doc interface{}) ([]models.MachineAlarm, bool) {
+	v, ok := firstValue(doc, alarmsPaths)
+	if !ok {
+		return nil, false
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	alarms := make([]models.MachineAlarm, 0, len(list))
+	for _, item := range list {
+		switch a := item.(type) {
+		case string:
+			alarms = append(alarms, models.MachineAlarm{Message: a})
+		case float64:
+			alarms = append(alarms, models.MachineAlarm{Code: strconv.FormatFloat(a, 'f', -1, 64)})
+		case map[string]interface{}:
+			alarms = append(alarms, models.MachineAlarm{
+				Code:    pickField(a, "code", "number", "id", "alarm_no"),
+				Message: pickField(a, "message", "msg", "text", "description"),
+			})
+		}
+	}
+	return alarms, true
+}
+
+func pickField(obj map[string]interface{}, names ...string) string {
+	for _, name := range names {
+		switch v := obj[name].(type) {
+		case string:
+			if v != "" {
+				return strings.TrimSpace(v)
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case nil:
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
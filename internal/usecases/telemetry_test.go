@@ -0,0 +1,80 @@
+package usecases
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+)
+
+func TestDecodeMachineStatus(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	n := func(v int64) *int64 { return &v }
+
+	tests := []struct {
+		name  string
+		value string
+		want  *models.MachineStatus // nil = unknown shape, shown as raw JSON
+	}{
+		{
+			name: "nested objects",
+			value: `{"machine_id":"M-01","timestamp":1767225600000,"data":{
+				"mode":"AUTO","run_status":"START",
+				"program":{"number":"O1234","name":"SHAFT"},
+				"spindle":{"speed":1200,"load":35.5},
+				"feed":{"rate":800,"override":100},
+				"part_count":42,
+				"alarms":[{"code":1001,"message":"SERVO ALARM"}]}}`,
+			want: &models.MachineStatus{
+				MachineID: "M-01", Time: time.UnixMilli(1767225600000),
+				RunMode: "AUTO", RunState: "START", ProgramNumber: "O1234", ProgramName: "SHAFT",
+				SpindleSpeed: f(1200), SpindleLoad: f(35.5), FeedRate: f(800), FeedOverride: f(100),
+				PartCount: n(42),
+				Alarms:    []models.MachineAlarm{{Code: "1001", Message: "SERVO ALARM"}}, HasAlarms: true,
+			},
+		},
+		{
+			name: "flat fields",
+			value: `{"machine_id":"M-02","data":{"run_mode":"MDI","program_number":1234,
+				"spindle_speed":"900","feed_rate":150,"alarms":[]}}`,
+			want: &models.MachineStatus{
+				MachineID: "M-02", RunMode: "MDI", ProgramNumber: "1234",
+				SpindleSpeed: f(900), FeedRate: f(150),
+				Alarms: []models.MachineAlarm{}, HasAlarms: true,
+			},
+		},
+		{
+			name:  "spindle list and alarm strings",
+			value: `{"machine_id":"M-03","data":{"spindles":[{"speed":3000,"load":10}],"alarm":{"list":["EMERGENCY STOP",2001]}}}`,
+			want: &models.MachineStatus{
+				MachineID: "M-03", SpindleSpeed: f(3000), SpindleLoad: f(10),
+				Alarms: []models.MachineAlarm{{Message: "EMERGENCY STOP"}, {Code: "2001"}}, HasAlarms: true,
+			},
+		},
+		{
+			name:  "alarm objects with other field names",
+			value: `{"machine_id":"M-04","data":{"alarms":[{"alarm_no":"SV0401","msg":" V-READY OFF "}]}}`,
+			want: &models.MachineStatus{
+				MachineID: "M-04",
+				Alarms:    []models.MachineAlarm{{Code: "SV0401", Message: "V-READY OFF"}}, HasAlarms: true,
+			},
+		},
+		{name: "unknown fields", value: `{"machine_id":"M-05","data":{"temperature":40}}`},
+		{name: "no machine id", value: `{"data":{"mode":"AUTO"}}`},
+		{name: "data is not an object", value: `{"machine_id":"M-06","data":[1,2]}`},
+		{name: "not a fanuc message", value: `{"sensor":"t1","value":21.5}`},
+		{name: "not json", value: `plain text`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := decodeMachineStatus(tt.value)
+			if ok != (tt.want != nil) {
+				t.Fatalf("decodeMachineStatus() ok = %v, want %v", ok, tt.want != nil)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeMachineStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
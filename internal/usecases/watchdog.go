@@ -0,0 +1,175 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iwtcode/fanucClient/internal/domain/entities"
+	"github.com/iwtcode/fanucClient/internal/domain/models"
+	"github.com/iwtcode/fanucClient/internal/interfaces"
+)
+
+const (
+	// Границы настройки максимальной тишины
+	minSilence = time.Minute
+	maxSilence = 7 * 24 * time.Hour
+
+	// Таймаут чтения времени последнего сообщения одного (target, key)
+	silenceCheckTimeout = 20 * time.Second
+	// Сколько (target, key) проверяется одновременно
+	silenceCheckParallel = 8
+)
+
+type watchdogUsecase struct {
+	repo         interfaces.UserRepository
+	monitoringUC interfaces.MonitoringUsecase
+	notifyUC     interfaces.NotificationUsecase
+}
+
+func NewWatchdogUsecase(
+	repo interfaces.UserRepository,
+	monitoringUC interfaces.MonitoringUsecase,
+	notifyUC interfaces.NotificationUsecase,
+) interfaces.WatchdogUsecase {
+	return &watchdogUsecase{
+		repo:         repo,
+		monitoringUC: monitoringUC,
+		notifyUC:     notifyUC,
+	}
+}
+
+func (u *watchdogUsecase) SetMaxSilence(userID int64, targetID, keyID uint, d time.Duration) error {
+	target, err := u.repo.GetTargetByID(targetID)
+	if err != nil || target.UserID != userID {
+		return fmt.Errorf("kafka target не найден")
+	}
+	if d == 0 {
+		return u.repo.DeleteSilenceWatch(targetID, keyID)
+	}
+	if d < minSilence || d > maxSilence {
+		return fmt.Errorf("допустимая пауза: от %s до %s", models.HumanDuration(minSilence), models.HumanDuration(maxSilence))
+	}
+
+	return u.repo.SaveSilenceWatch(&entities.SilenceWatch{
+		UserID:        userID,
+		TargetID:      targetID,
+		KeyID:         keyID,
+		MaxSilenceSec: int(d / time.Second),
+	})
+}
+
+func (u *watchdogUsecase) GetSilenceWatch(targetID, keyID uint) (*entities.SilenceWatch, error) {
+	return u.repo.GetSilenceWatch(targetID, keyID)
+}
+
+func (u *watchdogUsecase) CheckSilence(ctx context.Context) {
+	watches, err := u.repo.GetAllSilenceWatches()
+	if err != nil {
+		log.Printf("⚠️ Watchdog: не удалось загрузить настройки тишины: %v", err)
+		return
+	}
+
+	sem := make(chan struct{}, silenceCheckParallel)
+	var wg sync.WaitGroup
+	for _, w := range watches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(w entities.SilenceWatch) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			u.checkWatch(ctx, w)
+		}(w)
+	}
+	wg.Wait()
+}
+
+// checkWatch compares the age of the newest message with the limit and reports transitions.
+// A failed read counts as silence since the last message seen before.
+func (u *watchdogUsecase) checkWatch(ctx context.Context, w entities.SilenceWatch) {
+	readCtx, cancel := context.WithTimeout(ctx, silenceCheckTimeout)
+	last, readErr := u.monitoringUC.LastMessageTime(readCtx, w.TargetID, w.KeyID)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if readErr == nil && last.After(w.LastSeen) {
+		w.LastSeen = last
+		updates["last_seen"] = last
+	}
+
+	// No message at all since the watch was configured
+	ref := w.LastSeen
+	if ref.IsZero() {
+		ref = w.CreatedAt
+	}
+	now := time.Now()
+	limit := time.Duration(w.MaxSilenceSec) * time.Second
+	silentFor := now.Sub(ref)
+
+	switch {
+	case !w.Silent && silentFor > limit:
+		updates["silent"] = true
+		updates["silent_since"] = ref
+		u.notify(ctx, w, u.silenceText(w, silentFor, limit, readErr), models.SeverityWarning, fmt.Sprintf("silence:%d:%d", w.ID, ref.Unix()))
+	case w.Silent && silentFor <= limit:
+		updates["silent"] = false
+		updates["silent_since"] = nil
+		lasted := silentFor
+		if w.SilentSince != nil {
+			lasted = w.LastSeen.Sub(*w.SilentSince)
+		}
+		u.notify(ctx, w, u.recoveryText(w, lasted), models.SeverityInfo, "")
+	}
+
+	if len(updates) > 0 {
+		if err := u.repo.UpdateSilenceWatch(w.ID, updates); err != nil {
+			log.Printf("⚠️ Watchdog: не удалось сохранить состояние %d: %v", w.ID, err)
+		}
+	}
+}
+
+func (u *watchdogUsecase) silenceText(w entities.SilenceWatch, silentFor, limit time.Duration, readErr error) string {
+	var b strings.Builder
+	b.WriteString("🔇 <b>Нет новых данных</b>\n")
+	b.WriteString(u.watchTitle(w))
+	b.WriteString(fmt.Sprintf("⏱ Тишина: %s (порог %s)\n", models.HumanDuration(silentFor), models.HumanDuration(limit)))
+	if w.LastSeen.IsZero() {
+		b.WriteString("🕒 Сообщений не было с момента настройки\n")
+	} else {
+		b.WriteString(fmt.Sprintf("🕒 Последнее сообщение: %s\n", w.LastSeen.Local().Format("2006-01-02 15:04:05")))
+	}
+	if readErr != nil {
+		b.WriteString(fmt.Sprintf("⚠️ %s\n", html.EscapeString(readErr.Error())))
+	}
+	return b.String()
+}
+
+func (u *watchdogUsecase) recoveryText(w entities.SilenceWatch, lasted time.Duration) string {
+	var b strings.Builder
+	b.WriteString("✅ <b>Данные снова поступают</b>\n")
+	b.WriteString(u.watchTitle(w))
+	b.WriteString(fmt.Sprintf("⏱ Тишина длилась: %s\n", models.HumanDuration(lasted)))
+	return b.String()
+}
+
+func (u *watchdogUsecase) watchTitle(w entities.SilenceWatch) string {
+	target, err := u.repo.GetTargetByID(w.TargetID)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("📋 %s · %s\n", html.EscapeString(target.Name), html.EscapeString(ruleKeyTitle(target, w.KeyID)))
+}
+
+func (u *watchdogUsecase) notify(ctx context.Context, w entities.SilenceWatch, text, severity, dedupKey string) {
+	n := models.Notification{ChatID: w.UserID, Text: text, Severity: severity, DedupKey: dedupKey}
+	if err := u.notifyUC.Notify(ctx, n); err != nil {
+		log.Printf("⚠️ Watchdog: не удалось отправить уведомление %d: %v", w.ID, err)
+	}
+}